@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
 
@@ -17,9 +18,12 @@ func main() {
 	ctx := context.TODO()
 	logger := klogr.New().WithName("orchid")
 
-	options := orchid.Options{
-		Address: ":8080",
-	}
+	options := orchid.Options{}
+	flag.StringVar(&options.Address, "address", ":8080", "address the server listens on")
+	flag.BoolVar(&options.AssembleJSON, "assemble-json", false,
+		"assemble objects as JSON in the database, instead of the Go assembler")
+	flag.Parse()
+
 	srv := orchid.NewServer(logger, options)
 
 	logger.Info("Starting server")
@@ -3,8 +3,10 @@ module github.com/isutton/orchid
 go 1.14
 
 require (
+	github.com/evanphx/json-patch v4.5.0+incompatible
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-logr/logr v0.1.0
+	github.com/go-openapi/spec v0.19.3
 	github.com/go-test/deep v1.0.4
 	github.com/gorilla/mux v1.7.3
 	github.com/lib/pq v1.2.0
@@ -0,0 +1,182 @@
+package apiserver
+
+import (
+	"sort"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+
+	"github.com/isutton/orchid/pkg/orchid/repository"
+)
+
+// servedVerbs verbs supported by the object routes, shared by all resources backed by CRDs.
+var servedVerbs = metav1.Verbs{"create", "delete", "get", "list", "patch", "update", "watch"}
+
+// statusVerbs verbs supported by the status subresource routes.
+var statusVerbs = metav1.Verbs{"get", "patch", "update"}
+
+// scaleVerbs verbs supported by the scale subresource routes.
+var scaleVerbs = metav1.Verbs{"get", "patch", "update"}
+
+// GroupVersionResources resources served per group-version.
+type GroupVersionResources map[schema.GroupVersion][]metav1.APIResource
+
+// add resource under group-version.
+func (g GroupVersionResources) add(gv schema.GroupVersion, resource metav1.APIResource) {
+	g[gv] = append(g[gv], resource)
+}
+
+// builtinResources returns the resources served by orchid itself, regardless of stored CRDs.
+func builtinResources() GroupVersionResources {
+	resources := GroupVersionResources{}
+	for _, resource := range []metav1.APIResource{crdAPIResource, transactionAPIResource} {
+		gv := schema.GroupVersion{Group: resource.Group, Version: resource.Version}
+		resource.Group, resource.Version = "", ""
+		resources.add(gv, resource)
+	}
+	return resources
+}
+
+// crdResources returns the resources described by the CRDs stored in the default namespace, one
+// per served version. It can return errors on listing and converting CRDs.
+func (h *APIResourceHandler) crdResources() (GroupVersionResources, error) {
+	crds, err := h.repo.List(repository.DefaultNamespace, repository.CRDGVK, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	resources := GroupVersionResources{}
+	for _, item := range crds.Items {
+		crd, err := repository.ExtractCRD(item.Object)
+		if err != nil {
+			return nil, err
+		}
+		names := crd.Spec.Names
+		for _, crdVersion := range crd.Spec.Versions {
+			if !crdVersion.Served {
+				continue
+			}
+			gv := schema.GroupVersion{Group: crd.Spec.Group, Version: crdVersion.Name}
+			resources.add(gv, metav1.APIResource{
+				Name:         names.Plural,
+				SingularName: names.Singular,
+				Namespaced:   crd.Spec.Scope == extv1.NamespaceScoped,
+				Kind:         names.Kind,
+				Verbs:        servedVerbs,
+				ShortNames:   names.ShortNames,
+				Categories:   names.Categories,
+			})
+			if crdVersion.Subresources != nil && crdVersion.Subresources.Status != nil {
+				resources.add(gv, metav1.APIResource{
+					Name:       names.Plural + "/" + statusField,
+					Namespaced: crd.Spec.Scope == extv1.NamespaceScoped,
+					Kind:       names.Kind,
+					Verbs:      statusVerbs,
+				})
+			}
+			if crdVersion.Subresources != nil && crdVersion.Subresources.Scale != nil {
+				resources.add(gv, metav1.APIResource{
+					Name:       names.Plural + "/" + scaleField,
+					Namespaced: crd.Spec.Scope == extv1.NamespaceScoped,
+					Group:      autoscalingv1.GroupName,
+					Version:    autoscalingv1.SchemeGroupVersion.Version,
+					Kind:       "Scale",
+					Verbs:      scaleVerbs,
+				})
+			}
+		}
+	}
+	return resources, nil
+}
+
+// servedResources returns built-in resources together with the ones described by stored CRDs.
+func (h *APIResourceHandler) servedResources() (GroupVersionResources, error) {
+	resources, err := h.crdResources()
+	if err != nil {
+		return nil, err
+	}
+	for gv, builtin := range builtinResources() {
+		resources[gv] = append(resources[gv], builtin...)
+	}
+	return resources, nil
+}
+
+// apiGroups returns the API groups having resources, sorted by name. Group versions are sorted by
+// priority, following Kubernetes version ordering, and the first one is preferred.
+func apiGroups(resources GroupVersionResources) []metav1.APIGroup {
+	versions := map[string][]string{}
+	for gv := range resources {
+		versions[gv.Group] = append(versions[gv.Group], gv.Version)
+	}
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([]metav1.APIGroup, 0, len(names))
+	for _, name := range names {
+		groupVersions := versions[name]
+		sort.Slice(groupVersions, func(i, j int) bool {
+			return version.CompareKubeAwareVersionStrings(groupVersions[i], groupVersions[j]) > 0
+		})
+
+		group := metav1.APIGroup{Name: name}
+		for _, groupVersion := range groupVersions {
+			group.Versions = append(group.Versions, metav1.GroupVersionForDiscovery{
+				GroupVersion: schema.GroupVersion{Group: name, Version: groupVersion}.String(),
+				Version:      groupVersion,
+			})
+		}
+		group.PreferredVersion = group.Versions[0]
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// CRDAPIGroups returns the API groups described by the CRDs stored. It can return errors on
+// listing CRDs.
+func (h *APIResourceHandler) CRDAPIGroups() ([]metav1.APIGroup, error) {
+	resources, err := h.crdResources()
+	if err != nil {
+		return nil, err
+	}
+	return apiGroups(resources), nil
+}
+
+// APIResourceLister lists the API resources served under the group and version informed in vars.
+// It returns ResourceNotFoundErr when the group-version is not served.
+func (h *APIResourceHandler) APIResourceLister(vars Vars, body []byte) (runtime.Object, error) {
+	resources, err := h.servedResources()
+	if err != nil {
+		return nil, err
+	}
+	gv := schema.GroupVersion{Group: vars["group"], Version: vars["version"]}
+	gvResources, found := resources[gv]
+	if !found {
+		return nil, ResourceNotFoundErr
+	}
+	sort.Slice(gvResources, func(i, j int) bool {
+		return gvResources[i].Name < gvResources[j].Name
+	})
+	return &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+		APIResources: gvResources,
+	}, nil
+}
+
+// APIGroupLister lists the API groups served, built-in and described by stored CRDs.
+func (h *APIResourceHandler) APIGroupLister(vars Vars, body []byte) (runtime.Object, error) {
+	resources, err := h.servedResources()
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.APIGroupList{
+		TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+		Groups:   apiGroups(resources),
+	}, nil
+}
@@ -0,0 +1,109 @@
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+func TestAPIResourceHandler_Discovery(t *testing.T) {
+	logger := klogr.New()
+
+	// cluster scoped CRD with multiple versions, where only part of them are served
+	multiVersion := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "widgets.stable.example.com"},
+		"spec": map[string]interface{}{
+			"group": "stable.example.com",
+			"scope": "Cluster",
+			"names": map[string]interface{}{
+				"kind":       "Widget",
+				"plural":     "widgets",
+				"singular":   "widget",
+				"categories": []interface{}{"all"},
+			},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "served": false, "storage": false},
+				map[string]interface{}{"name": "v1beta1", "served": true, "storage": false},
+				map[string]interface{}{"name": "v1", "served": true, "storage": true},
+			},
+		},
+	}}
+
+	repo := &TestResourcePostHandlerRepository{
+		CRDs: []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset)), multiVersion},
+	}
+	h := &APIResourceHandler{
+		logger:    logger,
+		repo:      repo,
+		validator: validation.NewRepositoryValidator(repo),
+	}
+
+	t.Run("APIGroupLister", func(t *testing.T) {
+		obj, err := h.APIGroupLister(Vars{}, nil)
+		require.NoError(t, err)
+		groupList, ok := obj.(*metav1.APIGroupList)
+		require.True(t, ok)
+
+		names := []string{}
+		for _, group := range groupList.Groups {
+			names = append(names, group.Name)
+		}
+		require.Equal(t, []string{"apiextensions.k8s.io", "orchid.io", "stable.example.com"}, names)
+
+		stable := groupList.Groups[2]
+		require.Equal(t, "stable.example.com/v1", stable.PreferredVersion.GroupVersion)
+		require.Len(t, stable.Versions, 2)
+		require.Equal(t, "v1beta1", stable.Versions[1].Version)
+	})
+
+	t.Run("CRDAPIGroups", func(t *testing.T) {
+		groups, err := h.CRDAPIGroups()
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		require.Equal(t, "stable.example.com", groups[0].Name)
+	})
+
+	t.Run("APIResourceLister", func(t *testing.T) {
+		obj, err := h.APIResourceLister(Vars{"group": "stable.example.com", "version": "v1"}, nil)
+		require.NoError(t, err)
+		resourceList, ok := obj.(*metav1.APIResourceList)
+		require.True(t, ok)
+		require.Equal(t, "stable.example.com/v1", resourceList.GroupVersion)
+		require.Len(t, resourceList.APIResources, 2)
+
+		crontabs := resourceList.APIResources[0]
+		require.Equal(t, "crontabs", crontabs.Name)
+		require.Equal(t, "crontab", crontabs.SingularName)
+		require.Equal(t, "CronTab", crontabs.Kind)
+		require.True(t, crontabs.Namespaced)
+		require.Equal(t, []string{"ct"}, crontabs.ShortNames)
+		require.Contains(t, crontabs.Verbs, "watch")
+
+		widgets := resourceList.APIResources[1]
+		require.Equal(t, "widgets", widgets.Name)
+		require.False(t, widgets.Namespaced)
+		require.Equal(t, []string{"all"}, widgets.Categories)
+	})
+
+	t.Run("APIResourceLister built-in", func(t *testing.T) {
+		obj, err := h.APIResourceLister(Vars{"group": "apiextensions.k8s.io", "version": "v1"}, nil)
+		require.NoError(t, err)
+		resourceList, ok := obj.(*metav1.APIResourceList)
+		require.True(t, ok)
+		require.Len(t, resourceList.APIResources, 1)
+		require.Equal(t, "customresourcedefinitions", resourceList.APIResources[0].Name)
+	})
+
+	t.Run("APIResourceLister version not served", func(t *testing.T) {
+		_, err := h.APIResourceLister(Vars{"group": "stable.example.com", "version": "v1alpha1"}, nil)
+		require.Equal(t, ResourceNotFoundErr, err)
+	})
+}
@@ -0,0 +1,149 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AcceptVar key in Vars holding the request's accept header.
+const AcceptVar = "Accept"
+
+const (
+	// jsonMediaType media type for JSON documents.
+	jsonMediaType = "application/json"
+	// yamlMediaType media type for YAML documents.
+	yamlMediaType = "application/yaml"
+	// tableMediaType media type for metav1.Table documents, encoded as JSON.
+	tableMediaType = "application/json;as=Table;g=meta.k8s.io;v=v1"
+)
+
+// mediaRange a single entry of the accept header, media type and its parameters.
+type mediaRange struct {
+	mediaType string            // media type, as in "application/json"
+	params    map[string]string // parameters, as in "as=Table"
+}
+
+// isTable checks if the media range asks for metav1.Table documents.
+func (m mediaRange) isTable() bool {
+	return m.mediaType == jsonMediaType &&
+		m.params["as"] == "Table" &&
+		m.params["g"] == metav1.GroupName &&
+		m.params["v"] == metav1.SchemeGroupVersion.Version
+}
+
+// responseType returns the media type answering the media range with informed object, or empty
+// when it can't be served.
+func (m mediaRange) responseType(obj runtime.Object) string {
+	_, table := obj.(*metav1.Table)
+	if m.isTable() {
+		if table {
+			return tableMediaType
+		}
+		return ""
+	}
+	// other object representations, as in "as=PartialObjectMetadata", are not supported
+	if _, found := m.params["as"]; found {
+		return ""
+	}
+	switch m.mediaType {
+	case "*/*", "application/*", jsonMediaType:
+		return jsonMediaType
+	case yamlMediaType:
+		return yamlMediaType
+	}
+	return ""
+}
+
+// mediaType extracts the media type from a content-type header, ignoring its parameters.
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return parsed
+}
+
+// acceptedRanges parses the accept header into media ranges, in the order informed. Any media type
+// is accepted when the header is empty, and entries which can't be parsed are skipped.
+func acceptedRanges(accept string) []mediaRange {
+	if strings.TrimSpace(accept) == "" {
+		return []mediaRange{{mediaType: "*/*"}}
+	}
+	ranges := []mediaRange{}
+	for _, entry := range strings.Split(accept, ",") {
+		parsed, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		delete(params, "q")
+		ranges = append(ranges, mediaRange{mediaType: parsed, params: params})
+	}
+	return ranges
+}
+
+// newNotAcceptable returns a status error for an accept header the server can't satisfy.
+func newNotAcceptable(accept string) *apierrors.StatusError {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   http.StatusNotAcceptable,
+		Reason: metav1.StatusReasonNotAcceptable,
+		Message: fmt.Sprintf("only the following media types are accepted: %s",
+			strings.Join([]string{jsonMediaType, yamlMediaType, tableMediaType}, ", ")),
+	}}
+}
+
+// acceptable checks if the accept header informed in vars asks for a media type the server is able
+// to produce, returning a not-acceptable status error otherwise.
+func acceptable(vars Vars) error {
+	for _, accepted := range acceptedRanges(vars[AcceptVar]) {
+		if accepted.isTable() || accepted.responseType(nil) != "" {
+			return nil
+		}
+	}
+	return newNotAcceptable(vars[AcceptVar])
+}
+
+// wantsTable checks if the preferred media type informed in vars is metav1.Table.
+func wantsTable(vars Vars) bool {
+	for _, accepted := range acceptedRanges(vars[AcceptVar]) {
+		if accepted.isTable() {
+			return true
+		}
+		if accepted.responseType(nil) != "" {
+			return false
+		}
+	}
+	return false
+}
+
+// negotiate returns the media type answering the request with the informed object, the first media
+// range able to represent the object is employed. It returns a not-acceptable status error when
+// none is able to.
+func negotiate(vars Vars, obj runtime.Object) (string, error) {
+	for _, accepted := range acceptedRanges(vars[AcceptVar]) {
+		if responseType := accepted.responseType(obj); responseType != "" {
+			return responseType, nil
+		}
+	}
+	return "", newNotAcceptable(vars[AcceptVar])
+}
+
+// encode serializes the object in the media type informed.
+func encode(responseType string, obj runtime.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	if responseType == yamlMediaType {
+		return yaml.JSONToYAML(data)
+	}
+	return data, nil
+}
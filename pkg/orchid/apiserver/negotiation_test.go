@@ -0,0 +1,176 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+// printerColumnsCRDMock returns the crontab CRD declaring additional printer columns.
+func printerColumnsCRDMock(t *testing.T) unstructured.Unstructured {
+	crd := util.LoadUnstructured(ValidCRDAsset)
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	require.NoError(t, err)
+	version := versions[0].(map[string]interface{})
+	version["additionalPrinterColumns"] = []interface{}{
+		map[string]interface{}{"name": "Spec", "type": "string", "jsonPath": ".spec.cronSpec"},
+		map[string]interface{}{"name": "Replicas", "type": "integer", "jsonPath": ".spec.replicas"},
+	}
+	require.NoError(t, unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+	return *crd
+}
+
+func TestNegotiation_acceptedRanges(t *testing.T) {
+	kubectl := "application/json;as=Table;v=v1;g=meta.k8s.io,application/json;as=Table;v=v1beta1;" +
+		"g=meta.k8s.io,application/json"
+
+	tests := []struct {
+		name        string
+		accept      string
+		wantTable   bool
+		wantAllowed bool
+	}{
+		{name: "empty", accept: "", wantAllowed: true},
+		{name: "json", accept: "application/json", wantAllowed: true},
+		{name: "yaml", accept: "application/yaml", wantAllowed: true},
+		{name: "wildcard", accept: "*/*", wantAllowed: true},
+		{name: "kubectl get", accept: kubectl, wantTable: true, wantAllowed: true},
+		{name: "table only", accept: tableMediaType, wantTable: true, wantAllowed: true},
+		{name: "table v1beta1 only", accept: "application/json;as=Table;v=v1beta1;g=meta.k8s.io"},
+		{name: "protobuf", accept: "application/vnd.kubernetes.protobuf"},
+		{name: "html", accept: "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := Vars{AcceptVar: tt.accept}
+			require.Equal(t, tt.wantTable, wantsTable(vars))
+			require.Equal(t, tt.wantAllowed, acceptable(vars) == nil)
+		})
+	}
+}
+
+func TestTable_jsonPathValue(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"image": "image:latest"}},
+		},
+	}
+
+	value, found := jsonPathValue(obj, ".spec.containers[0].image")
+	require.True(t, found)
+	require.Equal(t, "image:latest", value)
+
+	_, found = jsonPathValue(obj, ".spec.containers[1].image")
+	require.False(t, found)
+
+	_, found = jsonPathValue(obj, ".spec.replicas")
+	require.False(t, found)
+}
+
+func TestNegotiation_Router(t *testing.T) {
+	logger := klogr.New()
+	crontab := util.LoadUnstructured(ValidCRAsset)
+	repo := &TestResourcePostHandlerRepository{
+		CRDs:       []unstructured.Unstructured{printerColumnsCRDMock(t)},
+		Objects:    []unstructured.Unstructured{*crontab},
+		ReadObject: crontab,
+	}
+	h := &APIResourceHandler{
+		logger:    logger,
+		repo:      repo,
+		validator: validation.NewRepositoryValidator(repo),
+	}
+	router := mux.NewRouter()
+	h.Register(router)
+
+	objectPath := "/apis/stable.example.com/v1/namespaces/example/crontabs/example"
+	listPath := "/apis/stable.example.com/v1/namespaces/example/crontabs"
+
+	serve := func(method, path, contentType, accept string, body []byte) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set(ContentTypeVar, contentType)
+		req.Header.Set(AcceptVar, accept)
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("json", func(t *testing.T) {
+		recorder := serve(http.MethodGet, objectPath, "", "application/json", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, jsonMediaType, recorder.Header().Get(ContentTypeVar))
+
+		u := &unstructured.Unstructured{}
+		require.NoError(t, u.UnmarshalJSON(recorder.Body.Bytes()))
+		require.Equal(t, "example", u.GetName())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		recorder := serve(http.MethodGet, objectPath, "", "application/yaml", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, yamlMediaType, recorder.Header().Get(ContentTypeVar))
+
+		obj := map[string]interface{}{}
+		require.NoError(t, yaml.Unmarshal(recorder.Body.Bytes(), &obj))
+		require.Equal(t, "CronTab", obj["kind"])
+	})
+
+	t.Run("table", func(t *testing.T) {
+		for _, path := range []string{objectPath, listPath} {
+			recorder := serve(http.MethodGet, path, "", tableMediaType, nil)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Equal(t, tableMediaType, recorder.Header().Get(ContentTypeVar))
+
+			table := &metav1.Table{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), table))
+			require.Equal(t, "Table", table.Kind)
+			names := []string{}
+			for _, column := range table.ColumnDefinitions {
+				names = append(names, column.Name)
+			}
+			require.Equal(t, []string{"Name", "Spec", "Replicas"}, names)
+			require.Len(t, table.Rows, 1)
+			require.Equal(t, []interface{}{"example", "* * * * *", float64(1)}, table.Rows[0].Cells)
+			require.NotEmpty(t, table.Rows[0].Object.Raw)
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		recorder := serve(http.MethodGet, objectPath, "", "text/html", nil)
+		require.Equal(t, http.StatusNotAcceptable, recorder.Code)
+
+		status := &metav1.Status{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), status))
+		require.Equal(t, metav1.StatusReasonNotAcceptable, status.Reason)
+	})
+
+	t.Run("table not available", func(t *testing.T) {
+		recorder := serve(http.MethodGet, "/apis/stable.example.com/v1", "", tableMediaType, nil)
+		require.Equal(t, http.StatusNotAcceptable, recorder.Code)
+	})
+
+	t.Run("json body", func(t *testing.T) {
+		body, err := crontab.MarshalJSON()
+		require.NoError(t, err)
+		recorder := serve(http.MethodPut, objectPath, "application/json", "", body)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("unsupported body", func(t *testing.T) {
+		body := util.ReadAsset(ValidCRAsset)
+		recorder := serve(http.MethodPut, objectPath, "application/xml", "", body)
+		require.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+	})
+}
@@ -0,0 +1,204 @@
+package apiserver
+
+import (
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/isutton/orchid/pkg/orchid/openapi"
+	"github.com/isutton/orchid/pkg/orchid/repository"
+)
+
+// openAPIDocument an OpenAPI document encoded as JSON, together with its hash.
+type openAPIDocument struct {
+	data []byte // document encoded as JSON
+	hash string // hash of the encoded document
+}
+
+// newOpenAPIDocument encodes the document informed as JSON.
+func newOpenAPIDocument(doc interface{}) (*openAPIDocument, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &openAPIDocument{data: data, hash: fmt.Sprintf("%X", sha512.Sum512(data))}, nil
+}
+
+// openAPICache keeps the last OpenAPI documents generated, identified by the CRDs they describe.
+type openAPICache struct {
+	lock        sync.Mutex                  // guards the attributes below
+	fingerprint string                      // names and resource versions of the CRDs described
+	v2          *openAPIDocument            // Swagger 2.0 document
+	v3          map[string]*openAPIDocument // OpenAPI v3 documents per group-version path
+}
+
+// storedCRDs returns the CRDs stored in the default namespace, together with a fingerprint
+// changing whenever a CRD is created, updated or deleted. It can return errors on listing and
+// converting CRDs.
+func (h *APIResourceHandler) storedCRDs() ([]*extv1.CustomResourceDefinition, string, error) {
+	list, err := h.repo.List(repository.DefaultNamespace, repository.CRDGVK, metav1.ListOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	crds := make([]*extv1.CustomResourceDefinition, 0, len(list.Items))
+	entries := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		crd, err := repository.ExtractCRD(item.Object)
+		if err != nil {
+			return nil, "", err
+		}
+		crds = append(crds, crd)
+		entries = append(entries, crd.GetName()+"@"+crd.GetResourceVersion())
+	}
+	sort.Strings(entries)
+	return crds, strings.Join(entries, ","), nil
+}
+
+// openAPIDocuments returns the Swagger 2.0 document and the OpenAPI v3 documents per group-version
+// path, describing built-in and CRD resources. Documents are generated again only when stored CRDs
+// have changed since last time. It can return errors on listing CRDs and generating documents.
+func (h *APIResourceHandler) openAPIDocuments() (
+	*openAPIDocument,
+	map[string]*openAPIDocument,
+	error,
+) {
+	crds, fingerprint, err := h.storedCRDs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.openAPI.lock.Lock()
+	defer h.openAPI.lock.Unlock()
+	if h.openAPI.v2 != nil && h.openAPI.fingerprint == fingerprint {
+		return h.openAPI.v2, h.openAPI.v3, nil
+	}
+
+	resources := append(openapi.BuiltinResources(), openapi.CRDResources(crds)...)
+	swagger, err := openapi.NewV2(resources)
+	if err != nil {
+		return nil, nil, err
+	}
+	v2, err := newOpenAPIDocument(swagger)
+	if err != nil {
+		return nil, nil, err
+	}
+	docs, err := openapi.NewV3(resources)
+	if err != nil {
+		return nil, nil, err
+	}
+	v3 := make(map[string]*openAPIDocument, len(docs))
+	for gv, doc := range docs {
+		if v3[openapi.V3Path(gv)], err = newOpenAPIDocument(doc); err != nil {
+			return nil, nil, err
+		}
+	}
+	h.openAPI.fingerprint, h.openAPI.v2, h.openAPI.v3 = fingerprint, v2, v3
+	return v2, v3, nil
+}
+
+// writeOpenAPIDocument writes the document either as JSON or YAML according to the accept header.
+// The document hash is informed as ETag, answering not-modified when it matches If-None-Match, and
+// documents requested by hash are cached by clients for good.
+func writeOpenAPIDocument(w http.ResponseWriter, r *http.Request, doc *openAPIDocument) {
+	vars := requestVars(r)
+	responseType := ""
+	for _, accepted := range acceptedRanges(vars[AcceptVar]) {
+		if responseType = accepted.responseType(nil); responseType != "" {
+			break
+		}
+	}
+	if responseType == "" {
+		writeError(w, vars, newNotAcceptable(vars[AcceptVar]))
+		return
+	}
+
+	etag := strconv.Quote(doc.hash)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", AcceptVar)
+	if vars["hash"] == doc.hash {
+		w.Header().Set("Cache-Control", "public, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache, private")
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data := doc.data
+	if responseType == yamlMediaType {
+		var err error
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			writeError(w, vars, err)
+			return
+		}
+	}
+	w.Header().Add(ContentTypeVar, responseType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// OpenAPIV2Handler writes the Swagger 2.0 document, used by kubectl explain and client-side
+// validation.
+func (h *APIResourceHandler) OpenAPIV2Handler(w http.ResponseWriter, r *http.Request) {
+	v2, _, err := h.openAPIDocuments()
+	if err != nil {
+		writeError(w, requestVars(r), err)
+		return
+	}
+	writeOpenAPIDocument(w, r, v2)
+}
+
+// OpenAPIV3Handler writes the OpenAPI v3 index, listing the document of each group-version
+// together with its hash.
+func (h *APIResourceHandler) OpenAPIV3Handler(w http.ResponseWriter, r *http.Request) {
+	_, v3, err := h.openAPIDocuments()
+	if err != nil {
+		writeError(w, requestVars(r), err)
+		return
+	}
+	index := map[string]map[string]map[string]string{"paths": {}}
+	for path, doc := range v3 {
+		index["paths"][path] = map[string]string{
+			"serverRelativeURL": fmt.Sprintf("/openapi/v3/%s?hash=%s", path, doc.hash),
+		}
+	}
+	doc, err := newOpenAPIDocument(index)
+	if err != nil {
+		writeError(w, requestVars(r), err)
+		return
+	}
+	writeOpenAPIDocument(w, r, doc)
+}
+
+// OpenAPIV3GroupVersionHandler writes the OpenAPI v3 document of the group-version informed in the
+// path. Requests carrying an outdated hash are redirected to the current document.
+func (h *APIResourceHandler) OpenAPIV3GroupVersionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := requestVars(r)
+	_, v3, err := h.openAPIDocuments()
+	if err != nil {
+		writeError(w, vars, err)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/openapi/v3/")
+	doc, found := v3[path]
+	if !found {
+		writeError(w, vars, ResourceNotFoundErr)
+		return
+	}
+	if hash := vars["hash"]; hash != "" && hash != doc.hash {
+		current := fmt.Sprintf("/openapi/v3/%s?hash=%s", path, doc.hash)
+		http.Redirect(w, r, current, http.StatusMovedPermanently)
+		return
+	}
+	writeOpenAPIDocument(w, r, doc)
+}
@@ -0,0 +1,152 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/openapi"
+	"github.com/isutton/orchid/test/util"
+)
+
+func TestOpenAPI_OpenAPIV2Handler(t *testing.T) {
+	crd := util.LoadUnstructured(ValidCRDAsset)
+	crd.SetResourceVersion("1")
+	repo := &TestResourcePostHandlerRepository{CRDs: []unstructured.Unstructured{*crd}}
+	h := &APIResourceHandler{logger: klogr.New(), repo: repo}
+	router := mux.NewRouter()
+	h.Register(router)
+
+	get := func(t *testing.T, accept string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/openapi/v2", nil)
+		req.Header.Set(AcceptVar, accept)
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	crontab := "com.example.stable.v1.CronTab"
+
+	t.Run("document", func(t *testing.T) {
+		recorder := get(t, jsonMediaType)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, jsonMediaType, recorder.Header().Get(ContentTypeVar))
+
+		swagger := &spec.Swagger{}
+		require.NoError(t, swagger.UnmarshalJSON(recorder.Body.Bytes()))
+		require.Contains(t, swagger.Definitions, crontab)
+		require.Contains(t, swagger.Definitions[crontab].Extensions, openapi.GVKExtension)
+		require.Contains(t, swagger.Paths.Paths,
+			"/apis/stable.example.com/v1/namespaces/{namespace}/crontabs/{name}")
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		cached := h.openAPI.v2
+		require.NotNil(t, cached)
+		require.Equal(t, http.StatusOK, get(t, "").Code)
+		require.Same(t, cached, h.openAPI.v2)
+	})
+
+	t.Run("invalidated on CRD changes", func(t *testing.T) {
+		changed := crd.DeepCopy()
+		changed.SetResourceVersion("2")
+		err := unstructured.SetNestedField(changed.Object, "ScheduledJob", "spec", "names", "kind")
+		require.NoError(t, err)
+		repo.CRDs = []unstructured.Unstructured{*changed}
+
+		recorder := get(t, jsonMediaType)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		swagger := &spec.Swagger{}
+		require.NoError(t, swagger.UnmarshalJSON(recorder.Body.Bytes()))
+		require.NotContains(t, swagger.Definitions, crontab)
+		require.Contains(t, swagger.Definitions, "com.example.stable.v1.ScheduledJob")
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		recorder := get(t, yamlMediaType)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, yamlMediaType, recorder.Header().Get(ContentTypeVar))
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		etag := get(t, jsonMediaType).Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/openapi/v2", nil)
+		req.Header.Set("If-None-Match", etag)
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusNotModified, recorder.Code)
+		require.Empty(t, recorder.Body.Bytes())
+	})
+
+	t.Run("protobuf is not acceptable", func(t *testing.T) {
+		recorder := get(t, "application/com.github.proto-openapi.spec.v2@v1.0+protobuf")
+		require.Equal(t, http.StatusNotAcceptable, recorder.Code)
+	})
+}
+
+func TestOpenAPI_OpenAPIV3Handler(t *testing.T) {
+	crd := util.LoadUnstructured(ValidCRDAsset)
+	crd.SetResourceVersion("1")
+	repo := &TestResourcePostHandlerRepository{CRDs: []unstructured.Unstructured{*crd}}
+	h := &APIResourceHandler{logger: klogr.New(), repo: repo}
+	router := mux.NewRouter()
+	h.Register(router)
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	recorder := get(t, "/openapi/v3")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	index := map[string]map[string]map[string]string{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &index))
+	require.Contains(t, index["paths"], "apis/apiextensions.k8s.io/v1")
+	require.Contains(t, index["paths"], "apis/stable.example.com/v1")
+	url := index["paths"]["apis/stable.example.com/v1"]["serverRelativeURL"]
+	require.Contains(t, url, "?hash=")
+
+	t.Run("group-version", func(t *testing.T) {
+		recorder := get(t, url)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "public, immutable", recorder.Header().Get("Cache-Control"))
+		require.NotEmpty(t, recorder.Header().Get("ETag"))
+
+		doc := &openapi.V3Document{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), doc))
+		require.Equal(t, "3.0.0", doc.OpenAPI)
+		require.Contains(t, doc.Components.Schemas, "com.example.stable.v1.CronTab")
+		require.NotContains(t, doc.Components.Schemas,
+			"io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1.CustomResourceDefinition")
+		item := doc.Paths["/apis/stable.example.com/v1/namespaces/{namespace}/crontabs/{name}"]
+		require.NotNil(t, item)
+		require.Equal(t, "get", item.Get.Action)
+	})
+
+	t.Run("without hash", func(t *testing.T) {
+		recorder := get(t, "/openapi/v3/apis/stable.example.com/v1")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "no-cache, private", recorder.Header().Get("Cache-Control"))
+	})
+
+	t.Run("outdated hash", func(t *testing.T) {
+		recorder := get(t, "/openapi/v3/apis/stable.example.com/v1?hash=outdated")
+		require.Equal(t, http.StatusMovedPermanently, recorder.Code)
+		require.Equal(t, url, recorder.Header().Get("Location"))
+	})
+
+	t.Run("unknown group-version", func(t *testing.T) {
+		recorder := get(t, "/openapi/v3/apis/stable.example.com/v2")
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
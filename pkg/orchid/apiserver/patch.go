@@ -0,0 +1,50 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newUnsupportedMediaType returns a status error for a content-type the server can't handle.
+func newUnsupportedMediaType(contentType string) *apierrors.StatusError {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusUnsupportedMediaType,
+		Reason:  metav1.StatusReasonUnsupportedMediaType,
+		Message: fmt.Sprintf("the body of the request was in an unknown format: '%s'", contentType),
+	}}
+}
+
+// patchType extracts the patch type from a content-type header, ignoring its parameters.
+func patchType(contentType string) types.PatchType {
+	return types.PatchType(mediaType(contentType))
+}
+
+// applyPatch applies the patch on original JSON document, according to the content-type informed.
+// It returns an unsupported media-type status error when the content-type is not a known patch
+// type, and bad-request when the patch can't be applied.
+func applyPatch(contentType string, original, patch []byte) ([]byte, error) {
+	var patched []byte
+	var err error
+	switch patchType(contentType) {
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case types.JSONPatchType:
+		var decoded jsonpatch.Patch
+		if decoded, err = jsonpatch.DecodePatch(patch); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		patched, err = decoded.Apply(original)
+	default:
+		return nil, newUnsupportedMediaType(contentType)
+	}
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return patched, nil
+}
@@ -1,16 +1,22 @@
 package apiserver
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/ghodss/yaml"
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/isutton/orchid/pkg/orchid/fieldmanager"
 	"github.com/isutton/orchid/pkg/orchid/repository"
 	orchid "github.com/isutton/orchid/pkg/orchid/runtime"
 	"github.com/isutton/orchid/pkg/orchid/validation"
@@ -21,131 +27,211 @@ type APIResourceHandler struct {
 	logger    logr.Logger                   // logger instance
 	repo      repository.ResourceRepository // resource repository
 	validator validation.Validator          // resource validation
+	openAPI   openAPICache                  // OpenAPI documents generated from stored CRDs
 }
 
 var (
-	exampleAPIResource = metav1.APIResource{
-		Group:        "examples.orchid.io",
-		Kind:         "Example",
-		Name:         "examples",
-		ShortNames:   []string{"ex"},
-		SingularName: "example",
-		Verbs:        []string{"get", "create"},
-		Version:      "v1alpha1",
-	}
-
-	examplesGroup        = exampleAPIResource.Group
-	examplesVersion      = exampleAPIResource.Version
-	examplesGroupVersion = examplesGroup + "/" + examplesVersion
-
 	// TODO: this definition should be transformed into a JsonSchema at some point, and automatically
 	//       added to the engine at startup time
 	crdAPIResource = metav1.APIResource{
 		Group:        "apiextensions.k8s.io",
 		Kind:         "CustomResourceDefinition",
 		Name:         "customresourcedefinitions",
-		ShortNames:   []string{"crd"},
+		Namespaced:   false,
+		ShortNames:   []string{"crd", "crds"},
 		SingularName: "customresourcedefinition",
-		Verbs:        []string{"get", "create"},
+		Verbs:        servedVerbs,
 		Version:      "v1",
 	}
 
-	crdGroup        = crdAPIResource.Group
-	crdVersion      = crdAPIResource.Version
-	crdGroupVersion = crdGroup + "/" + crdVersion
+	crdGroup   = crdAPIResource.Group
+	crdVersion = crdAPIResource.Version
 )
 
-// ObjectLister returns a list of objects.
-func (h *APIResourceHandler) ObjectLister(vars Vars, body []byte) (runtime.Object, error) {
-	apiVersion, err := vars.GetAPIVersion()
+// findCRD returns the first stored CRD where match is true, or nil when none matches. It can return
+// errors on listing and converting CRDs.
+func (h *APIResourceHandler) findCRD(
+	match func(crd *extv1.CustomResourceDefinition) bool,
+) (*extv1.CustomResourceDefinition, error) {
+	crds, err := h.repo.List(repository.DefaultNamespace, repository.CRDGVK, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range crds.Items {
+		crd, err := repository.ExtractCRD(item.Object)
+		if err != nil {
+			return nil, err
+		}
+		if match(crd) {
+			return crd, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveCRD finds the GVK served under the group, version and resource plural informed in vars,
+// together with the CRD describing it, by inspecting the served versions of the CRDs stored in the
+// repository. The CRD is nil for CRD objects themselves. It can return ResourceNotFoundErr when no
+// CRD matches, or when the route does not match the resource scope, and errors from listing CRDs.
+func (h *APIResourceHandler) resolveCRD(
+	vars Vars,
+) (schema.GroupVersionKind, *extv1.CustomResourceDefinition, error) {
+	group, version, resource := vars["group"], vars["version"], vars["resource"]
+	if group == crdGroup && version == crdVersion && resource == crdAPIResource.Name {
+		if err := matchScope(vars, nil); err != nil {
+			return schema.GroupVersionKind{}, nil, err
+		}
+		return repository.CRDGVK, nil, nil
+	}
+
+	crd, err := h.findCRD(func(crd *extv1.CustomResourceDefinition) bool {
+		if crd.Spec.Group != group || crd.Spec.Names.Plural != resource {
+			return false
+		}
+		for _, crdVersion := range crd.Spec.Versions {
+			if crdVersion.Name == version && crdVersion.Served {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return schema.GroupVersionKind{}, nil, err
+	}
+	if crd == nil {
+		return schema.GroupVersionKind{}, nil, ResourceNotFoundErr
+	}
+	if err = matchScope(vars, crd); err != nil {
+		return schema.GroupVersionKind{}, nil, err
+	}
+	gvk := schema.GroupVersionKind{Group: group, Version: version, Kind: crd.Spec.Names.Kind}
+	return gvk, crd, nil
+}
+
+// resolveGVK finds the GVK served under the group, version and resource plural informed in vars.
+// It can return ResourceNotFoundErr when no CRD matches, and errors from listing CRDs.
+func (h *APIResourceHandler) resolveGVK(vars Vars) (schema.GroupVersionKind, error) {
+	gvk, _, err := h.resolveCRD(vars)
+	return gvk, err
+}
+
+// ObjectGetHandler returns a single object, identified by namespace and name. The object is
+// returned as a table when requested.
+func (h *APIResourceHandler) ObjectGetHandler(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, crd, err := h.resolveCRD(vars)
 	if err != nil {
 		return nil, err
 	}
-	m := make(map[string]interface{})
-	u := &unstructured.Unstructured{Object: m}
-	u.SetAPIVersion(apiVersion)
-	u.SetKind(exampleAPIResource.Kind + "List")
-	items := make([]interface{}, 0)
-	_ = unstructured.SetNestedSlice(m, items, "items")
+	u, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	if wantsTable(vars) {
+		return tableForObject(crd, gvk.Version, u)
+	}
 	return u, nil
 }
 
-// APIResourceLister lists API resources.
-func (h *APIResourceHandler) APIResourceLister(vars Vars, body []byte) (runtime.Object, error) {
-	return &metav1.APIResourceList{
-		// TODO: add GroupVersion argument
-		GroupVersion: examplesGroupVersion,
-		APIResources: []metav1.APIResource{
-			exampleAPIResource,
-			crdAPIResource,
-		},
-	}, nil
-}
-
-// APIGroupLister lists API groups.
-func (h *APIResourceHandler) APIGroupLister(vars Vars, body []byte) (runtime.Object, error) {
-	crdAPIGroups := h.CRDAPIGroups()
-	groups := []metav1.APIGroup{
-		{
-			Name: examplesGroup,
-			PreferredVersion: metav1.GroupVersionForDiscovery{
-				GroupVersion: examplesGroupVersion,
-				Version:      examplesVersion,
-			},
-			Versions: []metav1.GroupVersionForDiscovery{
-				{
-					GroupVersion: examplesGroupVersion,
-					Version:      examplesVersion,
-				},
-			},
-		},
-		{
-			Name: crdGroup,
-			PreferredVersion: metav1.GroupVersionForDiscovery{
-				GroupVersion: crdGroupVersion,
-				Version:      crdVersion,
-			},
-			Versions: []metav1.GroupVersionForDiscovery{
-				{
-					GroupVersion: crdGroupVersion,
-					Version:      crdVersion,
-				},
-			},
-		},
-	}
-	return &metav1.APIGroupList{
-		Groups: append(groups, crdAPIGroups...),
-	}, nil
-}
-
-func (h *APIResourceHandler) OpenAPIHandler(vars Vars, body []byte) (runtime.Object, error) {
-	return nil, nil
+// ObjectLister returns a list of objects, either from the namespace informed in vars or from all
+// namespaces when not informed. The list is returned as a table when requested.
+func (h *APIResourceHandler) ObjectLister(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, crd, err := h.resolveCRD(vars)
+	if err != nil {
+		return nil, err
+	}
+	list, err := h.repo.List(vars["namespace"], gvk, vars.GetListOptions())
+	if err != nil {
+		return nil, err
+	}
+	if wantsTable(vars) {
+		return tableForList(crd, gvk.Version, list)
+	}
+	list.SetAPIVersion(gvk.GroupVersion().String())
+	list.SetKind(gvk.Kind + "List")
+	return list, nil
 }
 
 var BodyEmptyErr = errors.New("body is empty")
 
-// ResourcePostHandler handles the create resource action.
-func (h *APIResourceHandler) ResourcePostHandler(vars Vars, body []byte) (runtime.Object, error) {
+// decode deserializes body, either JSON or YAML according to the content-type informed, into an
+// unstructured object, making sure it can also be represented as an Orchid object. YAML is assumed
+// when content-type is not informed. It can return BodyEmptyErr, unsupported media-type status
+// error for other content-types, and bad-request status error when body can't be deserialized.
+func decode(contentType string, body []byte) (*unstructured.Unstructured, error) {
 	// do not proceed if body is empty
 	if len(body) == 0 {
 		return nil, BodyEmptyErr
 	}
 
+	// numbers are kept as int64 or float64 as JSON dictates, thus YAML is converted to JSON first
+	var jsonBody []byte
+	var err error
+	switch mediaType(contentType) {
+	case jsonMediaType:
+		jsonBody = body
+	case "", yamlMediaType, string(types.ApplyPatchType):
+		if jsonBody, err = yaml.YAMLToJSON(body); err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+	default:
+		return nil, newUnsupportedMediaType(contentType)
+	}
+
 	// deserialize the body to an Orchid object to validate the object
 	obj := orchid.NewObject()
-	err := yaml.Unmarshal(body, obj)
+	if err = json.Unmarshal(jsonBody, obj); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	// deserialize the body to an unstructured object as well, since we'll be using it to feed the
+	// Repository to create the resource
+	u := &unstructured.Unstructured{}
+	if err = u.UnmarshalJSON(jsonBody); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return u, nil
+}
+
+// createCRD finds the CRD describing the object being created, making sure the object is of the
+// resource informed in vars, when informed. The CRD is nil for CRD objects themselves. It returns a
+// bad-request status error when the object does not match the resource, and errors from resolving
+// the resource.
+func (h *APIResourceHandler) createCRD(
+	vars Vars,
+	u *unstructured.Unstructured,
+) (*extv1.CustomResourceDefinition, error) {
+	gvk := u.GroupVersionKind()
+	if vars["resource"] == "" {
+		return h.findCRD(func(crd *extv1.CustomResourceDefinition) bool {
+			return gvk != repository.CRDGVK &&
+				crd.Spec.Group == gvk.Group && crd.Spec.Names.Kind == gvk.Kind
+		})
+	}
+	routeGVK, crd, err := h.resolveCRD(vars)
 	if err != nil {
 		return nil, err
 	}
+	if gvk != routeGVK {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf(
+			"object kind '%s' does not match the resource kind '%s'", gvk, routeGVK))
+	}
+	return crd, nil
+}
 
-	// deserialize the body to a map[string]interface{} as well, since we'll be using it to feed the
-	// Repository to create the resource
-	uObj := map[string]interface{}{}
-	err = yaml.Unmarshal(body, &uObj)
+// ResourcePostHandler handles the create resource action. The object namespace is taken from the
+// route when informed, and removed for cluster scoped resources.
+func (h *APIResourceHandler) ResourcePostHandler(vars Vars, body []byte) (runtime.Object, error) {
+	u, err := decode(vars[ContentTypeVar], body)
+	if err != nil {
+		return nil, err
+	}
+	crd, err := h.createCRD(vars, u)
 	if err != nil {
 		return nil, err
 	}
-	u := &unstructured.Unstructured{Object: uObj}
+	if err = matchNamespace(vars, crd, u); err != nil {
+		return nil, err
+	}
 
 	// validate body against its schema
 	err = h.validator.Validate(u)
@@ -153,6 +239,12 @@ func (h *APIResourceHandler) ResourcePostHandler(vars Vars, body []byte) (runtim
 		return nil, err
 	}
 
+	// all fields informed are owned by the creator
+	if err = fieldmanager.Update(nil, u, vars.GetFieldManager()); err != nil {
+		return nil, err
+	}
+	prepareCreate(crd, u)
+
 	err = h.repo.Create(u)
 	if err != nil {
 		return nil, err
@@ -168,18 +260,237 @@ func (h *APIResourceHandler) ResourcePostHandler(vars Vars, body []byte) (runtim
 	return createdObj, err
 }
 
+// matchRoute makes sure the object informed is the one addressed by the route, comparing its GVK,
+// namespace and name with vars. The namespace is filled in when not informed, and removed for
+// routes without namespace, addressing cluster scoped objects. It returns a bad-request status
+// error when they differ.
+func matchRoute(vars Vars, gvk schema.GroupVersionKind, u *unstructured.Unstructured) error {
+	if u.GroupVersionKind() != gvk {
+		return apierrors.NewBadRequest(fmt.Sprintf(
+			"object kind '%s' does not match the resource kind '%s'", u.GroupVersionKind(), gvk))
+	}
+	namespacedName := vars.GetNamespacedName()
+	if u.GetName() != namespacedName.Name {
+		return apierrors.NewBadRequest(fmt.Sprintf(
+			"object name '%s' does not match the name in URL '%s'",
+			u.GetName(), namespacedName.Name))
+	}
+	if namespacedName.Namespace == metav1.NamespaceNone || u.GetNamespace() == metav1.NamespaceNone {
+		u.SetNamespace(namespacedName.Namespace)
+	}
+	if u.GetNamespace() != namespacedName.Namespace {
+		return apierrors.NewBadRequest(fmt.Sprintf(
+			"object namespace '%s' does not match the namespace in URL '%s'",
+			u.GetNamespace(), namespacedName.Namespace))
+	}
+	return nil
+}
+
+// ResourcePutHandler handles the update resource action, replacing an existing object.
+func (h *APIResourceHandler) ResourcePutHandler(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, crd, err := h.resolveCRD(vars)
+	if err != nil {
+		return nil, err
+	}
+	u, err := decode(vars[ContentTypeVar], body)
+	if err != nil {
+		return nil, err
+	}
+	if err = matchRoute(vars, gvk, u); err != nil {
+		return nil, err
+	}
+
+	// validate body against its schema
+	if err = h.validator.Validate(u); err != nil {
+		return nil, err
+	}
+
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	if err = prepareUpdate(crd, current, u); err != nil {
+		return nil, err
+	}
+	if err = fieldmanager.Update(current, u, vars.GetFieldManager()); err != nil {
+		return nil, err
+	}
+
+	if err = h.repo.Update(u); err != nil {
+		return nil, err
+	}
+	return h.repo.Read(gvk, vars.GetNamespacedName())
+}
+
+// resourceApply handles server-side apply, merging the applied configuration on the current object
+// on behalf of the field manager informed. The object is created when it does not exist yet.
+func (h *APIResourceHandler) resourceApply(
+	vars Vars,
+	gvk schema.GroupVersionKind,
+	crd *extv1.CustomResourceDefinition,
+	body []byte,
+) (runtime.Object, error) {
+	manager := vars["fieldManager"]
+	if manager == "" {
+		return nil, apierrors.NewBadRequest("fieldManager is required for apply requests")
+	}
+	applied, err := decode(vars[ContentTypeVar], body)
+	if err != nil {
+		return nil, err
+	}
+	if err = matchRoute(vars, gvk, applied); err != nil {
+		return nil, err
+	}
+
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	notFound := errors.Is(err, repository.ObjectNotFoundErr)
+	if err != nil && !notFound {
+		return nil, err
+	}
+	u, err := fieldmanager.Apply(current, applied, manager, vars["force"] == "true")
+	if err != nil {
+		return nil, err
+	}
+
+	// validate resulting object against its schema
+	if err = h.validator.Validate(u); err != nil {
+		return nil, err
+	}
+
+	if notFound {
+		prepareCreate(crd, u)
+		err = h.repo.Create(u)
+	} else if err = prepareUpdate(crd, current, u); err == nil {
+		err = h.repo.Update(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.repo.Read(gvk, vars.GetNamespacedName())
+}
+
+// ResourcePatchHandler handles the patch resource action, applying either a JSON merge-patch, a
+// JSON patch or a server-side apply, according to the request content-type, on the current object
+// and storing the result.
+func (h *APIResourceHandler) ResourcePatchHandler(vars Vars, body []byte) (runtime.Object, error) {
+	if len(body) == 0 {
+		return nil, BodyEmptyErr
+	}
+	gvk, crd, err := h.resolveCRD(vars)
+	if err != nil {
+		return nil, err
+	}
+	if patchType(vars[ContentTypeVar]) == types.ApplyPatchType {
+		return h.resourceApply(vars, gvk, crd, body)
+	}
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	currentJSON, err := current.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	patchedJSON, err := applyPatch(vars[ContentTypeVar], currentJSON, body)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err = u.UnmarshalJSON(patchedJSON); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	if err = matchRoute(vars, gvk, u); err != nil {
+		return nil, err
+	}
+
+	// validate patched object against its schema
+	if err = h.validator.Validate(u); err != nil {
+		return nil, err
+	}
+
+	if err = prepareUpdate(crd, current, u); err != nil {
+		return nil, err
+	}
+	if err = fieldmanager.Update(current, u, vars.GetFieldManager()); err != nil {
+		return nil, err
+	}
+
+	if err = h.repo.Update(u); err != nil {
+		return nil, err
+	}
+	return h.repo.Read(gvk, vars.GetNamespacedName())
+}
+
+// ResourceDeleteHandler handles the delete resource action, returning the object removed.
+func (h *APIResourceHandler) ResourceDeleteHandler(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, err := h.resolveGVK(vars)
+	if err != nil {
+		return nil, err
+	}
+	return h.repo.Delete(gvk, vars.GetNamespacedName())
+}
+
 // Register adds the handler routes in the router.
 func (h *APIResourceHandler) Register(router *mux.Router) {
+	// execute several write operations atomically, registered before resource creation in order to
+	// take precedence over it
+	router.HandleFunc(
+		fmt.Sprintf("/apis/%s/%s", transactionGroupVersion, transactionAPIResource.Name),
+		Adapt(h.TransactionPostHandler),
+	).Methods("POST")
+
 	// create a resource
-	// used by kubectl to create a resource
+	// used by kubectl to create a resource, either under a namespace or, for cluster scoped
+	// resources and objects informing their namespace, directly under the resource
 	// should support same serializations kubectl does
+	router.HandleFunc(
+		"/apis/{group}/{version}/namespaces/{namespace}/{resource}",
+		Adapt(h.ResourcePostHandler),
+	).Methods("POST")
 	router.HandleFunc("/apis/{group}/{version}/{resource}", Adapt(h.ResourcePostHandler)).
 		Methods("POST")
 
-	// used by kubectl to list objects of a particular resource
+	// namespaced objects are addressed under their namespace, and cluster scoped ones directly
+	// under their resource
+	for _, objectPath := range []string{
+		"/apis/{group}/{version}/namespaces/{namespace}/{resource}/{name}",
+		"/apis/{group}/{version}/{resource}/{name}",
+	} {
+		// used by kubectl to retrieve a single object by name
+		router.HandleFunc(objectPath, Adapt(h.ObjectGetHandler)).Methods("GET")
+
+		// used by kubectl to replace an existing object
+		router.HandleFunc(objectPath, Adapt(h.ResourcePutHandler)).Methods("PUT")
+
+		// used by kubectl edit, label and annotate to change an existing object
+		router.HandleFunc(objectPath, Adapt(h.ResourcePatchHandler)).Methods("PATCH")
+
+		// status subresource, where only the status of an existing object is read and written
+		statusPath := objectPath + "/status"
+		router.HandleFunc(statusPath, Adapt(h.StatusGetHandler)).Methods("GET")
+		router.HandleFunc(statusPath, Adapt(h.StatusPutHandler)).Methods("PUT")
+		router.HandleFunc(statusPath, Adapt(h.StatusPatchHandler)).Methods("PATCH")
+
+		// scale subresource, used by kubectl scale and autoscalers to read and change the replicas
+		// of an object
+		scalePath := objectPath + "/scale"
+		router.HandleFunc(scalePath, Adapt(h.ScaleGetHandler)).Methods("GET")
+		router.HandleFunc(scalePath, Adapt(h.ScalePutHandler)).Methods("PUT")
+		router.HandleFunc(scalePath, Adapt(h.ScalePatchHandler)).Methods("PATCH")
+
+		// used by kubectl to delete an object
+		router.HandleFunc(objectPath, Adapt(h.ResourceDeleteHandler)).Methods("DELETE")
+	}
+
+	// used by kubectl to list or watch objects of a particular resource, in all namespaces or in a
+	// single one
 	// TODO: investigate create a Handler specialized in resource entities
-	router.HandleFunc("/apis/{group}/{version}/{resource}", Adapt(h.ObjectLister)).
+	router.HandleFunc("/apis/{group}/{version}/{resource}", h.ListOrWatch).
 		Methods("GET")
+	router.HandleFunc(
+		"/apis/{group}/{version}/namespaces/{namespace}/{resource}",
+		h.ListOrWatch,
+	).Methods("GET")
 	// used by kubectl to discover all the resources for an API Group
 	router.HandleFunc("/apis/{group}/{version}", Adapt(h.APIResourceLister)).
 		Methods("GET")
@@ -187,13 +498,13 @@ func (h *APIResourceHandler) Register(router *mux.Router) {
 	router.HandleFunc("/apis", Adapt(h.APIGroupLister))
 
 	// used by kubectl to gather the OpenAPI specification of resources managed by this server.
-	// TODO: implement OpenAPI v2 generator from registered CRDs
-	router.HandleFunc("/openapi/v2", Adapt(h.OpenAPIHandler))
-}
-
-// CRDAPIGroups returns all supported APIGroups in the server.
-func (h *APIResourceHandler) CRDAPIGroups() []metav1.APIGroup {
-	return nil
+	router.HandleFunc("/openapi/v2", h.OpenAPIV2Handler).Methods("GET")
+	// used by newer clients to discover and gather OpenAPI v3 documents per group-version
+	router.HandleFunc("/openapi/v3", h.OpenAPIV3Handler).Methods("GET")
+	router.HandleFunc("/openapi/v3/apis/{group}/{version}", h.OpenAPIV3GroupVersionHandler).
+		Methods("GET")
+	router.HandleFunc("/openapi/v3/api/{version}", h.OpenAPIV3GroupVersionHandler).
+		Methods("GET")
 }
 
 // NewAPIResourceHandler create a new handler capable of handling APIResources.
@@ -1,45 +1,135 @@
 package apiserver
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/go-logr/logr"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/klog/klogr"
 
+	"github.com/isutton/orchid/pkg/orchid/fieldmanager"
+	"github.com/isutton/orchid/pkg/orchid/repository"
 	"github.com/isutton/orchid/pkg/orchid/validation"
 	"github.com/isutton/orchid/test/util"
 )
 
 type TestResourcePostHandlerRepository struct {
 	CRDs                 []unstructured.Unstructured
+	Objects              []unstructured.Unstructured
+	ListedNamespace      string
+	ListedOptions        metav1.ListOptions
 	Created              runtime.Object
 	CreatedError         error
+	Updated              runtime.Object
+	UpdatedError         error
+	StatusUpdated        runtime.Object
+	Deleted              types.NamespacedName
+	DeletedError         error
 	ReadObject           *unstructured.Unstructured
 	ReadError            error
 	OpenAPIV3Schema      *extv1.JSONSchemaProps
 	OpenAPIV3SchemaError error
+	WatchEvents          []watch.Event
+	WatchError           error
+	Operations           []repository.Operation
+	TransactionError     error
 }
 
 func (m *TestResourcePostHandlerRepository) List(ns string, gvk schema.GroupVersionKind, options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
-	return &unstructured.UnstructuredList{Items: m.CRDs}, nil
+	if gvk == repository.CRDGVK {
+		return &unstructured.UnstructuredList{Items: m.CRDs}, nil
+	}
+	m.ListedNamespace = ns
+	m.ListedOptions = options
+	return &unstructured.UnstructuredList{Items: m.Objects}, nil
+}
+
+func (m *TestResourcePostHandlerRepository) Watch(ctx context.Context, ns string, gvk schema.GroupVersionKind, options metav1.ListOptions) (<-chan watch.Event, error) {
+	m.ListedNamespace = ns
+	m.ListedOptions = options
+	if m.WatchError != nil {
+		return nil, m.WatchError
+	}
+	events := make(chan watch.Event, len(m.WatchEvents))
+	for _, event := range m.WatchEvents {
+		events <- event
+	}
+	close(events)
+	return events, nil
+}
+
+func (m *TestResourcePostHandlerRepository) Transaction(operations []repository.Operation) ([]*unstructured.Unstructured, error) {
+	m.Operations = operations
+	if m.TransactionError != nil {
+		return nil, m.TransactionError
+	}
+	objects := []*unstructured.Unstructured{}
+	for _, operation := range operations {
+		objects = append(objects, operation.Object)
+	}
+	return objects, nil
 }
 
 func (m *TestResourcePostHandlerRepository) Create(u *unstructured.Unstructured) error {
 	m.Created = u
+	// objects not found before are readable once created
+	if m.ReadError == repository.ObjectNotFoundErr {
+		m.ReadObject, m.ReadError = u, nil
+		return nil
+	}
 	return m.ReadError
 }
 
+func (m *TestResourcePostHandlerRepository) Update(u *unstructured.Unstructured) error {
+	m.Updated = u
+	return m.UpdatedError
+}
+
+func (m *TestResourcePostHandlerRepository) UpdateStatus(u *unstructured.Unstructured) error {
+	m.StatusUpdated = u
+	return m.UpdatedError
+}
+
+func (m *TestResourcePostHandlerRepository) Delete(gvk schema.GroupVersionKind, namespacedName types.NamespacedName) (*unstructured.Unstructured, error) {
+	m.Deleted = namespacedName
+	if m.DeletedError != nil {
+		return nil, m.DeletedError
+	}
+	return m.ReadObject, nil
+}
+
 func (m *TestResourcePostHandlerRepository) Read(gvk schema.GroupVersionKind, namespacedName types.NamespacedName) (*unstructured.Unstructured, error) {
 	return m.ReadObject, m.ReadError
 }
 
+// requireManagedBy asserts obj fields are managed by manager, returning a copy of obj without its
+// managedFields.
+func requireManagedBy(t *testing.T, obj runtime.Object, manager string) runtime.Object {
+	u, ok := obj.(*unstructured.Unstructured)
+	require.True(t, ok)
+	managedFields := u.GetManagedFields()
+	require.Len(t, managedFields, 1)
+	require.Equal(t, manager, managedFields[0].Manager)
+
+	u = u.DeepCopy()
+	u.SetManagedFields(nil)
+	return u
+}
+
 var (
 	CustomResourceDefintionAsset = "../../../test/crds/customresourcedefinition.yaml"
 	InvalidCRAsset               = "../../../test/crds/cr-invalid.yaml"
@@ -77,13 +167,14 @@ func TestAPIResourceHandler_ResourcePostHandler(t *testing.T) {
 			if args.wantErr {
 				require.Error(t, err)
 				if args.repository.ReadError != nil {
-					require.Equal(t, args.repository.ReadError, err)
+					require.True(t, errors.Is(err, args.repository.ReadError))
 				}
 				return
 			}
 			require.NoError(t, err)
 			util.RequireYamlEqual(t, got, args.want)
-			util.RequireYamlEqual(t, args.repository.Created, args.want)
+			created := requireManagedBy(t, args.repository.Created, fieldmanager.DefaultManager)
+			util.RequireYamlEqual(t, created, args.want)
 		}
 	}
 
@@ -156,6 +247,10 @@ func TestAPIResourceHandler_ResourcePostHandler(t *testing.T) {
 		},
 	))
 
+	// custom resources start at the first generation
+	createdCR := util.LoadUnstructured(ValidCRAsset)
+	createdCR.SetGeneration(1)
+
 	t.Run("crontab can be created", assertPost(
 		args{
 			body:   util.ReadAsset(ValidCRAsset),
@@ -164,11 +259,11 @@ func TestAPIResourceHandler_ResourcePostHandler(t *testing.T) {
 				CRDs: []unstructured.Unstructured{
 					*(util.LoadUnstructured(ValidCRDAsset)),
 				},
-				ReadObject:      util.LoadUnstructured(ValidCRAsset),
+				ReadObject:      createdCR,
 				OpenAPIV3Schema: openAPIV3Schema,
 			},
 			wantErr: false,
-			want:    util.LoadUnstructured(ValidCRAsset),
+			want:    createdCR,
 		},
 	))
 
@@ -242,3 +337,459 @@ func TestAPIResourceHandler_Validate(t *testing.T) {
 		},
 	))
 }
+
+func TestAPIResourceHandler_ObjectGetHandler(t *testing.T) {
+	logger := klogr.New()
+	vars := Vars{
+		"group":     "stable.example.com",
+		"version":   "v1",
+		"namespace": "example",
+		"resource":  "crontabs",
+		"name":      "example",
+	}
+
+	buildHandler := func(repo *TestResourcePostHandlerRepository) *APIResourceHandler {
+		return &APIResourceHandler{
+			logger:    logger,
+			repo:      repo,
+			validator: validation.NewRepositoryValidator(repo),
+		}
+	}
+
+	t.Run("crontab can be read", func(t *testing.T) {
+		h := buildHandler(&TestResourcePostHandlerRepository{
+			CRDs:       []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))},
+			ReadObject: util.LoadUnstructured(ValidCRAsset),
+		})
+		got, err := h.ObjectGetHandler(vars, nil)
+		require.NoError(t, err)
+		util.RequireYamlEqual(t, got, util.LoadUnstructured(ValidCRAsset))
+	})
+
+	t.Run("resource is not served", func(t *testing.T) {
+		h := buildHandler(&TestResourcePostHandlerRepository{})
+		_, err := h.ObjectGetHandler(vars, nil)
+		require.Equal(t, ResourceNotFoundErr, err)
+	})
+
+	t.Run("crontab does not exist", func(t *testing.T) {
+		h := buildHandler(&TestResourcePostHandlerRepository{
+			CRDs:      []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))},
+			ReadError: repository.ObjectNotFoundErr,
+		})
+		router := mux.NewRouter()
+		h.Register(router)
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodGet, "/apis/stable.example.com/v1/namespaces/example/crontabs/example", nil)
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+
+		status := &metav1.Status{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), status))
+		require.Equal(t, "Status", status.Kind)
+		require.Equal(t, metav1.StatusReasonNotFound, status.Reason)
+	})
+}
+
+func TestAPIResourceHandler_ObjectLister(t *testing.T) {
+	logger := klogr.New()
+
+	type args struct {
+		vars      Vars
+		namespace string
+	}
+
+	assertList := func(args args) func(*testing.T) {
+		return func(t *testing.T) {
+			repo := &TestResourcePostHandlerRepository{
+				CRDs:    []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))},
+				Objects: []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRAsset))},
+			}
+			h := &APIResourceHandler{
+				logger:    logger,
+				repo:      repo,
+				validator: validation.NewRepositoryValidator(repo),
+			}
+			obj, err := h.ObjectLister(args.vars, nil)
+			require.NoError(t, err)
+
+			list, ok := obj.(*unstructured.UnstructuredList)
+			require.True(t, ok)
+			require.Equal(t, "stable.example.com/v1", list.GetAPIVersion())
+			require.Equal(t, "CronTabList", list.GetKind())
+			require.Len(t, list.Items, 1)
+
+			require.Equal(t, args.namespace, repo.ListedNamespace)
+			require.Equal(t, args.vars["labelSelector"], repo.ListedOptions.LabelSelector)
+		}
+	}
+
+	t.Run("crontabs in namespace", assertList(args{
+		vars: Vars{
+			"group":         "stable.example.com",
+			"version":       "v1",
+			"namespace":     "example",
+			"resource":      "crontabs",
+			"labelSelector": "app=example",
+		},
+		namespace: "example",
+	}))
+
+	t.Run("crontabs in all namespaces", assertList(args{
+		vars: Vars{
+			"group":    "stable.example.com",
+			"version":  "v1",
+			"resource": "crontabs",
+		},
+		namespace: metav1.NamespaceAll,
+	}))
+}
+
+func TestAPIResourceHandler_ResourcePutHandler(t *testing.T) {
+	logger := klogr.New()
+	vars := Vars{
+		"group":     "stable.example.com",
+		"version":   "v1",
+		"namespace": "example",
+		"resource":  "crontabs",
+		"name":      "example",
+	}
+
+	type args struct {
+		body       []byte
+		repository *TestResourcePostHandlerRepository
+		vars       Vars
+		want       runtime.Object
+		wantErr    error
+		badRequest bool
+	}
+
+	assertPut := func(args args) func(*testing.T) {
+		return func(t *testing.T) {
+			h := &APIResourceHandler{
+				logger:    logger,
+				repo:      args.repository,
+				validator: validation.NewRepositoryValidator(args.repository),
+			}
+			got, err := h.ResourcePutHandler(args.vars, args.body)
+			if args.badRequest {
+				require.True(t, apierrors.IsBadRequest(err))
+				return
+			}
+			if args.wantErr != nil {
+				require.True(t, errors.Is(err, args.wantErr))
+				return
+			}
+			require.NoError(t, err)
+			util.RequireYamlEqual(t, got, args.want)
+			// body is not changing the current object, thus no managed fields are recorded
+			util.RequireYamlEqual(t, args.repository.Updated, args.want)
+		}
+	}
+
+	crds := []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))}
+
+	t.Run("body empty", assertPut(args{
+		body:       []byte{},
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		vars:       vars,
+		wantErr:    BodyEmptyErr,
+	}))
+
+	t.Run("resource manifest is invalid", assertPut(args{
+		body:       util.ReadAsset(InvalidCRAsset),
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		vars:       vars,
+		wantErr:    validation.InvalidObjectErr,
+	}))
+
+	t.Run("name does not match url", assertPut(args{
+		body:       util.ReadAsset(ValidCRAsset),
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		vars: Vars{
+			"group":     "stable.example.com",
+			"version":   "v1",
+			"namespace": "example",
+			"resource":  "crontabs",
+			"name":      "other",
+		},
+		badRequest: true,
+	}))
+
+	t.Run("crontab does not exist", assertPut(args{
+		body: util.ReadAsset(ValidCRAsset),
+		repository: &TestResourcePostHandlerRepository{
+			CRDs:         crds,
+			UpdatedError: repository.ObjectNotFoundErr,
+		},
+		vars:    vars,
+		wantErr: repository.ObjectNotFoundErr,
+	}))
+
+	t.Run("crontab has been modified", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:         crds,
+			ReadObject:   util.LoadUnstructured(ValidCRAsset),
+			UpdatedError: repository.ResourceVersionConflictErr,
+		}
+		h := &APIResourceHandler{
+			logger:    logger,
+			repo:      repo,
+			validator: validation.NewRepositoryValidator(repo),
+		}
+		_, err := h.ResourcePutHandler(vars, util.ReadAsset(ValidCRAsset))
+		require.Equal(t, repository.ResourceVersionConflictErr, err)
+		require.True(t, apierrors.IsConflict(asStatusError(err, vars)))
+	})
+
+	t.Run("crontab can be updated", assertPut(args{
+		body: util.ReadAsset(ValidCRAsset),
+		repository: &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: util.LoadUnstructured(ValidCRAsset),
+		},
+		vars: vars,
+		want: util.LoadUnstructured(ValidCRAsset),
+	}))
+}
+
+func TestAPIResourceHandler_ResourceDeleteHandler(t *testing.T) {
+	logger := klogr.New()
+	vars := Vars{
+		"group":     "stable.example.com",
+		"version":   "v1",
+		"namespace": "example",
+		"resource":  "crontabs",
+		"name":      "example",
+	}
+	crds := []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))}
+
+	t.Run("crontab can be deleted", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: util.LoadUnstructured(ValidCRAsset),
+		}
+		h := &APIResourceHandler{logger: logger, repo: repo}
+		got, err := h.ResourceDeleteHandler(vars, nil)
+		require.NoError(t, err)
+		util.RequireYamlEqual(t, got, util.LoadUnstructured(ValidCRAsset))
+		require.Equal(t, vars.GetNamespacedName(), repo.Deleted)
+	})
+
+	t.Run("crontab does not exist", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:         crds,
+			DeletedError: repository.ObjectNotFoundErr,
+		}
+		h := &APIResourceHandler{logger: logger, repo: repo}
+		_, err := h.ResourceDeleteHandler(vars, nil)
+		require.Equal(t, repository.ObjectNotFoundErr, err)
+	})
+}
+
+func TestAPIResourceHandler_ResourcePatchHandler(t *testing.T) {
+	logger := klogr.New()
+	crds := []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))}
+
+	type args struct {
+		contentType string
+		body        string
+		wantErr     error
+		wantCode    int32
+		wantImage   string
+		wantReplica int64
+	}
+
+	assertPatch := func(args args) func(*testing.T) {
+		return func(t *testing.T) {
+			repo := &TestResourcePostHandlerRepository{
+				CRDs:       crds,
+				ReadObject: util.LoadUnstructured(ValidCRAsset),
+			}
+			h := &APIResourceHandler{
+				logger:    logger,
+				repo:      repo,
+				validator: validation.NewRepositoryValidator(repo),
+			}
+			vars := Vars{
+				"group":        "stable.example.com",
+				"version":      "v1",
+				"namespace":    "example",
+				"resource":     "crontabs",
+				"name":         "example",
+				ContentTypeVar: args.contentType,
+			}
+			_, err := h.ResourcePatchHandler(vars, []byte(args.body))
+			if args.wantErr != nil {
+				require.True(t, errors.Is(err, args.wantErr))
+				return
+			}
+			if args.wantCode != 0 {
+				statusErr, ok := err.(apierrors.APIStatus)
+				require.True(t, ok)
+				require.Equal(t, args.wantCode, statusErr.Status().Code)
+				return
+			}
+			require.NoError(t, err)
+
+			updated, ok := repo.Updated.(*unstructured.Unstructured)
+			require.True(t, ok)
+			image, _, _ := unstructured.NestedString(updated.Object, "spec", "image")
+			require.Equal(t, args.wantImage, image)
+			replicas, _, _ := unstructured.NestedInt64(updated.Object, "spec", "replicas")
+			require.Equal(t, args.wantReplica, replicas)
+		}
+	}
+
+	t.Run("merge patch", assertPatch(args{
+		contentType: "application/merge-patch+json",
+		body:        `{"spec":{"replicas":3}}`,
+		wantImage:   "image:latest",
+		wantReplica: 3,
+	}))
+
+	t.Run("json patch", assertPatch(args{
+		contentType: "application/json-patch+json; charset=utf-8",
+		body:        `[{"op":"replace","path":"/spec/image","value":"image:v2"}]`,
+		wantImage:   "image:v2",
+		wantReplica: 1,
+	}))
+
+	t.Run("patched object is invalid", assertPatch(args{
+		contentType: "application/merge-patch+json",
+		body:        `{"spec":{"replicas":"three"}}`,
+		wantErr:     validation.InvalidObjectErr,
+	}))
+
+	t.Run("malformed json patch", assertPatch(args{
+		contentType: "application/json-patch+json",
+		body:        `{"op":"replace"}`,
+		wantCode:    http.StatusBadRequest,
+	}))
+
+	t.Run("unsupported patch type", assertPatch(args{
+		contentType: "application/strategic-merge-patch+json",
+		body:        `{"spec":{"replicas":3}}`,
+		wantCode:    http.StatusUnsupportedMediaType,
+	}))
+
+	t.Run("body empty", assertPatch(args{
+		contentType: "application/merge-patch+json",
+		wantErr:     BodyEmptyErr,
+	}))
+}
+
+func TestAPIResourceHandler_ResourceApply(t *testing.T) {
+	logger := klogr.New()
+	crds := []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))}
+	applyBody := `
+apiVersion: stable.example.com/v1
+kind: CronTab
+metadata:
+  name: example
+  namespace: example
+spec:
+  cronSpec: "* * * * *"
+  image: "image:latest"
+  replicas: 3
+`
+
+	// ownedByOther returns the crontab asset having all its fields owned by "other" manager
+	ownedByOther := func() *unstructured.Unstructured {
+		u := util.LoadUnstructured(ValidCRAsset)
+		require.NoError(t, fieldmanager.Update(nil, u, "other"))
+		return u
+	}
+
+	type args struct {
+		repository *TestResourcePostHandlerRepository
+		vars       Vars
+		wantCode   int32
+		wantField  string
+	}
+
+	assertApply := func(args args) func(*testing.T) {
+		return func(t *testing.T) {
+			h := &APIResourceHandler{
+				logger:    logger,
+				repo:      args.repository,
+				validator: validation.NewRepositoryValidator(args.repository),
+			}
+			vars := Vars{
+				"group":        "stable.example.com",
+				"version":      "v1",
+				"namespace":    "example",
+				"resource":     "crontabs",
+				"name":         "example",
+				ContentTypeVar: string(types.ApplyPatchType),
+			}
+			for k, v := range args.vars {
+				vars[k] = v
+			}
+			_, err := h.ResourcePatchHandler(vars, []byte(applyBody))
+			if args.wantCode != 0 {
+				statusErr, ok := asStatusError(err, vars).(apierrors.APIStatus)
+				require.True(t, ok)
+				require.Equal(t, args.wantCode, statusErr.Status().Code)
+				if args.wantField != "" {
+					require.Len(t, statusErr.Status().Details.Causes, 1)
+					require.Equal(t, args.wantField, statusErr.Status().Details.Causes[0].Field)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			stored := args.repository.Updated
+			if stored == nil {
+				stored = args.repository.Created
+			}
+			u, ok := stored.(*unstructured.Unstructured)
+			require.True(t, ok)
+			replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+			require.Equal(t, int64(3), replicas)
+
+			// replicas must be exclusively owned by the applier
+			replicasPath := fieldmanager.Path{"spec", "replicas"}
+			for _, entry := range u.GetManagedFields() {
+				set, err := fieldmanager.NewSetFromFieldsV1(entry.FieldsV1.Raw)
+				require.NoError(t, err)
+				applier := entry.Manager == "kubectl" &&
+					entry.Operation == metav1.ManagedFieldsOperationApply
+				require.Equal(t, applier, set.Has(replicasPath))
+			}
+		}
+	}
+
+	t.Run("field manager is required", assertApply(args{
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		wantCode:   http.StatusBadRequest,
+	}))
+
+	t.Run("crontab is created", assertApply(args{
+		repository: &TestResourcePostHandlerRepository{
+			CRDs:      crds,
+			ReadError: repository.ObjectNotFoundErr,
+		},
+		vars: Vars{"fieldManager": "kubectl"},
+	}))
+
+	t.Run("conflict with other manager", assertApply(args{
+		repository: &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: ownedByOther(),
+		},
+		vars:      Vars{"fieldManager": "kubectl"},
+		wantCode:  http.StatusConflict,
+		wantField: ".spec.replicas",
+	}))
+
+	t.Run("force takes ownership", assertApply(args{
+		repository: &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: ownedByOther(),
+		},
+		vars: Vars{"fieldManager": "kubectl", "force": "true"},
+	}))
+}
@@ -0,0 +1,278 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/isutton/orchid/pkg/orchid/fieldmanager"
+)
+
+// scaleField name of the subresource handling replicas.
+const scaleField = "scale"
+
+// scaleSubresource returns the scale subresource declared by the CRD for the version informed, or
+// nil when not declared.
+func scaleSubresource(
+	crd *extv1.CustomResourceDefinition,
+	version string,
+) *extv1.CustomResourceSubresourceScale {
+	if crd == nil {
+		return nil
+	}
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name == version && crdVersion.Subresources != nil {
+			return crdVersion.Subresources.Scale
+		}
+	}
+	return nil
+}
+
+// fieldPath splits a JSON path, as in ".spec.replicas", into its fields.
+func fieldPath(jsonPath string) []string {
+	return strings.Split(strings.TrimPrefix(jsonPath, "."), ".")
+}
+
+// replicasPath returns the field path of a JSON path, as in ".spec.replicas".
+func replicasPath(jsonPath string) *field.Path {
+	path := fieldPath(jsonPath)
+	return field.NewPath(path[0], path[1:]...)
+}
+
+// nestedReplicas returns the amount of replicas found in the object path, zero when not found. It
+// returns a field error when the value found is not an integer, or does not fit in 32 bits.
+func nestedReplicas(obj map[string]interface{}, jsonPath string) (int32, *field.Error) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fieldPath(jsonPath)...)
+	if err != nil {
+		return 0, field.Invalid(replicasPath(jsonPath), value, err.Error())
+	}
+	if !found {
+		return 0, nil
+	}
+	var replicas int64
+	switch v := value.(type) {
+	case int64:
+		replicas = v
+	case int32:
+		return v, nil
+	case int:
+		replicas = int64(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, field.Invalid(replicasPath(jsonPath), value, "must be an integer")
+		}
+		replicas = int64(v)
+	default:
+		return 0, field.Invalid(replicasPath(jsonPath), value, "must be an integer")
+	}
+	if replicas < math.MinInt32 || replicas > math.MaxInt32 {
+		return 0, field.Invalid(replicasPath(jsonPath), value, "must fit in a 32-bit integer")
+	}
+	return int32(replicas), nil
+}
+
+// newScale builds the scale object out of the custom resource, reading replicas and selector from
+// the paths declared in the scale subresource. It returns an invalid status error when replicas are
+// not 32-bit integers, and errors on reading the label selector path.
+func newScale(
+	scale *extv1.CustomResourceSubresourceScale,
+	u *unstructured.Unstructured,
+) (*autoscalingv1.Scale, error) {
+	errs := field.ErrorList{}
+	specReplicas, fieldErr := nestedReplicas(u.Object, scale.SpecReplicasPath)
+	if fieldErr != nil {
+		errs = append(errs, fieldErr)
+	}
+	statusReplicas, fieldErr := nestedReplicas(u.Object, scale.StatusReplicasPath)
+	if fieldErr != nil {
+		errs = append(errs, fieldErr)
+	}
+	if len(errs) > 0 {
+		return nil, apierrors.NewInvalid(u.GroupVersionKind().GroupKind(), u.GetName(), errs)
+	}
+	selector := ""
+	if scale.LabelSelectorPath != nil {
+		path := fieldPath(*scale.LabelSelectorPath)
+		var err error
+		if selector, _, err = unstructured.NestedString(u.Object, path...); err != nil {
+			return nil, err
+		}
+	}
+	return &autoscalingv1.Scale{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: autoscalingv1.SchemeGroupVersion.String(),
+			Kind:       "Scale",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              u.GetName(),
+			Namespace:         u.GetNamespace(),
+			UID:               u.GetUID(),
+			ResourceVersion:   u.GetResourceVersion(),
+			CreationTimestamp: u.GetCreationTimestamp(),
+		},
+		Spec:   autoscalingv1.ScaleSpec{Replicas: specReplicas},
+		Status: autoscalingv1.ScaleStatus{Replicas: statusReplicas, Selector: selector},
+	}, nil
+}
+
+// resolveScale finds the GVK served under vars, together with its CRD and the scale subresource it
+// declares. It returns ResourceNotFoundErr when not declared, and errors from listing CRDs.
+func (h *APIResourceHandler) resolveScale(vars Vars) (
+	schema.GroupVersionKind,
+	*extv1.CustomResourceDefinition,
+	*extv1.CustomResourceSubresourceScale,
+	error,
+) {
+	gvk, crd, err := h.resolveCRD(vars)
+	if err != nil {
+		return schema.GroupVersionKind{}, nil, nil, err
+	}
+	scale := scaleSubresource(crd, gvk.Version)
+	if scale == nil {
+		return schema.GroupVersionKind{}, nil, nil, ResourceNotFoundErr
+	}
+	return gvk, crd, scale, nil
+}
+
+// ScaleGetHandler returns the scale of a single object, identified by namespace and name.
+func (h *APIResourceHandler) ScaleGetHandler(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, _, scale, err := h.resolveScale(vars)
+	if err != nil {
+		return nil, err
+	}
+	u, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	return newScale(scale, u)
+}
+
+// updateScale stores the replicas informed in the desired scale on the spec replicas path of the
+// current object. The resource-version informed in the desired scale is expected to be stored, when
+// set.
+func (h *APIResourceHandler) updateScale(
+	vars Vars,
+	gvk schema.GroupVersionKind,
+	crd *extv1.CustomResourceDefinition,
+	scale *extv1.CustomResourceSubresourceScale,
+	current *unstructured.Unstructured,
+	desired *autoscalingv1.Scale,
+) (runtime.Object, error) {
+	namespacedName := vars.GetNamespacedName()
+	if desired.GetName() != namespacedName.Name {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf(
+			"object name '%s' does not match the name in URL '%s'",
+			desired.GetName(), namespacedName.Name))
+	}
+
+	u := current.DeepCopy()
+	replicas := int64(desired.Spec.Replicas)
+	err := unstructured.SetNestedField(u.Object, replicas, fieldPath(scale.SpecReplicasPath)...)
+	if err != nil {
+		return nil, err
+	}
+	u.SetResourceVersion(desired.GetResourceVersion())
+	if err = prepareUpdate(crd, current, u); err != nil {
+		return nil, err
+	}
+	if err = fieldmanager.Update(current, u, vars.GetFieldManager()); err != nil {
+		return nil, err
+	}
+
+	// validate resulting object against its schema, and its replicas as scale does
+	if err = h.validator.Validate(u); err != nil {
+		return nil, err
+	}
+	if _, err = newScale(scale, u); err != nil {
+		return nil, err
+	}
+
+	if err = h.repo.Update(u); err != nil {
+		return nil, err
+	}
+	updated, err := h.repo.Read(gvk, namespacedName)
+	if err != nil {
+		return nil, err
+	}
+	return newScale(scale, updated)
+}
+
+// ScalePutHandler handles the update scale action, storing the desired replicas on the spec
+// replicas path of an existing object.
+func (h *APIResourceHandler) ScalePutHandler(vars Vars, body []byte) (runtime.Object, error) {
+	if len(body) == 0 {
+		return nil, BodyEmptyErr
+	}
+	gvk, crd, scale, err := h.resolveScale(vars)
+	if err != nil {
+		return nil, err
+	}
+	desired := &autoscalingv1.Scale{}
+	if err = yaml.Unmarshal(body, desired); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	return h.updateScale(vars, gvk, crd, scale, current, desired)
+}
+
+// applyScalePatch applies the patch on the original scale JSON document. Since scale is a built-in
+// type, strategic merge-patches are accepted on top of the patch types handled for objects.
+func applyScalePatch(contentType string, original, patch []byte) ([]byte, error) {
+	if patchType(contentType) != types.StrategicMergePatchType {
+		return applyPatch(contentType, original, patch)
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, autoscalingv1.Scale{})
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return patched, nil
+}
+
+// ScalePatchHandler handles the patch scale action, applying a JSON merge-patch, a strategic
+// merge-patch or a JSON patch on the current scale, and storing the resulting replicas.
+func (h *APIResourceHandler) ScalePatchHandler(vars Vars, body []byte) (runtime.Object, error) {
+	if len(body) == 0 {
+		return nil, BodyEmptyErr
+	}
+	gvk, crd, scale, err := h.resolveScale(vars)
+	if err != nil {
+		return nil, err
+	}
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	currentScale, err := newScale(scale, current)
+	if err != nil {
+		return nil, err
+	}
+	currentJSON, err := json.Marshal(currentScale)
+	if err != nil {
+		return nil, err
+	}
+	patchedJSON, err := applyScalePatch(vars[ContentTypeVar], currentJSON, body)
+	if err != nil {
+		return nil, err
+	}
+	desired := &autoscalingv1.Scale{}
+	if err = json.Unmarshal(patchedJSON, desired); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return h.updateScale(vars, gvk, crd, scale, current, desired)
+}
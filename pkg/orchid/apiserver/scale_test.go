@@ -0,0 +1,196 @@
+package apiserver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+// scaleCRDMock returns the crontab CRD declaring the scale subresource over spec replicas.
+func scaleCRDMock(t *testing.T) unstructured.Unstructured {
+	crd := util.LoadUnstructured(ValidCRDAsset)
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	require.NoError(t, err)
+	version := versions[0].(map[string]interface{})
+	version["subresources"] = map[string]interface{}{
+		"scale": map[string]interface{}{
+			"specReplicasPath":   ".spec.replicas",
+			"statusReplicasPath": ".status.replicas",
+			"labelSelectorPath":  ".status.selector",
+		},
+	}
+	err = unstructured.SetNestedField(version, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"replicas": map[string]interface{}{"type": "integer"},
+			"selector": map[string]interface{}{"type": "string"},
+		},
+	}, "schema", "openAPIV3Schema", "properties", "status")
+	require.NoError(t, err)
+	require.NoError(t, unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+	return *crd
+}
+
+func TestAPIResourceHandler_Scale(t *testing.T) {
+	logger := klogr.New()
+	vars := Vars{
+		"group":     "stable.example.com",
+		"version":   "v1",
+		"namespace": "example",
+		"resource":  "crontabs",
+		"name":      "example",
+	}
+	crds := []unstructured.Unstructured{scaleCRDMock(t)}
+
+	crontab := util.LoadUnstructured(ValidCRAsset)
+	crontab.SetResourceVersion("1")
+	crontab.SetGeneration(1)
+	require.NoError(t, unstructured.SetNestedMap(crontab.Object, map[string]interface{}{
+		"replicas": int64(1),
+		"selector": "app=crontab",
+	}, "status"))
+
+	buildHandler := func(repo *TestResourcePostHandlerRepository) *APIResourceHandler {
+		return &APIResourceHandler{
+			logger:    logger,
+			repo:      repo,
+			validator: validation.NewRepositoryValidator(repo),
+		}
+	}
+
+	// patchVars returns vars informing the content-type of a patch request
+	patchVars := func(contentType string) Vars {
+		patched := Vars{ContentTypeVar: contentType}
+		for k, v := range vars {
+			patched[k] = v
+		}
+		return patched
+	}
+
+	t.Run("scale can be read", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontab}
+		obj, err := buildHandler(repo).ScaleGetHandler(vars, nil)
+		require.NoError(t, err)
+
+		scale, ok := obj.(*autoscalingv1.Scale)
+		require.True(t, ok)
+		require.Equal(t, "Scale", scale.Kind)
+		require.Equal(t, "autoscaling/v1", scale.APIVersion)
+		require.Equal(t, "example", scale.GetName())
+		require.Equal(t, "1", scale.GetResourceVersion())
+		require.Equal(t, int32(1), scale.Spec.Replicas)
+		require.Equal(t, int32(1), scale.Status.Replicas)
+		require.Equal(t, "app=crontab", scale.Status.Selector)
+	})
+
+	t.Run("scale can be updated", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontab}
+		body := []byte(`{
+			"apiVersion": "autoscaling/v1",
+			"kind": "Scale",
+			"metadata": {"name": "example", "namespace": "example", "resourceVersion": "1"},
+			"spec": {"replicas": 3}
+		}`)
+		_, err := buildHandler(repo).ScalePutHandler(vars, body)
+		require.NoError(t, err)
+
+		updated, ok := repo.Updated.(*unstructured.Unstructured)
+		require.True(t, ok)
+		replicas, _, err := unstructured.NestedInt64(updated.Object, "spec", "replicas")
+		require.NoError(t, err)
+		require.Equal(t, int64(3), replicas)
+		require.Equal(t, int64(2), updated.GetGeneration())
+		require.Equal(t, "1", updated.GetResourceVersion())
+	})
+
+	t.Run("scale can be patched", func(t *testing.T) {
+		patches := map[string]string{
+			"application/merge-patch+json":           `{"spec": {"replicas": 3}}`,
+			"application/strategic-merge-patch+json": `{"spec": {"replicas": 3}}`,
+			"application/json-patch+json": `[
+				{"op": "replace", "path": "/spec/replicas", "value": 3}
+			]`,
+		}
+		for contentType, patch := range patches {
+			repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontab}
+			_, err := buildHandler(repo).ScalePatchHandler(patchVars(contentType), []byte(patch))
+			require.NoError(t, err, contentType)
+
+			updated, ok := repo.Updated.(*unstructured.Unstructured)
+			require.True(t, ok)
+			replicas, _, err := unstructured.NestedInt64(updated.Object, "spec", "replicas")
+			require.NoError(t, err)
+			require.Equal(t, int64(3), replicas, contentType)
+			require.Equal(t, "1", updated.GetResourceVersion())
+		}
+	})
+
+	t.Run("scale update records the field manager", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontab}
+		body := []byte(`{
+			"apiVersion": "autoscaling/v1",
+			"kind": "Scale",
+			"metadata": {"name": "example", "namespace": "example"},
+			"spec": {"replicas": 3}
+		}`)
+		managerVars := patchVars("")
+		managerVars["fieldManager"] = "autoscaler"
+		_, err := buildHandler(repo).ScalePutHandler(managerVars, body)
+		require.NoError(t, err)
+		requireManagedBy(t, repo.Updated, "autoscaler")
+	})
+
+	t.Run("patch type is unknown", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontab}
+		_, err := buildHandler(repo).ScalePatchHandler(patchVars("text/plain"), []byte(`{}`))
+		require.True(t, apierrors.IsUnsupportedMediaType(err))
+	})
+
+	t.Run("replicas are not 32-bit integers", func(t *testing.T) {
+		for _, replicas := range []interface{}{1.5, int64(math.MaxInt32) + 1, "one"} {
+			u := crontab.DeepCopy()
+			require.NoError(t, unstructured.SetNestedField(u.Object, replicas, "status", "replicas"))
+			repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: u}
+			_, err := buildHandler(repo).ScaleGetHandler(vars, nil)
+			require.True(t, apierrors.IsInvalid(err), "%v", replicas)
+		}
+	})
+
+	t.Run("name does not match url", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontab}
+		body := []byte(`{"metadata": {"name": "other"}, "spec": {"replicas": 3}}`)
+		_, err := buildHandler(repo).ScalePutHandler(vars, body)
+		require.True(t, apierrors.IsBadRequest(err))
+	})
+
+	t.Run("scale is discovered", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds}
+		obj, err := buildHandler(repo).APIResourceLister(
+			Vars{"group": "stable.example.com", "version": "v1"}, nil)
+		require.NoError(t, err)
+		resourceList, ok := obj.(*metav1.APIResourceList)
+		require.True(t, ok)
+		require.Len(t, resourceList.APIResources, 2)
+		require.Equal(t, "crontabs/scale", resourceList.APIResources[1].Name)
+		require.ElementsMatch(t, []string{"get", "patch", "update"},
+			resourceList.APIResources[1].Verbs)
+	})
+
+	t.Run("scale subresource not declared", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))},
+			ReadObject: crontab,
+		}
+		_, err := buildHandler(repo).ScaleGetHandler(vars, nil)
+		require.Equal(t, ResourceNotFoundErr, err)
+	})
+}
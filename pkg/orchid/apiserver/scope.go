@@ -0,0 +1,76 @@
+package apiserver
+
+import (
+	"fmt"
+	"strings"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// namespaced checks if objects described by the CRD are namespace scoped, where a nil CRD stands for
+// CRD objects themselves, which are cluster scoped.
+func namespaced(crd *extv1.CustomResourceDefinition) bool {
+	return crd != nil && crd.Spec.Scope != extv1.ClusterScoped
+}
+
+// validateNamespace makes sure the namespace is a DNS-1123 label, since namespaces are stored as
+// databases. It returns a bad-request status error otherwise.
+func validateNamespace(namespace string) error {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return apierrors.NewBadRequest(fmt.Sprintf(
+			"invalid namespace '%s': %s", namespace, strings.Join(errs, ", ")))
+	}
+	return nil
+}
+
+// matchScope makes sure the route informed in vars addresses the resource according to its scope,
+// cluster scoped resources are not served under a namespace, and namespaced objects are only
+// addressed by name under a namespace. It returns ResourceNotFoundErr otherwise, and a bad-request
+// status error on invalid namespaces.
+func matchScope(vars Vars, crd *extv1.CustomResourceDefinition) error {
+	hasNamespace := vars["namespace"] != metav1.NamespaceNone
+	if hasNamespace {
+		if err := validateNamespace(vars["namespace"]); err != nil {
+			return err
+		}
+	}
+	if hasNamespace && !namespaced(crd) {
+		return ResourceNotFoundErr
+	}
+	if !hasNamespace && vars["name"] != "" && namespaced(crd) {
+		return ResourceNotFoundErr
+	}
+	return nil
+}
+
+// matchNamespace makes sure the namespace of an object being created is the one informed in the
+// route, filling it in when not informed. Objects of cluster scoped resources have their namespace
+// removed. It returns a bad-request status error when namespaces differ, when a namespaced object
+// has no namespace at all, or when its namespace is invalid.
+func matchNamespace(
+	vars Vars,
+	crd *extv1.CustomResourceDefinition,
+	u *unstructured.Unstructured,
+) error {
+	if !namespaced(crd) {
+		u.SetNamespace(metav1.NamespaceNone)
+		return nil
+	}
+	namespace := vars["namespace"]
+	switch {
+	case namespace == metav1.NamespaceNone && u.GetNamespace() == metav1.NamespaceNone:
+		return apierrors.NewBadRequest("the namespace of the object is required")
+	case namespace == metav1.NamespaceNone:
+	case u.GetNamespace() == metav1.NamespaceNone:
+		u.SetNamespace(namespace)
+	case u.GetNamespace() != namespace:
+		return apierrors.NewBadRequest(fmt.Sprintf(
+			"object namespace '%s' does not match the namespace in URL '%s'",
+			u.GetNamespace(), namespace))
+	}
+	return validateNamespace(u.GetNamespace())
+}
@@ -0,0 +1,158 @@
+package apiserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+// clusterCRDMock returns the crontab CRD declaring cluster scope.
+func clusterCRDMock(t *testing.T) unstructured.Unstructured {
+	crd := util.LoadUnstructured(ValidCRDAsset)
+	err := unstructured.SetNestedField(crd.Object, string(extv1.ClusterScoped), "spec", "scope")
+	require.NoError(t, err)
+	return *crd
+}
+
+func TestScope_matchNamespace(t *testing.T) {
+	namespacedCRD := &extv1.CustomResourceDefinition{}
+	namespacedCRD.Spec.Scope = extv1.NamespaceScoped
+	clusterCRD := &extv1.CustomResourceDefinition{}
+	clusterCRD.Spec.Scope = extv1.ClusterScoped
+
+	tests := []struct {
+		name          string
+		crd           *extv1.CustomResourceDefinition
+		vars          Vars
+		namespace     string
+		wantNamespace string
+		wantErr       bool
+	}{
+		{name: "filled in", crd: namespacedCRD, vars: Vars{"namespace": "ns"}, wantNamespace: "ns"},
+		{
+			name:          "matching",
+			crd:           namespacedCRD,
+			vars:          Vars{"namespace": "ns"},
+			namespace:     "ns",
+			wantNamespace: "ns",
+		},
+		{name: "from body", crd: namespacedCRD, namespace: "ns", wantNamespace: "ns"},
+		{name: "mismatch", crd: namespacedCRD, vars: Vars{"namespace": "ns"}, namespace: "other",
+			wantErr: true},
+		{name: "missing", crd: namespacedCRD, wantErr: true},
+		{name: "invalid", crd: namespacedCRD, namespace: "ns; drop database", wantErr: true},
+		{name: "cluster scoped", crd: clusterCRD, namespace: "ns"},
+		{name: "crd", namespace: "ns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			u.SetNamespace(tt.namespace)
+			err := matchNamespace(tt.vars, tt.crd, u)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantNamespace, u.GetNamespace())
+		})
+	}
+}
+
+func TestScope_Router(t *testing.T) {
+	crontab := util.LoadUnstructured(ValidCRAsset)
+	body, err := crontab.MarshalJSON()
+	require.NoError(t, err)
+
+	serve := func(router *mux.Router, method, path string, body []byte) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set(ContentTypeVar, jsonMediaType)
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	newRouter := func(crd unstructured.Unstructured) (*mux.Router, *TestResourcePostHandlerRepository) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       []unstructured.Unstructured{crd},
+			ReadObject: crontab,
+		}
+		h := &APIResourceHandler{
+			logger:    klogr.New(),
+			repo:      repo,
+			validator: validation.NewRepositoryValidator(repo),
+		}
+		router := mux.NewRouter()
+		h.Register(router)
+		return router, repo
+	}
+
+	t.Run("namespaced", func(t *testing.T) {
+		router, repo := newRouter(*util.LoadUnstructured(ValidCRDAsset))
+		collectionPath := "/apis/stable.example.com/v1/namespaces/example/crontabs"
+
+		recorder := serve(router, http.MethodPost, collectionPath, body)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "example", repo.Created.(*unstructured.Unstructured).GetNamespace())
+
+		withoutNamespace := crontab.DeepCopy()
+		withoutNamespace.SetNamespace("")
+		data, err := withoutNamespace.MarshalJSON()
+		require.NoError(t, err)
+		recorder = serve(router, http.MethodPost, collectionPath, data)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "example", repo.Created.(*unstructured.Unstructured).GetNamespace())
+
+		recorder = serve(router, http.MethodPost,
+			"/apis/stable.example.com/v1/namespaces/other/crontabs", body)
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		recorder = serve(router, http.MethodGet, "/apis/stable.example.com/v1/crontabs/example", nil)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+
+		recorder = serve(router, http.MethodGet,
+			"/apis/stable.example.com/v1/namespaces/Invalid_Namespace/crontabs", nil)
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("cluster scoped", func(t *testing.T) {
+		router, repo := newRouter(clusterCRDMock(t))
+
+		recorder := serve(router, http.MethodPost, "/apis/stable.example.com/v1/crontabs", body)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Empty(t, repo.Created.(*unstructured.Unstructured).GetNamespace())
+
+		recorder = serve(router, http.MethodGet, "/apis/stable.example.com/v1/crontabs/example", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		recorder = serve(router, http.MethodDelete,
+			"/apis/stable.example.com/v1/crontabs/example", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Empty(t, repo.Deleted.Namespace)
+		require.Equal(t, "example", repo.Deleted.Name)
+
+		recorder = serve(router, http.MethodGet,
+			"/apis/stable.example.com/v1/namespaces/example/crontabs/example", nil)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+		recorder = serve(router, http.MethodPost,
+			"/apis/stable.example.com/v1/namespaces/example/crontabs", body)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("custom resource definitions", func(t *testing.T) {
+		router, _ := newRouter(*util.LoadUnstructured(ValidCRDAsset))
+		crdPath := "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/crontabs.stable.example.com"
+
+		recorder := serve(router, http.MethodGet, crdPath, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
@@ -0,0 +1,200 @@
+package apiserver
+
+import (
+	"encoding/json"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/isutton/orchid/pkg/orchid/fieldmanager"
+)
+
+// statusField name of the object field handled by the status subresource.
+const statusField = "status"
+
+// statusSubresource checks if the CRD declares the status subresource for the version informed.
+func statusSubresource(crd *extv1.CustomResourceDefinition, version string) bool {
+	if crd == nil {
+		return false
+	}
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name == version {
+			return crdVersion.Subresources != nil && crdVersion.Subresources.Status != nil
+		}
+	}
+	return false
+}
+
+// copyStatus replaces the status of target by the one in source, removing it when source has none.
+func copyStatus(source, target *unstructured.Unstructured) {
+	status, found := source.Object[statusField]
+	if !found {
+		unstructured.RemoveNestedField(target.Object, statusField)
+		return
+	}
+	target.Object[statusField] = runtime.DeepCopyJSONValue(status)
+}
+
+// contentChanged checks if objects differ on fields other than metadata, and status when the status
+// subresource is in use. Objects are compared as JSON, so numbers of distinct types are alike.
+func contentChanged(current, desired *unstructured.Unstructured, ignoreStatus bool) (bool, error) {
+	content := func(u *unstructured.Unstructured) ([]byte, error) {
+		object := map[string]interface{}{}
+		for key, value := range u.Object {
+			if key == "metadata" || (ignoreStatus && key == statusField) {
+				continue
+			}
+			object[key] = value
+		}
+		return json.Marshal(object)
+	}
+	currentJSON, err := content(current)
+	if err != nil {
+		return false, err
+	}
+	desiredJSON, err := content(desired)
+	if err != nil {
+		return false, err
+	}
+	return string(currentJSON) != string(desiredJSON), nil
+}
+
+// prepareCreate sets the initial generation of custom resources, and removes the status of objects
+// being created when the status subresource is in use, since it's only written through it.
+func prepareCreate(crd *extv1.CustomResourceDefinition, u *unstructured.Unstructured) {
+	if crd == nil {
+		return
+	}
+	if statusSubresource(crd, u.GroupVersionKind().Version) {
+		unstructured.RemoveNestedField(u.Object, statusField)
+	}
+	u.SetGeneration(1)
+}
+
+// prepareUpdate keeps the current status on the desired object when the status subresource is in
+// use, and bumps the generation of custom resources only when their content changes, metadata and
+// status aside. It can return errors on comparing objects.
+func prepareUpdate(
+	crd *extv1.CustomResourceDefinition,
+	current *unstructured.Unstructured,
+	desired *unstructured.Unstructured,
+) error {
+	if crd == nil || current == nil {
+		return nil
+	}
+	ignoreStatus := statusSubresource(crd, desired.GroupVersionKind().Version)
+	if ignoreStatus {
+		copyStatus(current, desired)
+	}
+	changed, err := contentChanged(current, desired, ignoreStatus)
+	if err != nil {
+		return err
+	}
+	generation := current.GetGeneration()
+	if changed {
+		generation++
+	}
+	desired.SetGeneration(generation)
+	return nil
+}
+
+// resolveStatus finds the GVK served under vars, making sure its CRD declares the status
+// subresource. It returns ResourceNotFoundErr otherwise, and errors from listing CRDs.
+func (h *APIResourceHandler) resolveStatus(vars Vars) (schema.GroupVersionKind, error) {
+	gvk, crd, err := h.resolveCRD(vars)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	if !statusSubresource(crd, gvk.Version) {
+		return schema.GroupVersionKind{}, ResourceNotFoundErr
+	}
+	return gvk, nil
+}
+
+// updateStatus stores the status informed in source on the current object, keeping the rest of the
+// object untouched. The resource-version informed in source is expected to be stored, when set.
+func (h *APIResourceHandler) updateStatus(
+	vars Vars,
+	gvk schema.GroupVersionKind,
+	current *unstructured.Unstructured,
+	source *unstructured.Unstructured,
+) (runtime.Object, error) {
+	if err := matchRoute(vars, gvk, source); err != nil {
+		return nil, err
+	}
+	u := current.DeepCopy()
+	copyStatus(source, u)
+	u.SetResourceVersion(source.GetResourceVersion())
+	if err := fieldmanager.Update(current, u, vars.GetFieldManager()); err != nil {
+		return nil, err
+	}
+
+	// validate resulting object against its schema
+	if err := h.validator.Validate(u); err != nil {
+		return nil, err
+	}
+
+	if err := h.repo.UpdateStatus(u); err != nil {
+		return nil, err
+	}
+	return h.repo.Read(gvk, vars.GetNamespacedName())
+}
+
+// StatusGetHandler returns a single object through the status subresource.
+func (h *APIResourceHandler) StatusGetHandler(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, err := h.resolveStatus(vars)
+	if err != nil {
+		return nil, err
+	}
+	return h.repo.Read(gvk, vars.GetNamespacedName())
+}
+
+// StatusPutHandler handles the update status action, replacing only the status of an existing
+// object, any other change informed is ignored.
+func (h *APIResourceHandler) StatusPutHandler(vars Vars, body []byte) (runtime.Object, error) {
+	gvk, err := h.resolveStatus(vars)
+	if err != nil {
+		return nil, err
+	}
+	u, err := decode(vars[ContentTypeVar], body)
+	if err != nil {
+		return nil, err
+	}
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	return h.updateStatus(vars, gvk, current, u)
+}
+
+// StatusPatchHandler handles the patch status action, applying either a JSON merge-patch or a JSON
+// patch on the current object, and storing only the resulting status.
+func (h *APIResourceHandler) StatusPatchHandler(vars Vars, body []byte) (runtime.Object, error) {
+	if len(body) == 0 {
+		return nil, BodyEmptyErr
+	}
+	gvk, err := h.resolveStatus(vars)
+	if err != nil {
+		return nil, err
+	}
+	current, err := h.repo.Read(gvk, vars.GetNamespacedName())
+	if err != nil {
+		return nil, err
+	}
+	currentJSON, err := current.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	patchedJSON, err := applyPatch(vars[ContentTypeVar], currentJSON, body)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err = u.UnmarshalJSON(patchedJSON); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return h.updateStatus(vars, gvk, current, u)
+}
@@ -0,0 +1,188 @@
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+// statusCRDMock returns the crontab CRD declaring the status subresource, and a status property.
+func statusCRDMock(t *testing.T) unstructured.Unstructured {
+	crd := util.LoadUnstructured(ValidCRDAsset)
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	require.NoError(t, err)
+	version := versions[0].(map[string]interface{})
+	version["subresources"] = map[string]interface{}{"status": map[string]interface{}{}}
+	err = unstructured.SetNestedField(version, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"lastScheduleTime": map[string]interface{}{"type": "string"},
+		},
+	}, "schema", "openAPIV3Schema", "properties", "status")
+	require.NoError(t, err)
+	require.NoError(t, unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+	return *crd
+}
+
+// crontabMock returns the crontab object with informed replicas and status.
+func crontabMock(t *testing.T, replicas int64, lastScheduleTime string) *unstructured.Unstructured {
+	u := util.LoadUnstructured(ValidCRAsset)
+	u.SetGeneration(1)
+	u.SetResourceVersion("1")
+	require.NoError(t, unstructured.SetNestedField(u.Object, replicas, "spec", "replicas"))
+	require.NoError(t, unstructured.SetNestedField(
+		u.Object, lastScheduleTime, "status", "lastScheduleTime"))
+	return u
+}
+
+func TestAPIResourceHandler_Status(t *testing.T) {
+	logger := klogr.New()
+	vars := Vars{
+		"group":     "stable.example.com",
+		"version":   "v1",
+		"namespace": "example",
+		"resource":  "crontabs",
+		"name":      "example",
+	}
+	crds := []unstructured.Unstructured{statusCRDMock(t)}
+
+	buildHandler := func(repo *TestResourcePostHandlerRepository) *APIResourceHandler {
+		return &APIResourceHandler{
+			logger:    logger,
+			repo:      repo,
+			validator: validation.NewRepositoryValidator(repo),
+		}
+	}
+
+	requireObject := func(
+		t *testing.T,
+		obj interface{},
+		replicas int64,
+		lastScheduleTime string,
+		generation int64,
+	) *unstructured.Unstructured {
+		u, ok := obj.(*unstructured.Unstructured)
+		require.True(t, ok)
+		gotReplicas, _, err := unstructured.NestedInt64(u.Object, "spec", "replicas")
+		require.NoError(t, err)
+		require.Equal(t, replicas, gotReplicas)
+		gotTime, _, err := unstructured.NestedString(u.Object, "status", "lastScheduleTime")
+		require.NoError(t, err)
+		require.Equal(t, lastScheduleTime, gotTime)
+		require.Equal(t, generation, u.GetGeneration())
+		return u
+	}
+
+	t.Run("create ignores status", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds, ReadObject: crontabMock(t, 1, "")}
+		body, err := crontabMock(t, 1, "now").MarshalJSON()
+		require.NoError(t, err)
+		_, err = buildHandler(repo).ResourcePostHandler(vars, body)
+		require.NoError(t, err)
+
+		created := requireObject(t, repo.Created, 1, "", 1)
+		_, found := created.Object["status"]
+		require.False(t, found)
+	})
+
+	t.Run("update ignores status and bumps generation", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: crontabMock(t, 1, "before"),
+		}
+		body, err := crontabMock(t, 2, "after").MarshalJSON()
+		require.NoError(t, err)
+		_, err = buildHandler(repo).ResourcePutHandler(vars, body)
+		require.NoError(t, err)
+		requireObject(t, repo.Updated, 2, "before", 2)
+	})
+
+	t.Run("update without changes keeps generation", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: crontabMock(t, 1, "before"),
+		}
+		patch := []byte(`{"status":{"lastScheduleTime":"after"}}`)
+		patchVars := Vars{ContentTypeVar: string(types.MergePatchType)}
+		for key, value := range vars {
+			patchVars[key] = value
+		}
+		_, err := buildHandler(repo).ResourcePatchHandler(patchVars, patch)
+		require.NoError(t, err)
+		requireObject(t, repo.Updated, 1, "before", 1)
+	})
+
+	t.Run("status update changes only status", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: crontabMock(t, 1, "before"),
+		}
+		body, err := crontabMock(t, 2, "after").MarshalJSON()
+		require.NoError(t, err)
+		_, err = buildHandler(repo).StatusPutHandler(vars, body)
+		require.NoError(t, err)
+		updated := requireObject(t, repo.StatusUpdated, 1, "after", 1)
+		require.Equal(t, "1", updated.GetResourceVersion())
+		require.Nil(t, repo.Updated)
+	})
+
+	t.Run("status patch changes only status", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: crontabMock(t, 1, "before"),
+		}
+		patch := []byte(`{"spec":{"replicas":3},"status":{"lastScheduleTime":"after"}}`)
+		patchVars := Vars{ContentTypeVar: string(types.MergePatchType)}
+		for key, value := range vars {
+			patchVars[key] = value
+		}
+		_, err := buildHandler(repo).StatusPatchHandler(patchVars, patch)
+		require.NoError(t, err)
+		requireObject(t, repo.StatusUpdated, 1, "after", 1)
+	})
+
+	t.Run("status update records the field manager", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			ReadObject: crontabMock(t, 1, "before"),
+		}
+		body, err := crontabMock(t, 1, "after").MarshalJSON()
+		require.NoError(t, err)
+		managerVars := Vars{"fieldManager": "controller"}
+		for key, value := range vars {
+			managerVars[key] = value
+		}
+		_, err = buildHandler(repo).StatusPutHandler(managerVars, body)
+		require.NoError(t, err)
+		requireManagedBy(t, repo.StatusUpdated, "controller")
+	})
+
+	t.Run("status subresource not declared", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))},
+			ReadObject: crontabMock(t, 1, "before"),
+		}
+		_, err := buildHandler(repo).StatusGetHandler(vars, nil)
+		require.Equal(t, ResourceNotFoundErr, err)
+	})
+
+	t.Run("status subresource is discovered", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{CRDs: crds}
+		obj, err := buildHandler(repo).APIResourceLister(vars, nil)
+		require.NoError(t, err)
+		list, ok := obj.(*metav1.APIResourceList)
+		require.True(t, ok)
+		names := []string{}
+		for _, resource := range list.APIResources {
+			names = append(names, resource.Name)
+		}
+		require.Equal(t, []string{"crontabs", "crontabs/status"}, names)
+	})
+}
@@ -0,0 +1,204 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// defaultPrinterColumns columns shown for resources not declaring additional printer columns.
+var defaultPrinterColumns = []extv1.CustomResourceColumnDefinition{{
+	Name:        "Age",
+	Type:        "date",
+	Description: metav1.ObjectMeta{}.SwaggerDoc()["creationTimestamp"],
+	JSONPath:    ".metadata.creationTimestamp",
+}}
+
+// printerColumns returns the additional printer columns declared by the CRD for the version
+// informed, or the default columns when none is declared.
+func printerColumns(
+	crd *extv1.CustomResourceDefinition,
+	version string,
+) []extv1.CustomResourceColumnDefinition {
+	if crd == nil {
+		return defaultPrinterColumns
+	}
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name == version && len(crdVersion.AdditionalPrinterColumns) > 0 {
+			return crdVersion.AdditionalPrinterColumns
+		}
+	}
+	return defaultPrinterColumns
+}
+
+// indexedField matches a field followed by an array index, as in "containers[0]".
+var indexedField = regexp.MustCompile(`^(.*)\[(\d+)\]$`)
+
+// jsonPathValue returns the value found on a simple JSON path, as in ".spec.containers[0].image",
+// and whether it has been found.
+func jsonPathValue(obj map[string]interface{}, jsonPath string) (interface{}, bool) {
+	var current interface{} = obj
+	for _, field := range fieldPath(jsonPath) {
+		index := -1
+		if match := indexedField.FindStringSubmatch(field); match != nil {
+			field = match[1]
+			index, _ = strconv.Atoi(match[2])
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[field]; !ok {
+			return nil, false
+		}
+		if index < 0 {
+			continue
+		}
+		items, ok := current.([]interface{})
+		if !ok || index >= len(items) {
+			return nil, false
+		}
+		current = items[index]
+	}
+	return current, true
+}
+
+// cellValue formats the value found for a column according to its type, dates are shown as the
+// time elapsed since then. It returns nil when the value is not found.
+func cellValue(
+	column extv1.CustomResourceColumnDefinition,
+	u *unstructured.Unstructured,
+) interface{} {
+	value, found := jsonPathValue(u.Object, column.JSONPath)
+	if !found || value == nil {
+		return nil
+	}
+	switch column.Type {
+	case "date":
+		timestamp, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			return "<unknown>"
+		}
+		return duration.HumanDuration(time.Since(parsed))
+	case "string":
+		if text, ok := value.(string); ok {
+			return text
+		}
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+		return nil
+	}
+	return value
+}
+
+// partialObjectMetadata returns the metadata of the object, as carried on table rows.
+func partialObjectMetadata(u *unstructured.Unstructured) (runtime.RawExtension, error) {
+	partial := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: metav1.SchemeGroupVersion.String(),
+			Kind:       "PartialObjectMetadata",
+		},
+	}
+	metadata, _, err := unstructured.NestedMap(u.Object, "metadata")
+	if err != nil {
+		return runtime.RawExtension{}, err
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(metadata, &partial.ObjectMeta)
+	if err != nil {
+		return runtime.RawExtension{}, err
+	}
+	data, err := json.Marshal(partial)
+	if err != nil {
+		return runtime.RawExtension{}, err
+	}
+	return runtime.RawExtension{Raw: data}, nil
+}
+
+// newTable builds a table out of objects, having name as first column followed by the CRD printer
+// columns. Rows carry the metadata of their objects. It can return errors on extracting metadata.
+func newTable(
+	crd *extv1.CustomResourceDefinition,
+	version string,
+	objects []unstructured.Unstructured,
+) (*metav1.Table, error) {
+	columns := printerColumns(crd, version)
+	table := &metav1.Table{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: metav1.SchemeGroupVersion.String(),
+			Kind:       "Table",
+		},
+		ColumnDefinitions: []metav1.TableColumnDefinition{{
+			Name:        "Name",
+			Type:        "string",
+			Format:      "name",
+			Description: metav1.ObjectMeta{}.SwaggerDoc()["name"],
+		}},
+		Rows: make([]metav1.TableRow, 0, len(objects)),
+	}
+	for _, column := range columns {
+		table.ColumnDefinitions = append(table.ColumnDefinitions, metav1.TableColumnDefinition{
+			Name:        column.Name,
+			Type:        column.Type,
+			Format:      column.Format,
+			Description: column.Description,
+			Priority:    column.Priority,
+		})
+	}
+
+	for i := range objects {
+		u := &objects[i]
+		row := metav1.TableRow{Cells: []interface{}{u.GetName()}}
+		for _, column := range columns {
+			row.Cells = append(row.Cells, cellValue(column, u))
+		}
+		object, err := partialObjectMetadata(u)
+		if err != nil {
+			return nil, fmt.Errorf("unable to extract metadata of '%s': %w", u.GetName(), err)
+		}
+		row.Object = object
+		table.Rows = append(table.Rows, row)
+	}
+	return table, nil
+}
+
+// tableForObject builds a table with a single row for the object informed.
+func tableForObject(
+	crd *extv1.CustomResourceDefinition,
+	version string,
+	u *unstructured.Unstructured,
+) (*metav1.Table, error) {
+	table, err := newTable(crd, version, []unstructured.Unstructured{*u})
+	if err != nil {
+		return nil, err
+	}
+	table.ResourceVersion = u.GetResourceVersion()
+	return table, nil
+}
+
+// tableForList builds a table with a row per item in the list informed.
+func tableForList(
+	crd *extv1.CustomResourceDefinition,
+	version string,
+	list *unstructured.UnstructuredList,
+) (*metav1.Table, error) {
+	table, err := newTable(crd, version, list.Items)
+	if err != nil {
+		return nil, err
+	}
+	table.ResourceVersion = list.GetResourceVersion()
+	table.Continue = list.GetContinue()
+	return table, nil
+}
@@ -0,0 +1,302 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ghodss/yaml"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/isutton/orchid/pkg/orchid/fieldmanager"
+	"github.com/isutton/orchid/pkg/orchid/repository"
+)
+
+var (
+	// transactionAPIResource orchid's own resource to write several objects atomically.
+	transactionAPIResource = metav1.APIResource{
+		Group:        "orchid.io",
+		Kind:         "Transaction",
+		Name:         "transactions",
+		SingularName: "transaction",
+		Verbs:        []string{"create"},
+		Version:      "v1alpha1",
+	}
+
+	transactionGroupVersion = transactionAPIResource.Group + "/" + transactionAPIResource.Version
+)
+
+const (
+	// OperationRolledBack cause type of operations executed before the failing one.
+	OperationRolledBack metav1.CauseType = "OperationRolledBack"
+	// OperationFailed cause type of the operation causing the transaction to roll back.
+	OperationFailed metav1.CauseType = "OperationFailed"
+	// OperationNotExecuted cause type of operations after the failing one.
+	OperationNotExecuted metav1.CauseType = "OperationNotExecuted"
+)
+
+// TransactionOperation a single write operation, and the object it applies to.
+type TransactionOperation struct {
+	Type   repository.OperationType   `json:"type"`
+	Object *unstructured.Unstructured `json:"object"`
+}
+
+// TransactionSpec the ordered list of operations to be executed.
+type TransactionSpec struct {
+	Operations []TransactionOperation `json:"operations"`
+}
+
+// TransactionStatus the result of each operation, in the same order, carrying the object stored or
+// removed.
+type TransactionStatus struct {
+	Operations []TransactionOperation `json:"operations,omitempty"`
+}
+
+// Transaction represents a set of write operations, spanning different types and namespaces,
+// committed or rolled back together.
+type Transaction struct {
+	metav1.TypeMeta `json:",inline"`
+	Spec            TransactionSpec   `json:"spec"`
+	Status          TransactionStatus `json:"status,omitempty"`
+}
+
+// operationVars returns vars describing the object of an operation, as if informed in the route.
+// The resource plural is taken from the CRD describing the object, and is empty when unknown.
+func operationVars(u *unstructured.Unstructured, crd *extv1.CustomResourceDefinition) Vars {
+	gvk := u.GroupVersionKind()
+	resource := ""
+	switch {
+	case gvk == repository.CRDGVK:
+		resource = crdAPIResource.Name
+	case crd != nil:
+		resource = crd.Spec.Names.Plural
+	}
+	return Vars{
+		"group":     gvk.Group,
+		"version":   gvk.Version,
+		"resource":  resource,
+		"namespace": u.GetNamespace(),
+		"name":      u.GetName(),
+	}
+}
+
+// declaredInTransactionErr rejects objects of a kind declared by a CRD created in the same
+// transaction, since its schema is only registered once the transaction is committed.
+func declaredInTransactionErr(u *unstructured.Unstructured) error {
+	return apierrors.NewInvalid(u.GroupVersionKind().GroupKind(), u.GetName(), field.ErrorList{
+		field.Forbidden(field.NewPath("kind"), fmt.Sprintf(
+			"kind '%s' is declared by a CRD created in the same transaction, objects of this "+
+				"kind must be written in a later transaction", u.GetKind())),
+	})
+}
+
+// operationCRD finds the CRD describing the object of an operation, where CRD objects themselves
+// have none. It returns ResourceNotFoundErr when no CRD describes the object, and errors from
+// listing CRDs.
+func (h *APIResourceHandler) operationCRD(
+	u *unstructured.Unstructured,
+) (*extv1.CustomResourceDefinition, error) {
+	if u.GroupVersionKind() == repository.CRDGVK {
+		return nil, nil
+	}
+	crd, err := h.createCRD(Vars{}, u)
+	if err != nil {
+		return nil, err
+	}
+	if crd == nil {
+		return nil, ResourceNotFoundErr
+	}
+	return crd, nil
+}
+
+// prepareOperation validates the operation object, and records its managed fields on behalf of the
+// field manager informed in vars. Generation, status and scope are handled as in single object
+// requests: namespaced objects require a namespace, while cluster scoped ones have it removed.
+// Objects of kinds declared by CRDs created earlier in the transaction are rejected. The CRD
+// describing the object is returned alongside the operation, when found.
+func (h *APIResourceHandler) prepareOperation(
+	vars Vars,
+	operation TransactionOperation,
+	declared map[schema.GroupKind]bool,
+) (repository.Operation, *extv1.CustomResourceDefinition, error) {
+	u := operation.Object
+	if u == nil {
+		return repository.Operation{}, nil, apierrors.NewBadRequest(
+			"operation object is required")
+	}
+	if declared[u.GroupVersionKind().GroupKind()] {
+		return repository.Operation{}, nil, declaredInTransactionErr(u)
+	}
+	crd, err := h.operationCRD(u)
+	if err != nil {
+		return repository.Operation{}, nil, err
+	}
+	// operations are addressed by their objects alone, there is no namespace in the route
+	if err = matchNamespace(Vars{}, crd, u); err != nil {
+		return repository.Operation{}, crd, err
+	}
+
+	switch operation.Type {
+	case repository.CreateOperation:
+		if err := h.validator.Validate(u); err != nil {
+			return repository.Operation{}, crd, err
+		}
+		if err := fieldmanager.Update(nil, u, vars.GetFieldManager()); err != nil {
+			return repository.Operation{}, crd, err
+		}
+		prepareCreate(crd, u)
+	case repository.UpdateOperation:
+		if err := h.validator.Validate(u); err != nil {
+			return repository.Operation{}, crd, err
+		}
+		namespacedName := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
+		current, err := h.repo.Read(u.GroupVersionKind(), namespacedName)
+		if err != nil {
+			return repository.Operation{}, crd, err
+		}
+		if err = prepareUpdate(crd, current, u); err != nil {
+			return repository.Operation{}, crd, err
+		}
+		if err = fieldmanager.Update(current, u, vars.GetFieldManager()); err != nil {
+			return repository.Operation{}, crd, err
+		}
+	case repository.DeleteOperation:
+	default:
+		return repository.Operation{}, crd, apierrors.NewBadRequest(
+			fmt.Sprintf("unknown operation type '%s'", operation.Type))
+	}
+	return repository.Operation{Type: operation.Type, Object: u}, crd, nil
+}
+
+// transactionError reports the outcome of every operation when one of them fails, using the failing
+// operation status code and reason when known. The CRDs describing the objects are informed in
+// the same order as operations, being nil when unknown.
+func transactionError(
+	operations []TransactionOperation,
+	crds []*extv1.CustomResourceDefinition,
+	opErr *repository.OperationErr,
+) error {
+	failed := operations[opErr.Index]
+	message := fmt.Sprintf(
+		"transaction rolled back, operation %d failed: %s", opErr.Index, opErr.Err)
+	status := metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusInternalServerError,
+		Reason:  metav1.StatusReasonInternalError,
+		Message: message,
+		Details: &metav1.StatusDetails{
+			Group: transactionAPIResource.Group,
+			Kind:  transactionAPIResource.Name,
+		},
+	}
+	if failed.Object != nil {
+		var crd *extv1.CustomResourceDefinition
+		if opErr.Index < len(crds) {
+			crd = crds[opErr.Index]
+		}
+		err := asStatusError(opErr.Err, operationVars(failed.Object, crd))
+		if statusErr, ok := err.(apierrors.APIStatus); ok {
+			status.Code = statusErr.Status().Code
+			status.Reason = statusErr.Status().Reason
+		}
+	}
+
+	for i := range operations {
+		cause := metav1.StatusCause{Field: fmt.Sprintf("spec.operations[%d]", i)}
+		switch {
+		case i < opErr.Index:
+			cause.Type = OperationRolledBack
+			cause.Message = "operation rolled back"
+		case i == opErr.Index:
+			cause.Type = OperationFailed
+			cause.Message = opErr.Err.Error()
+		default:
+			cause.Type = OperationNotExecuted
+			cause.Message = "operation not executed"
+		}
+		status.Details.Causes = append(status.Details.Causes, cause)
+	}
+	return &apierrors.StatusError{ErrStatus: status}
+}
+
+// TransactionPostHandler executes the operations informed in a Transaction, in order, committing
+// them atomically. On success the Transaction is returned with the objects stored in its status. On
+// failure everything is rolled back, and the outcome of each operation is reported.
+func (h *APIResourceHandler) TransactionPostHandler(vars Vars, body []byte) (runtime.Object, error) {
+	if len(body) == 0 {
+		return nil, BodyEmptyErr
+	}
+	jsonBody, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	txn := &Transaction{}
+	if err = json.Unmarshal(jsonBody, txn); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	if len(txn.Spec.Operations) == 0 {
+		return nil, apierrors.NewBadRequest("transaction has no operations")
+	}
+
+	operations := make([]repository.Operation, 0, len(txn.Spec.Operations))
+	crds := make([]*extv1.CustomResourceDefinition, 0, len(txn.Spec.Operations))
+	// kinds declared by CRDs created in this transaction, only served once it is committed
+	declared := map[schema.GroupKind]bool{}
+	for i, operation := range txn.Spec.Operations {
+		prepared, crd, err := h.prepareOperation(vars, operation, declared)
+		crds = append(crds, crd)
+		if err != nil {
+			return nil, transactionError(
+				txn.Spec.Operations, crds, &repository.OperationErr{Index: i, Err: err})
+		}
+		operations = append(operations, prepared)
+
+		gvk := prepared.Object.GroupVersionKind()
+		if prepared.Type != repository.CreateOperation || gvk != repository.CRDGVK {
+			continue
+		}
+		created, err := repository.ExtractCRD(prepared.Object.Object)
+		if err != nil {
+			return nil, transactionError(
+				txn.Spec.Operations, crds, &repository.OperationErr{Index: i, Err: err})
+		}
+		declared[schema.GroupKind{Group: created.Spec.Group, Kind: created.Spec.Names.Kind}] = true
+	}
+
+	objects, err := h.repo.Transaction(operations)
+	if err != nil {
+		var opErr *repository.OperationErr
+		if errors.As(err, &opErr) {
+			return nil, transactionError(txn.Spec.Operations, crds, opErr)
+		}
+		return nil, err
+	}
+
+	txn.APIVersion = transactionGroupVersion
+	txn.Kind = transactionAPIResource.Kind
+	for i, u := range objects {
+		txn.Status.Operations = append(txn.Status.Operations, TransactionOperation{
+			Type:   txn.Spec.Operations[i].Type,
+			Object: u,
+		})
+	}
+
+	// representing the transaction as unstructured, in order to be handled as a regular object
+	txnJSON, err := json.Marshal(txn)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err = u.UnmarshalJSON(txnJSON); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
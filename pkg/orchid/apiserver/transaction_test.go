@@ -0,0 +1,214 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/repository"
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+func TestAPIResourceHandler_TransactionPostHandler(t *testing.T) {
+	logger := klogr.New()
+	crds := []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))}
+
+	// body builds a transaction manifest with the operations informed, as type and object pairs
+	body := func(operations ...string) string {
+		manifest := "apiVersion: orchid.io/v1alpha1\nkind: Transaction\nspec:\n  operations:\n"
+		for i := 0; i < len(operations); i += 2 {
+			manifest += "  - type: " + operations[i] + "\n    object:\n"
+			for _, line := range strings.Split(operations[i+1], "\n") {
+				manifest += "      " + line + "\n"
+			}
+		}
+		return manifest
+	}
+	cr := string(util.ReadAsset(ValidCRAsset))
+	invalidCR := string(util.ReadAsset(InvalidCRAsset))
+
+	type args struct {
+		body           string
+		repository     *TestResourcePostHandlerRepository
+		wantOperations int
+		wantCode       int32
+		wantCauses     []metav1.CauseType
+	}
+
+	assertTransaction := func(args args) func(*testing.T) {
+		return func(t *testing.T) {
+			h := &APIResourceHandler{
+				logger:    logger,
+				repo:      args.repository,
+				validator: validation.NewRepositoryValidator(args.repository),
+			}
+			router := mux.NewRouter()
+			h.Register(router)
+
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/apis/orchid.io/v1alpha1/transactions",
+				strings.NewReader(args.body))
+			router.ServeHTTP(recorder, req)
+
+			if args.wantCode != 0 {
+				require.Equal(t, int(args.wantCode), recorder.Code)
+				status := &metav1.Status{}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), status))
+				if len(args.wantCauses) == 0 {
+					return
+				}
+				require.NotNil(t, status.Details)
+				causeTypes := []metav1.CauseType{}
+				for _, cause := range status.Details.Causes {
+					causeTypes = append(causeTypes, cause.Type)
+				}
+				require.Equal(t, args.wantCauses, causeTypes)
+				return
+			}
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Len(t, args.repository.Operations, args.wantOperations)
+
+			txn := &Transaction{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), txn))
+			require.Equal(t, "Transaction", txn.Kind)
+			require.Len(t, txn.Status.Operations, args.wantOperations)
+		}
+	}
+
+	t.Run("operations are executed", assertTransaction(args{
+		body:           body("create", cr, "delete", cr),
+		repository:     &TestResourcePostHandlerRepository{CRDs: crds},
+		wantOperations: 2,
+	}))
+
+	t.Run("operation is invalid", assertTransaction(args{
+		body:       body("create", cr, "create", invalidCR, "delete", cr),
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		wantCode:   http.StatusUnprocessableEntity,
+		wantCauses: []metav1.CauseType{OperationRolledBack, OperationFailed, OperationNotExecuted},
+	}))
+
+	t.Run("operation fails on repository", assertTransaction(args{
+		body: body("create", cr, "delete", cr),
+		repository: &TestResourcePostHandlerRepository{
+			CRDs: crds,
+			TransactionError: &repository.OperationErr{
+				Index: 1,
+				Err:   repository.ObjectNotFoundErr,
+			},
+		},
+		wantCode:   http.StatusNotFound,
+		wantCauses: []metav1.CauseType{OperationRolledBack, OperationFailed},
+	}))
+
+	t.Run("unknown operation type", assertTransaction(args{
+		body:       body("merge", cr),
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		wantCode:   http.StatusBadRequest,
+		wantCauses: []metav1.CauseType{OperationFailed},
+	}))
+
+	t.Run("no operations", assertTransaction(args{
+		body:       body(),
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		wantCode:   http.StatusBadRequest,
+	}))
+
+	t.Run("status and generation are prepared", func(t *testing.T) {
+		desired, err := yaml.Marshal(crontabMock(t, 2, "desired").Object)
+		require.NoError(t, err)
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       []unstructured.Unstructured{statusCRDMock(t)},
+			ReadObject: crontabMock(t, 1, "current"),
+		}
+		assertTransaction(args{
+			body:           body("create", string(desired), "update", string(desired)),
+			repository:     repo,
+			wantOperations: 2,
+		})(t)
+
+		created := repo.Operations[0].Object
+		_, found, err := unstructured.NestedFieldNoCopy(created.Object, "status")
+		require.NoError(t, err)
+		require.False(t, found)
+		require.Equal(t, int64(1), created.GetGeneration())
+
+		updated := repo.Operations[1].Object
+		lastScheduleTime, _, err := unstructured.NestedString(
+			updated.Object, "status", "lastScheduleTime")
+		require.NoError(t, err)
+		require.Equal(t, "current", lastScheduleTime)
+		require.Equal(t, int64(2), updated.GetGeneration())
+	})
+
+	t.Run("namespace is required", assertTransaction(args{
+		body:       body("create", cr, "delete", strings.Replace(cr, "  namespace: example\n", "", 1)),
+		repository: &TestResourcePostHandlerRepository{CRDs: crds},
+		wantCode:   http.StatusBadRequest,
+		wantCauses: []metav1.CauseType{OperationRolledBack, OperationFailed},
+	}))
+
+	t.Run("namespace is removed from cluster scoped objects", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs: []unstructured.Unstructured{clusterCRDMock(t)},
+		}
+		assertTransaction(args{
+			body:           body("create", cr, "delete", cr),
+			repository:     repo,
+			wantOperations: 2,
+		})(t)
+		for _, operation := range repo.Operations {
+			require.Empty(t, operation.Object.GetNamespace())
+		}
+	})
+
+	t.Run("unknown resource", assertTransaction(args{
+		body:       body("delete", cr),
+		repository: &TestResourcePostHandlerRepository{},
+		wantCode:   http.StatusNotFound,
+		wantCauses: []metav1.CauseType{OperationFailed},
+	}))
+
+	t.Run("kind declared by a CRD created in the same transaction", assertTransaction(args{
+		body: body("create", string(util.ReadAsset(ValidCRDAsset)), "create", cr),
+		repository: &TestResourcePostHandlerRepository{
+			CRDs: []unstructured.Unstructured{*(util.LoadUnstructured(CustomResourceDefintionAsset))},
+		},
+		wantCode:   http.StatusUnprocessableEntity,
+		wantCauses: []metav1.CauseType{OperationRolledBack, OperationFailed},
+	}))
+
+	t.Run("operation vars resolve the resource plural", func(t *testing.T) {
+		crd, err := repository.ExtractCRD(crds[0].Object)
+		require.NoError(t, err)
+		vars := operationVars(util.LoadUnstructured(ValidCRAsset), crd)
+		require.Equal(t, crd.Spec.Names.Plural, vars["resource"])
+
+		vars = operationVars(util.LoadUnstructured(ValidCRDAsset), nil)
+		require.Equal(t, "customresourcedefinitions", vars["resource"])
+	})
+
+	t.Run("body empty", func(t *testing.T) {
+		h := &APIResourceHandler{logger: logger, repo: &TestResourcePostHandlerRepository{}}
+		_, err := h.TransactionPostHandler(Vars{}, nil)
+		require.Equal(t, BodyEmptyErr, err)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		h := &APIResourceHandler{logger: logger, repo: &TestResourcePostHandlerRepository{}}
+		_, err := h.TransactionPostHandler(Vars{}, []byte("spec: ["))
+		require.True(t, apierrors.IsBadRequest(err))
+	})
+}
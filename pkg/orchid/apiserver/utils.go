@@ -3,19 +3,52 @@ package apiserver
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/isutton/orchid/pkg/orchid/fieldmanager"
+	"github.com/isutton/orchid/pkg/orchid/repository"
+	"github.com/isutton/orchid/pkg/orchid/validation"
 )
 
 // Vars is equivalent to mux.Vars.
 //
 // Don't know what I'm doing here yet. This is meant to contain information encoded in the route
-// such as group, version, resource and object name
+// such as group, version, resource and object name, and query string parameters
 type Vars map[string]string
 
+// ContentTypeVar key in Vars holding the request's content-type header.
+const ContentTypeVar = "Content-Type"
+
+// UserAgentVar key in Vars holding the request's user-agent header.
+const UserAgentVar = "User-Agent"
+
+// requestVars returns the query string parameters, route variables, content-type, accept and
+// user-agent found in r, where route variables take precedence over query string parameters.
+func requestVars(r *http.Request) Vars {
+	vars := Vars{}
+	for key := range r.URL.Query() {
+		vars[key] = r.URL.Query().Get(key)
+	}
+	for key, value := range mux.Vars(r) {
+		vars[key] = value
+	}
+	vars[ContentTypeVar] = r.Header.Get(ContentTypeVar)
+	vars[AcceptVar] = r.Header.Get(AcceptVar)
+	vars[UserAgentVar] = r.Header.Get(UserAgentVar)
+	return vars
+}
+
 // GetAPIVersion returns the apiVersion encoded in v.
 func (v Vars) GetAPIVersion() (string, error) {
 	group, ok := v["group"]
@@ -31,6 +64,117 @@ func (v Vars) GetAPIVersion() (string, error) {
 	return group + "/" + version, nil
 }
 
+// GetNamespacedName returns the namespace and object name encoded in v.
+func (v Vars) GetNamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: v["namespace"], Name: v["name"]}
+}
+
+// GetGroupResource returns the group and resource encoded in v.
+func (v Vars) GetGroupResource() schema.GroupResource {
+	return schema.GroupResource{Group: v["group"], Resource: v["resource"]}
+}
+
+// GetListOptions returns the list options encoded in v's query string parameters. Limits which are
+// not integers are ignored.
+func (v Vars) GetListOptions() metav1.ListOptions {
+	limit, _ := strconv.ParseInt(v["limit"], 10, 64)
+	return metav1.ListOptions{
+		LabelSelector:   v["labelSelector"],
+		FieldSelector:   v["fieldSelector"],
+		ResourceVersion: v["resourceVersion"],
+		Limit:           limit,
+		Continue:        v["continue"],
+	}
+}
+
+// GetFieldManager returns the field manager informed as parameter, or derived from user-agent.
+func (v Vars) GetFieldManager() string {
+	if manager := v["fieldManager"]; manager != "" {
+		return manager
+	}
+	return fieldmanager.ManagerFromUserAgent(v[UserAgentVar])
+}
+
+// ResourceNotFoundErr returned when the resource informed in the route is not served.
+var ResourceNotFoundErr = errors.New("resource not found")
+
+// asStatusError converts known errors into Kubernetes API status errors, carrying the reason and
+// HTTP code expected by clients.
+func asStatusError(err error, vars Vars) error {
+	var conflicts fieldmanager.Conflicts
+	var validationErr *validation.ObjectValidationErr
+	switch {
+	case errors.As(err, &validationErr):
+		groupKind := validationErr.GVK.GroupKind()
+		return apierrors.NewInvalid(groupKind, validationErr.Name, validationErr.Errors)
+	case errors.Is(err, validation.GVKNotFoundErr):
+		return apierrors.NewNotFound(vars.GetGroupResource(), "")
+	case errors.Is(err, BodyEmptyErr), errors.Is(err, repository.InvalidSelectorErr),
+		errors.Is(err, repository.InvalidContinueErr):
+		return apierrors.NewBadRequest(err.Error())
+	case errors.Is(err, repository.ObjectAlreadyExistsErr):
+		statusErr := apierrors.NewAlreadyExists(vars.GetGroupResource(), vars["name"])
+		statusErr.ErrStatus.Message = err.Error()
+		return statusErr
+	case errors.Is(err, repository.ReferenceViolationErr):
+		return apierrors.NewConflict(vars.GetGroupResource(), vars["name"], err)
+	case errors.As(err, &conflicts):
+		causes := make([]metav1.StatusCause, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			causes = append(causes, metav1.StatusCause{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: fmt.Sprintf("conflict with \"%s\"", conflict.Manager),
+				Field:   conflict.Path.String(),
+			})
+		}
+		return apierrors.NewApplyConflict(causes, conflicts.Error())
+	case errors.Is(err, repository.ResourceVersionConflictErr):
+		return apierrors.NewConflict(vars.GetGroupResource(), vars["name"], err)
+	case errors.Is(err, repository.ObjectNotFoundErr):
+		return apierrors.NewNotFound(vars.GetGroupResource(), vars["name"])
+	case errors.Is(err, ResourceNotFoundErr):
+		return apierrors.NewNotFound(vars.GetGroupResource(), "")
+	case errors.Is(err, repository.NamespaceNotFoundErr):
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, vars["namespace"])
+	case errors.Is(err, repository.ResourceVersionExpiredErr):
+		return apierrors.NewResourceExpired(err.Error())
+	case errors.Is(err, repository.TwoPhaseCommitDisabledErr):
+		return apierrors.NewServiceUnavailable(err.Error())
+	case errors.Is(err, repository.IncompatibleSchemaChangeErr):
+		crdGroupKind := repository.CRDGVK.GroupKind()
+		return apierrors.NewInvalid(crdGroupKind, vars["name"], field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "versions"), err.Error()),
+		})
+	}
+	return err
+}
+
+// writeStatus writes the metav1.Status carried by informed status error as response body.
+func writeStatus(w http.ResponseWriter, statusErr apierrors.APIStatus) {
+	status := statusErr.Status()
+	status.Kind = "Status"
+	status.APIVersion = metav1.SchemeGroupVersion.Version
+
+	jsonStatus, err := json.Marshal(status)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	_, _ = w.Write(jsonStatus)
+}
+
+// writeError writes err as a metav1.Status response, carrying the reason and code of known errors,
+// and internal server error otherwise.
+func writeError(w http.ResponseWriter, vars Vars, err error) {
+	if statusErr, ok := asStatusError(err, vars).(apierrors.APIStatus); ok {
+		writeStatus(w, statusErr)
+		return
+	}
+	writeStatus(w, apierrors.NewInternalError(err))
+}
+
 // ResourceFunc maps vars to runtime.Object
 type ResourceFunc func(vars Vars, body []byte) (runtime.Object, error)
 
@@ -42,14 +186,15 @@ func Adapt(resourceFunc ResourceFunc) http.HandlerFunc {
 			w.WriteHeader(400)
 		}
 
-		// execute the given resourceFunc
-		obj, err := resourceFunc(mux.Vars(r), body)
+		// refusing requests which can't be answered before executing the given resourceFunc
+		vars := requestVars(r)
+		if err = acceptable(vars); err != nil {
+			writeError(w, vars, err)
+			return
+		}
+		obj, err := resourceFunc(vars, body)
 		if err != nil {
-			w.WriteHeader(500)
-			_, err = w.Write([]byte(err.Error()))
-			if err != nil {
-				// FIXME: this should be probably logged at application level?
-			}
+			writeError(w, vars, err)
 			return
 		}
 		if obj == nil {
@@ -57,20 +202,21 @@ func Adapt(resourceFunc ResourceFunc) http.HandlerFunc {
 			return
 		}
 
-		// TODO: implement proper serialization
-		jsonObj, err := json.Marshal(obj)
+		responseType, err := negotiate(vars, obj)
 		if err != nil {
-			// TODO: error treatment
+			writeError(w, vars, err)
+			return
 		}
-
-		// TODO: implement proper content type handling
-		w.Header().Add("Content-Type", "application/json")
-
+		data, err := encode(responseType, obj)
+		if err != nil {
+			writeError(w, vars, err)
+			return
+		}
+		w.Header().Add("Content-Type", responseType)
 		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(jsonObj)
+		_, err = w.Write(data)
 		if err != nil {
 			// TODO: error treatment, probably like K8s HandleError() function
 		}
-
 	}
 }
@@ -0,0 +1,139 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/isutton/orchid/pkg/orchid/repository"
+	"github.com/isutton/orchid/pkg/orchid/validation"
+)
+
+func TestAsStatusError(t *testing.T) {
+	vars := Vars{
+		"group":     "stable.example.com",
+		"version":   "v1",
+		"namespace": "example",
+		"resource":  "crontabs",
+		"name":      "example",
+	}
+
+	validationErr := &validation.ObjectValidationErr{
+		GVK:  schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "CronTab"},
+		Name: "example",
+		Errors: field.ErrorList{
+			field.Invalid(field.NewPath("spec", "replicas"), "str", "must be an integer"),
+			field.Required(field.NewPath("spec", "image"), ""),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   int32
+		wantReason metav1.StatusReason
+		wantCauses int
+	}{
+		{
+			name:       "invalid object",
+			err:        validationErr,
+			wantCode:   http.StatusUnprocessableEntity,
+			wantReason: metav1.StatusReasonInvalid,
+			wantCauses: 2,
+		},
+		{
+			name:       "unknown gvk",
+			err:        validation.GVKNotFoundErr,
+			wantCode:   http.StatusNotFound,
+			wantReason: metav1.StatusReasonNotFound,
+		},
+		{
+			name:       "body empty",
+			err:        BodyEmptyErr,
+			wantCode:   http.StatusBadRequest,
+			wantReason: metav1.StatusReasonBadRequest,
+		},
+		{
+			name:       "invalid selector",
+			err:        fmt.Errorf("%w: 'spec.array' is not a scalar", repository.InvalidSelectorErr),
+			wantCode:   http.StatusBadRequest,
+			wantReason: metav1.StatusReasonBadRequest,
+		},
+		{
+			name:       "invalid continue",
+			err:        fmt.Errorf("%w: last item is not informed", repository.InvalidContinueErr),
+			wantCode:   http.StatusBadRequest,
+			wantReason: metav1.StatusReasonBadRequest,
+		},
+		{
+			name:       "already exists",
+			err:        fmt.Errorf("%w: key exists", repository.ObjectAlreadyExistsErr),
+			wantCode:   http.StatusConflict,
+			wantReason: metav1.StatusReasonAlreadyExists,
+		},
+		{
+			name:       "resource version expired",
+			err:        fmt.Errorf("%w: (1)", repository.ResourceVersionExpiredErr),
+			wantCode:   http.StatusGone,
+			wantReason: metav1.StatusReasonExpired,
+		},
+		{
+			name:       "two-phase commit disabled",
+			err:        repository.TwoPhaseCommitDisabledErr,
+			wantCode:   http.StatusServiceUnavailable,
+			wantReason: metav1.StatusReasonServiceUnavailable,
+		},
+		{
+			name:       "reference violation",
+			err:        fmt.Errorf("%w: key is missing", repository.ReferenceViolationErr),
+			wantCode:   http.StatusConflict,
+			wantReason: metav1.StatusReasonConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusErr, ok := asStatusError(tt.err, vars).(apierrors.APIStatus)
+			require.True(t, ok)
+			status := statusErr.Status()
+			require.Equal(t, tt.wantCode, status.Code)
+			require.Equal(t, tt.wantReason, status.Reason)
+			if tt.wantCauses > 0 {
+				require.Len(t, status.Details.Causes, tt.wantCauses)
+			}
+		})
+	}
+
+	t.Run("unknown error", func(t *testing.T) {
+		err := errors.New("unknown")
+		require.Equal(t, err, asStatusError(err, vars))
+
+		recorder := httptest.NewRecorder()
+		writeError(recorder, vars, err)
+		require.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+		status := &metav1.Status{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), status))
+		require.Equal(t, "Status", status.Kind)
+		require.Equal(t, metav1.StatusReasonInternalError, status.Reason)
+	})
+}
+
+func TestVars_GetListOptions(t *testing.T) {
+	vars := Vars{"labelSelector": "app=example", "limit": "10", "continue": "token"}
+	options := vars.GetListOptions()
+	require.Equal(t, "app=example", options.LabelSelector)
+	require.Equal(t, int64(10), options.Limit)
+	require.Equal(t, "token", options.Continue)
+
+	vars["limit"] = "ten"
+	require.Zero(t, vars.GetListOptions().Limit)
+}
@@ -0,0 +1,75 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// StreamingNotSupportedErr returned when the response writer is not able to flush partial content.
+var StreamingNotSupportedErr = errors.New("streaming is not supported by response writer")
+
+// isWatch checks if the request asks for watching changes instead of listing objects.
+func isWatch(r *http.Request) bool {
+	watch := r.URL.Query().Get("watch")
+	return watch == "true" || watch == "1"
+}
+
+// ObjectWatcher streams the changes on objects of a resource, either in the namespace informed in
+// the route or in all namespaces, as a chunked sequence of JSON encoded metav1.WatchEvents. The
+// stream is kept open until the client disconnects.
+func (h *APIResourceHandler) ObjectWatcher(w http.ResponseWriter, r *http.Request) {
+	vars := requestVars(r)
+	logger := h.logger.WithValues("vars", vars)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, vars, StreamingNotSupportedErr)
+		return
+	}
+	gvk, err := h.resolveGVK(vars)
+	if err != nil {
+		writeError(w, vars, err)
+		return
+	}
+	events, err := h.repo.Watch(r.Context(), vars["namespace"], gvk, vars.GetListOptions())
+	if err != nil {
+		writeError(w, vars, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for event := range events {
+		raw, err := json.Marshal(event.Object)
+		if err != nil {
+			logger.Error(err, "Unable to encode watch event object")
+			continue
+		}
+		watchEvent := &metav1.WatchEvent{
+			Type:   string(event.Type),
+			Object: runtime.RawExtension{Raw: raw},
+		}
+		if err = encoder.Encode(watchEvent); err != nil {
+			logger.Error(err, "Unable to write watch event, client is likely gone")
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// ListOrWatch dispatches the request to ObjectWatcher when watching is requested, and to
+// ObjectLister otherwise.
+func (h *APIResourceHandler) ListOrWatch(w http.ResponseWriter, r *http.Request) {
+	if isWatch(r) {
+		h.ObjectWatcher(w, r)
+		return
+	}
+	Adapt(h.ObjectLister)(w, r)
+}
@@ -0,0 +1,110 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/repository"
+	"github.com/isutton/orchid/pkg/orchid/validation"
+	"github.com/isutton/orchid/test/util"
+)
+
+func TestAPIResourceHandler_ObjectWatcher(t *testing.T) {
+	logger := klogr.New()
+	crds := []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRDAsset))}
+
+	serve := func(repo *TestResourcePostHandlerRepository, url string) *httptest.ResponseRecorder {
+		h := &APIResourceHandler{
+			logger:    logger,
+			repo:      repo,
+			validator: validation.NewRepositoryValidator(repo),
+		}
+		router := mux.NewRouter()
+		h.Register(router)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+
+	t.Run("events are streamed", func(t *testing.T) {
+		cr := util.LoadUnstructured(ValidCRAsset)
+		repo := &TestResourcePostHandlerRepository{
+			CRDs: crds,
+			WatchEvents: []watch.Event{
+				{Type: watch.Added, Object: cr},
+				{Type: watch.Modified, Object: cr},
+				{Type: watch.Deleted, Object: cr},
+			},
+		}
+		recorder := serve(repo,
+			"/apis/stable.example.com/v1/namespaces/example/crontabs?watch=true&labelSelector=a=b")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.True(t, recorder.Flushed)
+		require.Equal(t, "example", repo.ListedNamespace)
+		require.Equal(t, "a=b", repo.ListedOptions.LabelSelector)
+
+		decoder := json.NewDecoder(recorder.Body)
+		types := []string{}
+		for {
+			event := metav1.WatchEvent{}
+			err := decoder.Decode(&event)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+
+			u := &unstructured.Unstructured{}
+			require.NoError(t, u.UnmarshalJSON(event.Object.Raw))
+			require.Equal(t, "example", u.GetName())
+			types = append(types, event.Type)
+		}
+		require.Equal(t, []string{"ADDED", "MODIFIED", "DELETED"}, types)
+	})
+
+	t.Run("resource is not served", func(t *testing.T) {
+		recorder := serve(&TestResourcePostHandlerRepository{},
+			"/apis/stable.example.com/v1/crontabs?watch=true")
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("field is not selectable", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:       crds,
+			WatchError: fmt.Errorf("%w: 'spec.array'", repository.InvalidSelectorErr),
+		}
+		recorder := serve(repo,
+			"/apis/stable.example.com/v1/crontabs?watch=true&fieldSelector=spec.array=a")
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, "spec.array=a", repo.ListedOptions.FieldSelector)
+
+		status := &metav1.Status{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), status))
+		require.Equal(t, metav1.StatusReasonBadRequest, status.Reason)
+	})
+
+	t.Run("list when not watching", func(t *testing.T) {
+		repo := &TestResourcePostHandlerRepository{
+			CRDs:    crds,
+			Objects: []unstructured.Unstructured{*(util.LoadUnstructured(ValidCRAsset))},
+		}
+		recorder := serve(repo, "/apis/stable.example.com/v1/crontabs?watch=false")
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		list := &unstructured.UnstructuredList{}
+		require.NoError(t, list.UnmarshalJSON(recorder.Body.Bytes()))
+		require.Equal(t, "CronTabList", list.GetKind())
+		require.Len(t, list.Items, 1)
+	})
+}
@@ -5,4 +5,6 @@ type Config struct {
 	Username string // postgresql username
 	Password string // postgresql password
 	Options  string // key=value set of libpq connection string options
+
+	AssembleJSON bool // assemble objects as JSON in the database, instead of the assembler
 }
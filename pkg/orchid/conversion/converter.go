@@ -0,0 +1,63 @@
+package conversion
+
+import (
+	"errors"
+	"fmt"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Converter converts objects of the same kind between the versions of a CRD.
+type Converter interface {
+	// Convert returns objects converted to the informed api-version, in the same order.
+	Convert(
+		objects []*unstructured.Unstructured,
+		apiVersion string,
+	) ([]*unstructured.Unstructured, error)
+}
+
+// UnsupportedStrategyErr returned when the CRD conversion strategy is not known.
+var UnsupportedStrategyErr = errors.New("unsupported conversion strategy")
+
+// noneConverter implements the "None" strategy, where only api-version is rewritten.
+type noneConverter struct{}
+
+// Convert copies objects rewriting their api-version.
+func (n *noneConverter) Convert(
+	objects []*unstructured.Unstructured,
+	apiVersion string,
+) ([]*unstructured.Unstructured, error) {
+	converted := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, u := range objects {
+		u = u.DeepCopy()
+		u.SetAPIVersion(apiVersion)
+		converted = append(converted, u)
+	}
+	return converted, nil
+}
+
+// NewNoneConverter instantiate the converter for "None" strategy.
+func NewNoneConverter() Converter {
+	return &noneConverter{}
+}
+
+// NewConverter instantiate the converter for the CRD's conversion strategy, where "None" is assumed
+// when not informed. It can return UnsupportedStrategyErr, and errors on configuring webhook
+// client.
+func NewConverter(crd *extv1.CustomResourceDefinition) (Converter, error) {
+	conversion := crd.Spec.Conversion
+	if conversion == nil {
+		return NewNoneConverter(), nil
+	}
+	switch conversion.Strategy {
+	case "", extv1.NoneConverter:
+		return NewNoneConverter(), nil
+	case extv1.WebhookConverter:
+		if conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil {
+			return nil, fmt.Errorf("%w: webhook configuration is missing", UnsupportedStrategyErr)
+		}
+		return newWebhookConverterForConfig(conversion.Webhook.ClientConfig)
+	}
+	return nil, fmt.Errorf("%w: '%s'", UnsupportedStrategyErr, conversion.Strategy)
+}
@@ -0,0 +1,144 @@
+package conversion
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newObject(apiVersion, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(1)},
+	}}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind("Complex")
+	u.SetName(name)
+	return u
+}
+
+// webhookStandIn serves conversion reviews, renaming "replicas" to "size" on the way to v1.
+func webhookStandIn(t *testing.T, result metav1.Status) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &extv1.ConversionReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(review))
+
+		response := &extv1.ConversionResponse{UID: review.Request.UID, Result: result}
+		for _, raw := range review.Request.Objects {
+			u := &unstructured.Unstructured{}
+			require.NoError(t, u.UnmarshalJSON(raw.Raw))
+			replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+			unstructured.RemoveNestedField(u.Object, "spec", "replicas")
+			require.NoError(t, unstructured.SetNestedField(u.Object, replicas, "spec", "size"))
+			u.SetAPIVersion(review.Request.DesiredAPIVersion)
+
+			converted, err := u.MarshalJSON()
+			require.NoError(t, err)
+			response.ConvertedObjects = append(
+				response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+		}
+		review.Response = response
+		require.NoError(t, json.NewEncoder(w).Encode(review))
+	}))
+}
+
+func TestConverter_None(t *testing.T) {
+	original := newObject("tests.example.com/v1alpha1", "name")
+	converted, err := NewNoneConverter().Convert(
+		[]*unstructured.Unstructured{original}, "tests.example.com/v1")
+	require.NoError(t, err)
+	require.Len(t, converted, 1)
+
+	assert.Equal(t, "tests.example.com/v1", converted[0].GetAPIVersion())
+	assert.Equal(t, original.Object["spec"], converted[0].Object["spec"])
+	assert.Equal(t, "tests.example.com/v1alpha1", original.GetAPIVersion())
+}
+
+func TestConverter_Webhook(t *testing.T) {
+	objects := []*unstructured.Unstructured{
+		newObject("tests.example.com/v1alpha1", "first"),
+		newObject("tests.example.com/v1alpha1", "second"),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		server := webhookStandIn(t, metav1.Status{Status: metav1.StatusSuccess})
+		defer server.Close()
+
+		converted, err := NewWebhookConverter(server.Client(), server.URL).
+			Convert(objects, "tests.example.com/v1")
+		require.NoError(t, err)
+		require.Len(t, converted, 2)
+		for i, u := range converted {
+			assert.Equal(t, "tests.example.com/v1", u.GetAPIVersion())
+			assert.Equal(t, objects[i].GetName(), u.GetName())
+			size, found, err := unstructured.NestedInt64(u.Object, "spec", "size")
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, int64(1), size)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		server := webhookStandIn(t, metav1.Status{Status: metav1.StatusFailure, Message: "nope"})
+		defer server.Close()
+
+		_, err := NewWebhookConverter(server.Client(), server.URL).
+			Convert(objects, "tests.example.com/v1")
+		require.True(t, errors.Is(err, WebhookErr))
+		assert.Contains(t, err.Error(), "nope")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := webhookStandIn(t, metav1.Status{Status: metav1.StatusSuccess})
+		server.Close()
+
+		_, err := NewWebhookConverter(server.Client(), server.URL).
+			Convert(objects, "tests.example.com/v1")
+		require.True(t, errors.Is(err, WebhookErr))
+	})
+}
+
+func TestConverter_NewConverter(t *testing.T) {
+	crd := &extv1.CustomResourceDefinition{}
+
+	t.Run("none", func(t *testing.T) {
+		converter, err := NewConverter(crd)
+		require.NoError(t, err)
+		assert.IsType(t, &noneConverter{}, converter)
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		url := "http://127.0.0.1:8443/convert"
+		crd.Spec.Conversion = &extv1.CustomResourceConversion{
+			Strategy: extv1.WebhookConverter,
+			Webhook: &extv1.WebhookConversion{
+				ClientConfig: &extv1.WebhookClientConfig{URL: &url},
+			},
+		}
+		converter, err := NewConverter(crd)
+		require.NoError(t, err)
+		assert.IsType(t, &webhookConverter{}, converter)
+	})
+
+	t.Run("webhook-service", func(t *testing.T) {
+		crd.Spec.Conversion.Webhook.ClientConfig = &extv1.WebhookClientConfig{
+			Service: &extv1.ServiceReference{Namespace: "ns", Name: "name"},
+		}
+		_, err := NewConverter(crd)
+		require.True(t, errors.Is(err, UnsupportedStrategyErr))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		crd.Spec.Conversion.Strategy = "Unknown"
+		_, err := NewConverter(crd)
+		require.True(t, errors.Is(err, UnsupportedStrategyErr))
+	})
+}
@@ -0,0 +1,140 @@
+package conversion
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+// WebhookErr returned when the conversion webhook can't be reached, or fails to convert.
+var WebhookErr = errors.New("conversion webhook failed")
+
+// webhookTimeout time limit on calling the conversion webhook.
+const webhookTimeout = 30 * time.Second
+
+// webhookConverter implements the "Webhook" strategy, sending objects to an external service as a
+// ConversionReview.
+type webhookConverter struct {
+	client *http.Client // http client
+	url    string       // webhook url
+}
+
+// review sends the conversion review request, and returns the response.
+func (w *webhookConverter) review(
+	request *extv1.ConversionRequest,
+) (*extv1.ConversionResponse, error) {
+	review := &extv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: extv1.SchemeGroupVersion.String(),
+			Kind:       "ConversionReview",
+		},
+		Request: request,
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", WebhookErr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status code %d", WebhookErr, resp.StatusCode)
+	}
+
+	review = &extv1.ConversionReview{}
+	if err = json.NewDecoder(resp.Body).Decode(review); err != nil {
+		return nil, fmt.Errorf("%w: %s", WebhookErr, err)
+	}
+	response := review.Response
+	if response == nil {
+		return nil, fmt.Errorf("%w: response is missing", WebhookErr)
+	}
+	if response.UID != request.UID {
+		return nil, fmt.Errorf("%w: response uid does not match request", WebhookErr)
+	}
+	if response.Result.Status != metav1.StatusSuccess {
+		return nil, fmt.Errorf("%w: %s", WebhookErr, response.Result.Message)
+	}
+	if len(response.ConvertedObjects) != len(request.Objects) {
+		return nil, fmt.Errorf("%w: expected %d objects, got %d",
+			WebhookErr, len(request.Objects), len(response.ConvertedObjects))
+	}
+	return response, nil
+}
+
+// Convert sends objects to the webhook, and makes sure converted objects are in api-version.
+func (w *webhookConverter) Convert(
+	objects []*unstructured.Unstructured,
+	apiVersion string,
+) ([]*unstructured.Unstructured, error) {
+	request := &extv1.ConversionRequest{
+		UID:               types.UID(rand.String(16)),
+		DesiredAPIVersion: apiVersion,
+		Objects:           make([]runtime.RawExtension, 0, len(objects)),
+	}
+	for _, u := range objects {
+		raw, err := u.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		request.Objects = append(request.Objects, runtime.RawExtension{Raw: raw})
+	}
+
+	response, err := w.review(request)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, raw := range response.ConvertedObjects {
+		u := &unstructured.Unstructured{}
+		if err = u.UnmarshalJSON(raw.Raw); err != nil {
+			return nil, fmt.Errorf("%w: %s", WebhookErr, err)
+		}
+		if u.GetAPIVersion() != apiVersion {
+			return nil, fmt.Errorf("%w: object converted to '%s' instead of '%s'",
+				WebhookErr, u.GetAPIVersion(), apiVersion)
+		}
+		converted = append(converted, u)
+	}
+	return converted, nil
+}
+
+// NewWebhookConverter instantiate the converter for "Webhook" strategy, calling the informed url
+// with client.
+func NewWebhookConverter(client *http.Client, url string) Converter {
+	return &webhookConverter{client: client, url: url}
+}
+
+// newWebhookConverterForConfig instantiate the webhook converter out of CRD's client configuration,
+// where only url is supported. When informed, CA bundle is employed to verify the webhook. It can
+// return UnsupportedStrategyErr when url is missing, and errors on reading CA bundle.
+func newWebhookConverterForConfig(config *extv1.WebhookClientConfig) (Converter, error) {
+	if config.URL == nil || *config.URL == "" {
+		return nil, fmt.Errorf("%w: webhook url is missing, service is not supported",
+			UnsupportedStrategyErr)
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	if len(config.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CABundle) {
+			return nil, errors.New("unable to read webhook CA bundle")
+		}
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return NewWebhookConverter(client, *config.URL), nil
+}
@@ -0,0 +1,271 @@
+package fieldmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FieldsTypeV1 the only fields type supported in managedFields entries.
+const FieldsTypeV1 = "FieldsV1"
+
+// DefaultManager manager name used when none is informed.
+const DefaultManager = "unknown"
+
+// Conflict represents a field owned by another manager, holding a different value.
+type Conflict struct {
+	Manager string // manager owning the field
+	Path    Path   // conflicting field path
+}
+
+// Conflicts is the error returned when applying changes on fields owned by other managers.
+type Conflicts []Conflict
+
+// Error returns the conflicting managers and fields.
+func (c Conflicts) Error() string {
+	messages := make([]string, 0, len(c))
+	for _, conflict := range c {
+		messages = append(messages,
+			fmt.Sprintf("conflict with '%s': %s", conflict.Manager, conflict.Path))
+	}
+	return fmt.Sprintf("Apply failed with %d conflict(s): %s",
+		len(c), strings.Join(messages, ", "))
+}
+
+// entry is a managedFields entry followed by its set of fields.
+type entry struct {
+	metav1.ManagedFieldsEntry
+	set Set
+}
+
+// entries is the sequence of managed fields entries of an object.
+type entries []*entry
+
+// get returns the entry for manager and operation, creating it when not found.
+func (e *entries) get(manager string, operation metav1.ManagedFieldsOperationType) *entry {
+	for _, existing := range *e {
+		if existing.Manager == manager && existing.Operation == operation {
+			return existing
+		}
+	}
+	created := &entry{
+		ManagedFieldsEntry: metav1.ManagedFieldsEntry{Manager: manager, Operation: operation},
+		set:                Set{},
+	}
+	*e = append(*e, created)
+	return created
+}
+
+// ownedByOthers checks if path is owned by any entry but the one informed.
+func (e entries) ownedByOthers(owner *entry, path Path) bool {
+	for _, existing := range e {
+		if existing != owner && existing.set.Has(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// managedFields serializes entries back to managedFields, skipping entries without fields. It
+// returns nil when no entries are left, so managedFields are removed from the object.
+func (e entries) managedFields() ([]metav1.ManagedFieldsEntry, error) {
+	var managedFields []metav1.ManagedFieldsEntry
+	for _, existing := range e {
+		if len(existing.set) == 0 {
+			continue
+		}
+		raw, err := existing.set.FieldsV1()
+		if err != nil {
+			return nil, err
+		}
+		managedFieldsEntry := existing.ManagedFieldsEntry
+		managedFieldsEntry.FieldsType = FieldsTypeV1
+		managedFieldsEntry.FieldsV1 = &metav1.FieldsV1{Raw: raw}
+		managedFields = append(managedFields, managedFieldsEntry)
+	}
+	return managedFields, nil
+}
+
+// decodeEntries reads managedFields entries from object, and their set of fields.
+func decodeEntries(u *unstructured.Unstructured) (entries, error) {
+	decoded := entries{}
+	if u == nil {
+		return decoded, nil
+	}
+	for _, managedFieldsEntry := range u.GetManagedFields() {
+		var raw []byte
+		if managedFieldsEntry.FieldsV1 != nil {
+			raw = managedFieldsEntry.FieldsV1.Raw
+		}
+		set, err := NewSetFromFieldsV1(raw)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, &entry{ManagedFieldsEntry: managedFieldsEntry, set: set})
+	}
+	return decoded, nil
+}
+
+// touch updates entry's version and time.
+func touch(e *entry, apiVersion string) {
+	now := metav1.Now()
+	e.APIVersion = apiVersion
+	e.Time = &now
+}
+
+// sameValue compares the value found on path in both objects, by their JSON representation in
+// order to ignore differences in numeric types.
+func sameValue(a, b map[string]interface{}, path Path) bool {
+	aValue, aFound, _ := unstructured.NestedFieldNoCopy(a, path...)
+	bValue, bFound, _ := unstructured.NestedFieldNoCopy(b, path...)
+	if aFound != bFound {
+		return false
+	}
+	aJSON, _ := json.Marshal(aValue)
+	bJSON, _ := json.Marshal(bValue)
+	return string(aJSON) == string(bJSON)
+}
+
+// Apply merges the applied configuration on the current object on behalf of manager, following
+// server-side apply semantics. Fields applied are owned by manager, fields previously applied and
+// now omitted are removed unless owned by others. When applied fields are owned by other managers
+// with different values, Conflicts are returned, unless force is set, on which case ownership is
+// transferred to manager. Current object may be nil, when it does not exist yet.
+func Apply(
+	current *unstructured.Unstructured,
+	applied *unstructured.Unstructured,
+	manager string,
+	force bool,
+) (*unstructured.Unstructured, error) {
+	managed, err := decodeEntries(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *unstructured.Unstructured
+	if current == nil {
+		result = applied.DeepCopy()
+	} else {
+		result = current.DeepCopy()
+	}
+
+	appliedSet := NewSetFromObject(applied.Object)
+	owner := managed.get(manager, metav1.ManagedFieldsOperationApply)
+
+	// inspecting other managers looking for conflicts, fields holding the same value are shared
+	conflicts := Conflicts{}
+	for _, other := range managed {
+		if other == owner {
+			continue
+		}
+		for _, path := range appliedSet.Paths() {
+			if !other.set.Has(path) || sameValue(result.Object, applied.Object, path) {
+				continue
+			}
+			if force {
+				other.set.Delete(path)
+				continue
+			}
+			conflicts = append(conflicts, Conflict{Manager: other.Manager, Path: path})
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+
+	// removing fields no longer part of manager's configuration
+	for _, path := range owner.set.Paths() {
+		if appliedSet.Has(path) || managed.ownedByOthers(owner, path) {
+			continue
+		}
+		unstructured.RemoveNestedField(result.Object, path...)
+	}
+	// setting applied fields on the resulting object
+	for _, path := range appliedSet.Paths() {
+		value, _, err := unstructured.NestedFieldCopy(applied.Object, path...)
+		if err != nil {
+			return nil, err
+		}
+		if err = unstructured.SetNestedField(result.Object, value, path...); err != nil {
+			return nil, err
+		}
+	}
+
+	owner.set = appliedSet
+	touch(owner, applied.GetAPIVersion())
+
+	managedFields, err := managed.managedFields()
+	if err != nil {
+		return nil, err
+	}
+	result.SetManagedFields(managedFields)
+	return result, nil
+}
+
+// Update records manager as owner of the fields changed between current and updated objects,
+// removing them from other managers. Current object may be nil when updated is being created. The
+// managedFields informed in the updated object are replaced by the ones calculated.
+func Update(current, updated *unstructured.Unstructured, manager string) error {
+	managed, err := decodeEntries(current)
+	if err != nil {
+		return err
+	}
+	if manager == "" {
+		manager = DefaultManager
+	}
+
+	currentSet := Set{}
+	var currentObj map[string]interface{}
+	if current != nil {
+		currentSet = NewSetFromObject(current.Object)
+		currentObj = current.Object
+	}
+	updatedSet := NewSetFromObject(updated.Object)
+
+	owner := managed.get(manager, metav1.ManagedFieldsOperationUpdate)
+	changed := false
+
+	// fields added or modified are transferred to manager
+	for _, path := range updatedSet.Paths() {
+		if currentSet.Has(path) && sameValue(currentObj, updated.Object, path) {
+			continue
+		}
+		for _, other := range managed {
+			other.set.Delete(path)
+		}
+		owner.set.Insert(path)
+		changed = true
+	}
+	// fields removed are no longer owned by anyone
+	for _, path := range currentSet.Paths() {
+		if updatedSet.Has(path) {
+			continue
+		}
+		for _, other := range managed {
+			other.set.Delete(path)
+		}
+		changed = true
+	}
+	if changed {
+		touch(owner, updated.GetAPIVersion())
+	}
+
+	managedFields, err := managed.managedFields()
+	if err != nil {
+		return err
+	}
+	updated.SetManagedFields(managedFields)
+	return nil
+}
+
+// ManagerFromUserAgent returns the default manager name out of a user-agent, as in its prefix.
+func ManagerFromUserAgent(userAgent string) string {
+	prefix := strings.Split(userAgent, "/")[0]
+	if prefix == "" {
+		return DefaultManager
+	}
+	return prefix
+}
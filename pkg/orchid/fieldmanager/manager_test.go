@@ -0,0 +1,113 @@
+package fieldmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func cronTab(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "stable.example.com/v1",
+		"kind":       "CronTab",
+		"metadata": map[string]interface{}{
+			"name":      "example",
+			"namespace": "example",
+		},
+		"spec": spec,
+	}}
+}
+
+func managerEntry(
+	t *testing.T,
+	u *unstructured.Unstructured,
+	manager string,
+	operation metav1.ManagedFieldsOperationType,
+) Set {
+	for _, managedFieldsEntry := range u.GetManagedFields() {
+		if managedFieldsEntry.Manager != manager || managedFieldsEntry.Operation != operation {
+			continue
+		}
+		require.Equal(t, FieldsTypeV1, managedFieldsEntry.FieldsType)
+		require.NotNil(t, managedFieldsEntry.FieldsV1)
+		set, err := NewSetFromFieldsV1(managedFieldsEntry.FieldsV1.Raw)
+		require.NoError(t, err)
+		return set
+	}
+	return nil
+}
+
+func TestManager_Apply(t *testing.T) {
+	applied := cronTab(map[string]interface{}{"image": "image:v1", "replicas": int64(1)})
+
+	created, err := Apply(nil, applied, "gitops", false)
+	require.NoError(t, err)
+	set := managerEntry(t, created, "gitops", metav1.ManagedFieldsOperationApply)
+	require.NotNil(t, set)
+	assert.True(t, set.Has(Path{"spec", "image"}))
+	assert.True(t, set.Has(Path{"spec", "replicas"}))
+
+	t.Run("update takes ownership", func(t *testing.T) {
+		scaled := created.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(scaled.Object, int64(3), "spec", "replicas"))
+		require.NoError(t, Update(created, scaled, "kubectl-scale"))
+
+		updateSet := managerEntry(t, scaled, "kubectl-scale", metav1.ManagedFieldsOperationUpdate)
+		assert.True(t, updateSet.Has(Path{"spec", "replicas"}))
+		applySet := managerEntry(t, scaled, "gitops", metav1.ManagedFieldsOperationApply)
+		assert.False(t, applySet.Has(Path{"spec", "replicas"}))
+		created = scaled
+	})
+
+	t.Run("apply conflicts with update", func(t *testing.T) {
+		_, err := Apply(created, applied, "other", false)
+		conflicts, ok := err.(Conflicts)
+		require.True(t, ok)
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, "kubectl-scale", conflicts[0].Manager)
+		assert.Equal(t, Path{"spec", "replicas"}, conflicts[0].Path)
+	})
+
+	t.Run("apply with same value shares ownership", func(t *testing.T) {
+		same := cronTab(map[string]interface{}{"replicas": int64(3)})
+		result, err := Apply(created, same, "other", false)
+		require.NoError(t, err)
+		assert.True(t, managerEntry(t, result, "other", metav1.ManagedFieldsOperationApply).
+			Has(Path{"spec", "replicas"}))
+		assert.True(t, managerEntry(t, result, "kubectl-scale", metav1.ManagedFieldsOperationUpdate).
+			Has(Path{"spec", "replicas"}))
+	})
+
+	t.Run("force apply transfers ownership", func(t *testing.T) {
+		result, err := Apply(created, applied, "other", true)
+		require.NoError(t, err)
+		replicas, _, _ := unstructured.NestedInt64(result.Object, "spec", "replicas")
+		assert.Equal(t, int64(1), replicas)
+		assert.Nil(t, managerEntry(t, result, "kubectl-scale", metav1.ManagedFieldsOperationUpdate))
+	})
+
+	t.Run("omitted fields are removed", func(t *testing.T) {
+		reduced := cronTab(map[string]interface{}{"image": "image:v2"})
+		result, err := Apply(created, reduced, "gitops", false)
+		require.NoError(t, err)
+		image, _, _ := unstructured.NestedString(result.Object, "spec", "image")
+		assert.Equal(t, "image:v2", image)
+		// replicas is still owned by kubectl-scale, therefore kept
+		_, found, _ := unstructured.NestedInt64(result.Object, "spec", "replicas")
+		assert.True(t, found)
+
+		reduced = cronTab(map[string]interface{}{"replicas": int64(3)})
+		result, err = Apply(result, reduced, "gitops", false)
+		require.NoError(t, err)
+		_, found, _ = unstructured.NestedString(result.Object, "spec", "image")
+		assert.False(t, found)
+	})
+}
+
+func TestManager_ManagerFromUserAgent(t *testing.T) {
+	assert.Equal(t, "kubectl", ManagerFromUserAgent("kubectl/v1.18.0 (linux/amd64)"))
+	assert.Equal(t, DefaultManager, ManagerFromUserAgent(""))
+}
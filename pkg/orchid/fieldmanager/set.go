@@ -0,0 +1,159 @@
+package fieldmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Path is the sequence of keys leading to a field in an object.
+type Path []string
+
+// String returns the path using dot notation, as in ".spec.replicas".
+func (p Path) String() string {
+	return "." + strings.Join(p, ".")
+}
+
+// Set of field paths, keyed by an unambiguous representation of each path.
+type Set map[string]Path
+
+// fieldPrefix prefix used by FieldsV1 to denote fields.
+const fieldPrefix = "f:"
+
+// key returns an unambiguous string representation of path, since object keys may contain dots.
+func (s Set) key(path Path) string {
+	bytes, _ := json.Marshal(path)
+	return string(bytes)
+}
+
+// Insert path in set.
+func (s Set) Insert(path Path) {
+	s[s.key(path)] = append(Path{}, path...)
+}
+
+// Has checks if path is part of set.
+func (s Set) Has(path Path) bool {
+	_, found := s[s.key(path)]
+	return found
+}
+
+// Delete path from set.
+func (s Set) Delete(path Path) {
+	delete(s, s.key(path))
+}
+
+// Paths returns the paths in set, sorted.
+func (s Set) Paths() []Path {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	paths := make([]Path, 0, len(s))
+	for _, key := range keys {
+		paths = append(paths, s[key])
+	}
+	return paths
+}
+
+// FieldsV1 serializes the set using Kubernetes FieldsV1 format, where each field is a nested object
+// with "f:" prefixed keys.
+func (s Set) FieldsV1() ([]byte, error) {
+	root := map[string]interface{}{}
+	for _, path := range s {
+		node := root
+		for _, field := range path {
+			key := fieldPrefix + field
+			next, found := node[key].(map[string]interface{})
+			if !found {
+				next = map[string]interface{}{}
+				node[key] = next
+			}
+			node = next
+		}
+	}
+	return json.Marshal(root)
+}
+
+// insertFieldsV1 recursively walks a FieldsV1 node, inserting leaf fields in set.
+func (s Set) insertFieldsV1(node map[string]interface{}, path Path) error {
+	leaf := true
+	for key, value := range node {
+		if !strings.HasPrefix(key, fieldPrefix) {
+			continue
+		}
+		leaf = false
+		child, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected FieldsV1 value at '%s'", append(path, key))
+		}
+		fieldPath := append(append(Path{}, path...), strings.TrimPrefix(key, fieldPrefix))
+		if err := s.insertFieldsV1(child, fieldPath); err != nil {
+			return err
+		}
+	}
+	if leaf && len(path) > 0 {
+		s.Insert(path)
+	}
+	return nil
+}
+
+// NewSetFromFieldsV1 creates a set out of Kubernetes FieldsV1 serialized data.
+func NewSetFromFieldsV1(raw []byte) (Set, error) {
+	s := Set{}
+	if len(raw) == 0 {
+		return s, nil
+	}
+	root := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, err
+	}
+	if err := s.insertFieldsV1(root, Path{}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// trackedMetadata metadata fields subject to ownership, the remaining are managed by the server.
+var trackedMetadata = []string{"annotations", "finalizers", "labels", "ownerReferences"}
+
+// insertObject recursively walks obj, inserting leaf fields in set. Slices are considered atomic,
+// and therefore leaves.
+func (s Set) insertObject(obj map[string]interface{}, path Path) {
+	for key, value := range obj {
+		fieldPath := append(append(Path{}, path...), key)
+		child, ok := value.(map[string]interface{})
+		if !ok || len(child) == 0 {
+			s.Insert(fieldPath)
+			continue
+		}
+		s.insertObject(child, fieldPath)
+	}
+}
+
+// NewSetFromObject creates a set with the fields present in obj. Type information is not part of
+// the set, while only a few metadata fields are taken into account.
+func NewSetFromObject(obj map[string]interface{}) Set {
+	s := Set{}
+	for key, value := range obj {
+		switch key {
+		case "apiVersion", "kind":
+			continue
+		case "metadata":
+			metadata, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, field := range trackedMetadata {
+				if fieldValue, found := metadata[field]; found {
+					s.insertObject(map[string]interface{}{field: fieldValue}, Path{key})
+				}
+			}
+		default:
+			s.insertObject(map[string]interface{}{key: value}, Path{})
+		}
+	}
+	return s
+}
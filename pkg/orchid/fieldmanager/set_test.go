@@ -0,0 +1,57 @@
+package fieldmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet_NewSetFromObject(t *testing.T) {
+	obj := map[string]interface{}{
+		"apiVersion": "stable.example.com/v1",
+		"kind":       "CronTab",
+		"metadata": map[string]interface{}{
+			"name":   "example",
+			"labels": map[string]interface{}{"app.kubernetes.io/name": "example"},
+		},
+		"spec": map[string]interface{}{
+			"image":    "image:latest",
+			"replicas": int64(1),
+			"args":     []interface{}{"a", "b"},
+		},
+	}
+
+	s := NewSetFromObject(obj)
+	assert.Len(t, s, 4)
+	assert.True(t, s.Has(Path{"metadata", "labels", "app.kubernetes.io/name"}))
+	assert.True(t, s.Has(Path{"spec", "args"}))
+	assert.True(t, s.Has(Path{"spec", "image"}))
+	assert.True(t, s.Has(Path{"spec", "replicas"}))
+	assert.False(t, s.Has(Path{"metadata", "name"}))
+	assert.False(t, s.Has(Path{"kind"}))
+}
+
+func TestSet_FieldsV1(t *testing.T) {
+	s := Set{}
+	s.Insert(Path{"spec", "replicas"})
+	s.Insert(Path{"spec", "image"})
+	s.Insert(Path{"metadata", "labels", "app"})
+
+	raw, err := s.FieldsV1()
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:image":{},"f:replicas":{}}}`,
+		string(raw))
+
+	decoded, err := NewSetFromFieldsV1(raw)
+	require.NoError(t, err)
+	assert.Equal(t, s.Paths(), decoded.Paths())
+
+	s.Delete(Path{"spec", "image"})
+	assert.False(t, s.Has(Path{"spec", "image"}))
+	assert.Equal(t, ".spec.replicas", s.Paths()[1].String())
+
+	_, err = NewSetFromFieldsV1([]byte(`{"f:spec":"invalid"}`))
+	assert.Error(t, err)
+}
@@ -22,6 +22,8 @@ func objectMetaManagedFields() extv1.JSONSchemaProps {
 	items := JSONSchemaPropsOrArray(
 		JSONSchemaProps(Object, "", nil, nil, map[string]extv1.JSONSchemaProps{
 			"apiVersion": StringProp,
+			"fieldsType": StringProp,
+			"fieldsV1":   PreserveUnknownFieldsProp(),
 			"manager":    StringProp,
 			"operation":  StringProp,
 			"time":       DateTimeProp,
@@ -74,3 +76,14 @@ func MetaV1ObjectMetaOpenAPIV3Schema() extv1.JSONSchemaProps {
 		XListMapKeys: keys,
 	}
 }
+
+// MetaV1ListMetaOpenAPIV3Schema creates a ListMeta object based on metav1.
+func MetaV1ListMetaOpenAPIV3Schema() extv1.JSONSchemaProps {
+	properties := map[string]extv1.JSONSchemaProps{
+		"continue":           StringProp,
+		"remainingItemCount": Int64Prop,
+		"resourceVersion":    StringProp,
+		"selfLink":           StringProp,
+	}
+	return extv1.JSONSchemaProps{Type: Object, Properties: properties}
+}
@@ -11,6 +11,13 @@ var (
 	BooleanProp  = JSONSchemaProps(Boolean, "", nil, nil, nil)
 )
 
+// PreserveUnknownFieldsProp creates an object accepting any content, flagged with
+// x-kubernetes-preserve-unknown-fields.
+func PreserveUnknownFieldsProp() extv1.JSONSchemaProps {
+	preserveUnknownFields := true
+	return extv1.JSONSchemaProps{Type: Object, XPreserveUnknownFields: &preserveUnknownFields}
+}
+
 // JSONSchemaPropsOrArray creates a JSONSchemaPropsOrArray skeleton based on properties.
 func JSONSchemaPropsOrArray(props extv1.JSONSchemaProps) *extv1.JSONSchemaPropsOrArray {
 	return &extv1.JSONSchemaPropsOrArray{Schema: &props}
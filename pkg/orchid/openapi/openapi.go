@@ -0,0 +1,202 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+	"github.com/isutton/orchid/pkg/orchid/repository"
+)
+
+// GVKExtension extension carrying the group, version and kind of definitions and operations.
+const GVKExtension = "x-kubernetes-group-version-kind"
+
+const (
+	// ObjectMetaDefinition definition name of metav1.ObjectMeta.
+	ObjectMetaDefinition = "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+	// ListMetaDefinition definition name of metav1.ListMeta.
+	ListMetaDefinition = "io.k8s.apimachinery.pkg.apis.meta.v1.ListMeta"
+)
+
+// builtinPrefixes definition name prefix of built-in groups, as Kubernetes itself names them.
+var builtinPrefixes = map[string]string{
+	repository.NSGVK.Group:  "io.k8s.api.core",
+	repository.CRDGVK.Group: "io.k8s.apiextensions-apiserver.pkg.apis.apiextensions",
+}
+
+// Resource a resource served by orchid, described by its GVK and OpenAPI v3 schema.
+type Resource struct {
+	GVK        schema.GroupVersionKind // group, version and kind
+	Plural     string                  // resource name employed in paths, empty when not served
+	Namespaced bool                    // resource is namespace scoped
+	Schema     extv1.JSONSchemaProps   // OpenAPI v3 schema, as informed in CRDs
+}
+
+// DefinitionName returns the name of the definition describing the GVK, where the group is
+// reversed, as in "com.example.stable.v1.CronTab".
+func DefinitionName(gvk schema.GroupVersionKind) string {
+	prefix, builtin := builtinPrefixes[gvk.Group]
+	if !builtin {
+		parts := strings.Split(gvk.Group, ".")
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+		prefix = strings.Join(parts, ".")
+	}
+	return fmt.Sprintf("%s.%s.%s", prefix, gvk.Version, gvk.Kind)
+}
+
+// groupVersionPath returns the path prefix of resources in the group-version, where the core group
+// is served under "/api".
+func groupVersionPath(gvk schema.GroupVersionKind) string {
+	if gvk.Group == "" {
+		return fmt.Sprintf("/api/%s", gvk.Version)
+	}
+	return fmt.Sprintf("/apis/%s/%s", gvk.Group, gvk.Version)
+}
+
+// operationTag returns the tag grouping operations of the group-version, as in
+// "stable_example_com_v1".
+func operationTag(gvk schema.GroupVersionKind) string {
+	group := gvk.Group
+	if group == "" {
+		group = "core"
+	}
+	return fmt.Sprintf("%s_%s", strings.ReplaceAll(group, ".", "_"), gvk.Version)
+}
+
+// BuiltinResources returns the resources known by orchid itself, regardless of stored CRDs.
+// Namespaces are described, but not served.
+func BuiltinResources() []Resource {
+	return []Resource{
+		{
+			GVK:    repository.CRDGVK,
+			Plural: "customresourcedefinitions",
+			Schema: jsc.ExtV1CRDOpenAPIV3Schema(),
+		},
+		{
+			GVK:    repository.NSGVK,
+			Schema: jsc.CoreV1NamespaceOpenAPIV3Schema(),
+		},
+	}
+}
+
+// CRDResources returns the resources described by the CRDs informed, one per served version.
+// Versions without schema accept any content.
+func CRDResources(crds []*extv1.CustomResourceDefinition) []Resource {
+	resources := []Resource{}
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			if !version.Served {
+				continue
+			}
+			resource := Resource{
+				GVK: schema.GroupVersionKind{
+					Group:   crd.Spec.Group,
+					Version: version.Name,
+					Kind:    crd.Spec.Names.Kind,
+				},
+				Plural:     crd.Spec.Names.Plural,
+				Namespaced: crd.Spec.Scope == extv1.NamespaceScoped,
+				Schema:     jsc.PreserveUnknownFieldsProp(),
+			}
+			if version.Schema != nil && version.Schema.OpenAPIV3Schema != nil {
+				resource.Schema = *version.Schema.OpenAPIV3Schema.DeepCopy()
+			}
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// patchTypes media types accepted as patch request body.
+var patchTypes = []string{
+	"application/json-patch+json",
+	"application/merge-patch+json",
+	"application/apply-patch+yaml",
+}
+
+// route an operation served for a resource, identified by its method and path.
+type route struct {
+	path       string // path, including namespace and name parameters when needed
+	method     string // HTTP method
+	verb       string // verb employed on operation identifiers, as in "read"
+	action     string // kubernetes action, as in "get"
+	namespaced bool   // path is scoped by namespace
+	list       bool   // answers with a list of objects
+	body       bool   // receives an object as request body
+	patch      bool   // receives a patch as request body
+}
+
+// params returns the names of path parameters.
+func (r route) params() []string {
+	params := []string{}
+	if r.namespaced {
+		params = append(params, "namespace")
+	}
+	if strings.HasSuffix(r.path, "{name}") {
+		params = append(params, "name")
+	}
+	return params
+}
+
+// operationID returns the identifier of the operation on the resource, following Kubernetes
+// naming, as in "readStableExampleComV1NamespacedCronTab".
+func (r route) operationID(resource Resource) string {
+	gvk := resource.GVK
+	group := ""
+	for _, part := range strings.Split(gvk.Group, ".") {
+		group += strings.Title(part)
+	}
+	scope := ""
+	if r.namespaced {
+		scope = "Namespaced"
+	}
+	id := fmt.Sprintf("%s%s%s%s%s", r.verb, group, strings.Title(gvk.Version), scope, gvk.Kind)
+	if r.list && !r.namespaced && resource.Namespaced {
+		id += "ForAllNamespaces"
+	}
+	return id
+}
+
+// routes returns the operations serving the resource, following Kubernetes layout for the
+// resource's scope. Namespaced resources can also be listed across all namespaces.
+func routes(resource Resource) []route {
+	collectionPath := fmt.Sprintf("%s/%s", groupVersionPath(resource.GVK), resource.Plural)
+	all := []route{}
+	if resource.Namespaced {
+		all = append(all, route{path: collectionPath, method: http.MethodGet, verb: "list",
+			action: "list", list: true})
+		collectionPath = fmt.Sprintf("%s/namespaces/{namespace}/%s",
+			groupVersionPath(resource.GVK), resource.Plural)
+	}
+	itemPath := collectionPath + "/{name}"
+	ns := resource.Namespaced
+	return append(all,
+		route{path: collectionPath, method: http.MethodGet, verb: "list", action: "list",
+			namespaced: ns, list: true},
+		route{path: collectionPath, method: http.MethodPost, verb: "create", action: "post",
+			namespaced: ns, body: true},
+		route{path: itemPath, method: http.MethodGet, verb: "read", action: "get", namespaced: ns},
+		route{path: itemPath, method: http.MethodPut, verb: "replace", action: "put",
+			namespaced: ns, body: true},
+		route{path: itemPath, method: http.MethodPatch, verb: "patch", action: "patch",
+			namespaced: ns, patch: true},
+		route{path: itemPath, method: http.MethodDelete, verb: "delete", action: "delete",
+			namespaced: ns},
+	)
+}
+
+// gvkExtension returns the value of GVKExtension for the GVK informed, definitions carry a list of
+// those.
+func gvkExtension(gvk schema.GroupVersionKind) map[string]interface{} {
+	return map[string]interface{}{
+		"group":   gvk.Group,
+		"version": gvk.Version,
+		"kind":    gvk.Kind,
+	}
+}
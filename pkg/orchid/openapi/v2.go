@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/spec"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+)
+
+// v2PostProcess removes the schema constructs Swagger 2.0 is not able to represent.
+func v2PostProcess(s *spec.Schema) error {
+	s.OneOf, s.AnyOf, s.Not = nil, nil, nil
+	s.Nullable = false
+	// int-or-string types are kept only as extension
+	if len(s.Type) > 1 {
+		s.Type = nil
+	}
+	return nil
+}
+
+// v2Schema converts an OpenAPI v3 schema, as informed in CRDs, into a Swagger 2.0 schema.
+func v2Schema(props extv1.JSONSchemaProps) (*spec.Schema, error) {
+	internal := &apiextensions.JSONSchemaProps{}
+	err := extv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&props, internal, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &spec.Schema{}
+	if err = validation.ConvertJSONSchemaPropsWithPostProcess(internal, s, v2PostProcess); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// refSchema returns a schema referencing the definition informed.
+func refSchema(definition string) *spec.Schema {
+	return spec.RefSchema(fmt.Sprintf("#/definitions/%s", definition))
+}
+
+// v2ResourceDefinitions returns the definitions of resource and its list, having metadata
+// referencing the meta definitions and carrying GVKExtension.
+func v2ResourceDefinitions(resource Resource) (spec.Definitions, error) {
+	s, err := v2Schema(resource.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert schema of '%s': %w", resource.GVK, err)
+	}
+	if s.Properties == nil {
+		s.Properties = map[string]spec.Schema{}
+	}
+	s.Properties["metadata"] = *refSchema(ObjectMetaDefinition)
+	s.AddExtension(GVKExtension, []interface{}{gvkExtension(resource.GVK)})
+
+	name := DefinitionName(resource.GVK)
+	listGVK := resource.GVK.GroupVersion().WithKind(resource.GVK.Kind + "List")
+	list := &spec.Schema{}
+	list.Typed(jsc.Object, "")
+	list.Required = []string{"items"}
+	list.Properties = map[string]spec.Schema{
+		"apiVersion": *spec.StringProperty(),
+		"kind":       *spec.StringProperty(),
+		"items":      *spec.ArrayProperty(refSchema(name)),
+		"metadata":   *refSchema(ListMetaDefinition),
+	}
+	list.AddExtension(GVKExtension, []interface{}{gvkExtension(listGVK)})
+
+	return spec.Definitions{name: *s, DefinitionName(listGVK): *list}, nil
+}
+
+// v2Operation returns the operation served by route, carrying GVKExtension.
+func v2Operation(resource Resource, r route) *spec.Operation {
+	name := DefinitionName(resource.GVK)
+	response := refSchema(name)
+	if r.list {
+		response = refSchema(name + "List")
+	}
+	operation := spec.NewOperation(r.operationID(resource)).
+		WithTags(operationTag(resource.GVK)).
+		RespondsWith(http.StatusOK, spec.NewResponse().WithDescription("OK").WithSchema(response))
+	if r.body {
+		operation.AddParam(spec.BodyParam("body", refSchema(name)).AsRequired())
+	}
+	if r.patch {
+		patch := &spec.Schema{}
+		patch.Typed(jsc.Object, "")
+		operation.WithConsumes(patchTypes...).AddParam(spec.BodyParam("body", patch).AsRequired())
+	}
+	operation.AddExtension("x-kubernetes-action", r.action)
+	operation.AddExtension(GVKExtension, gvkExtension(resource.GVK))
+	return operation
+}
+
+// v2Paths returns the paths serving the resource.
+func v2Paths(resource Resource) map[string]spec.PathItem {
+	paths := map[string]spec.PathItem{}
+	for _, r := range routes(resource) {
+		item := paths[r.path]
+		if item.Parameters == nil {
+			for _, param := range r.params() {
+				item.Parameters = append(item.Parameters,
+					*spec.PathParam(param).Typed(jsc.String, "").AsRequired())
+			}
+		}
+		operation := v2Operation(resource, r)
+		switch r.method {
+		case http.MethodGet:
+			item.Get = operation
+		case http.MethodPost:
+			item.Post = operation
+		case http.MethodPut:
+			item.Put = operation
+		case http.MethodPatch:
+			item.Patch = operation
+		case http.MethodDelete:
+			item.Delete = operation
+		}
+		paths[r.path] = item
+	}
+	return paths
+}
+
+// NewV2 generates a Swagger 2.0 document describing the resources informed, with definitions for
+// all of them and paths for the ones served. It can return errors on converting schemas.
+func NewV2(resources []Resource) (*spec.Swagger, error) {
+	objectMeta, err := v2Schema(jsc.MetaV1ObjectMetaOpenAPIV3Schema())
+	if err != nil {
+		return nil, err
+	}
+	listMeta, err := v2Schema(jsc.MetaV1ListMetaOpenAPIV3Schema())
+	if err != nil {
+		return nil, err
+	}
+
+	swagger := &spec.Swagger{SwaggerProps: spec.SwaggerProps{
+		Swagger:  "2.0",
+		Info:     &spec.Info{InfoProps: spec.InfoProps{Title: "Orchid", Version: "v1"}},
+		Consumes: []string{"application/json", "application/yaml"},
+		Produces: []string{"application/json", "application/yaml"},
+		Paths:    &spec.Paths{Paths: map[string]spec.PathItem{}},
+		Definitions: spec.Definitions{
+			ObjectMetaDefinition: *objectMeta,
+			ListMetaDefinition:   *listMeta,
+		},
+	}}
+	for _, resource := range resources {
+		definitions, err := v2ResourceDefinitions(resource)
+		if err != nil {
+			return nil, err
+		}
+		for name, definition := range definitions {
+			swagger.Definitions[name] = definition
+		}
+		if resource.Plural == "" {
+			continue
+		}
+		for path, item := range v2Paths(resource) {
+			swagger.Paths.Paths[path] = item
+		}
+	}
+	return swagger, nil
+}
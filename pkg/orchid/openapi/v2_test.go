@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+	"github.com/isutton/orchid/pkg/orchid/repository"
+	"github.com/isutton/orchid/test/mocks"
+)
+
+func TestOpenAPI_DefinitionName(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "CronTab"}
+	require.Equal(t, "com.example.stable.v1.CronTab", DefinitionName(gvk))
+	require.Equal(t, "io.k8s.api.core.v1.Namespace", DefinitionName(repository.NSGVK))
+	require.Equal(t,
+		"io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1.CustomResourceDefinition",
+		DefinitionName(repository.CRDGVK))
+}
+
+func TestOpenAPI_CRDResources(t *testing.T) {
+	crd := mocks.CRDMock("ns", "complexi.tests.example.com")
+	crd.Spec.Versions[0].Served = true
+	crd.Spec.Versions = append(crd.Spec.Versions, extv1.CustomResourceDefinitionVersion{
+		Name:   "v1beta1",
+		Served: true,
+	}, extv1.CustomResourceDefinitionVersion{
+		Name: "v1alpha1",
+	})
+
+	resources := CRDResources([]*extv1.CustomResourceDefinition{crd})
+	require.Len(t, resources, 2)
+	require.Equal(t, "v1", resources[0].GVK.Version)
+	require.Equal(t, "complexi", resources[0].Plural)
+	require.True(t, resources[0].Namespaced)
+	require.Contains(t, resources[0].Schema.Properties, "spec")
+	require.Equal(t, "v1beta1", resources[1].GVK.Version)
+	require.Equal(t, jsc.PreserveUnknownFieldsProp(), resources[1].Schema)
+}
+
+func TestOpenAPI_NewV2(t *testing.T) {
+	crd := mocks.CRDMock("ns", "complexi.tests.example.com")
+	crd.Spec.Versions[0].Served = true
+	intOrString := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+	intOrString.Properties["port"] = extv1.JSONSchemaProps{XIntOrString: true, Nullable: true}
+	resources := append(BuiltinResources(), CRDResources([]*extv1.CustomResourceDefinition{crd})...)
+
+	swagger, err := NewV2(resources)
+	require.NoError(t, err)
+	require.Equal(t, "2.0", swagger.Swagger)
+
+	t.Run("definitions", func(t *testing.T) {
+		for _, name := range []string{
+			ObjectMetaDefinition,
+			ListMetaDefinition,
+			"com.example.tests.v1.Complex",
+			"com.example.tests.v1.ComplexList",
+			"io.k8s.api.core.v1.Namespace",
+			"io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1.CustomResourceDefinition",
+		} {
+			require.Contains(t, swagger.Definitions, name)
+		}
+
+		definition := swagger.Definitions["com.example.tests.v1.Complex"]
+		gvk, found := definition.Extensions[GVKExtension]
+		require.True(t, found)
+		require.Equal(t, []interface{}{map[string]interface{}{
+			"group":   "tests.example.com",
+			"version": "v1",
+			"kind":    "Complex",
+		}}, gvk)
+		metadata := definition.Properties["metadata"]
+		require.Equal(t, "#/definitions/"+ObjectMetaDefinition, metadata.Ref.String())
+
+		port := definition.Properties["spec"].Properties["port"]
+		require.Empty(t, port.Type)
+		require.False(t, port.Nullable)
+		require.Contains(t, port.Extensions, "x-kubernetes-int-or-string")
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		for _, path := range []string{
+			"/apis/tests.example.com/v1/complexi",
+			"/apis/tests.example.com/v1/namespaces/{namespace}/complexi",
+			"/apis/tests.example.com/v1/namespaces/{namespace}/complexi/{name}",
+			"/apis/apiextensions.k8s.io/v1/customresourcedefinitions",
+			"/apis/apiextensions.k8s.io/v1/customresourcedefinitions/{name}",
+		} {
+			require.Contains(t, swagger.Paths.Paths, path)
+		}
+		require.Len(t, swagger.Paths.Paths, 5)
+
+		item := swagger.Paths.Paths["/apis/tests.example.com/v1/namespaces/{namespace}/complexi/{name}"]
+		require.Len(t, item.Parameters, 2)
+		require.Equal(t, "readTestsExampleComV1NamespacedComplex", item.Get.ID)
+		require.Equal(t, "get", item.Get.Extensions["x-kubernetes-action"])
+		require.Contains(t, item.Get.Extensions, GVKExtension)
+		require.NotNil(t, item.Put)
+		require.NotNil(t, item.Patch)
+		require.NotNil(t, item.Delete)
+
+		all := swagger.Paths.Paths["/apis/tests.example.com/v1/complexi"]
+		require.Equal(t, "listTestsExampleComV1ComplexForAllNamespaces", all.Get.ID)
+		require.Nil(t, all.Post)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		_, err := json.Marshal(swagger)
+		require.NoError(t, err)
+	})
+}
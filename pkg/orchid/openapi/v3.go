@@ -0,0 +1,257 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+)
+
+// V3Document OpenAPI v3 document describing the resources of a single group-version.
+type V3Document struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       V3Info                 `json:"info"`
+	Paths      map[string]*V3PathItem `json:"paths"`
+	Components V3Components           `json:"components"`
+}
+
+// V3Info document title and version.
+type V3Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// V3Components schemas referred by operations, keyed by definition name.
+type V3Components struct {
+	Schemas map[string]map[string]interface{} `json:"schemas"`
+}
+
+// V3PathItem operations served under a path, sharing the path parameters.
+type V3PathItem struct {
+	Parameters []V3Parameter `json:"parameters,omitempty"`
+	Get        *V3Operation  `json:"get,omitempty"`
+	Put        *V3Operation  `json:"put,omitempty"`
+	Post       *V3Operation  `json:"post,omitempty"`
+	Delete     *V3Operation  `json:"delete,omitempty"`
+	Patch      *V3Operation  `json:"patch,omitempty"`
+}
+
+// V3Parameter path parameter.
+type V3Parameter struct {
+	Name     string                `json:"name"`
+	In       string                `json:"in"`
+	Required bool                  `json:"required"`
+	Schema   extv1.JSONSchemaProps `json:"schema"`
+}
+
+// V3Operation operation carrying the kubernetes action and GVK extensions.
+type V3Operation struct {
+	OperationID string                 `json:"operationId"`
+	Tags        []string               `json:"tags,omitempty"`
+	RequestBody *V3RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]V3Response  `json:"responses"`
+	Action      string                 `json:"x-kubernetes-action"`
+	GVK         map[string]interface{} `json:"x-kubernetes-group-version-kind"`
+}
+
+// V3RequestBody request body schema per media type.
+type V3RequestBody struct {
+	Content  map[string]V3MediaType `json:"content"`
+	Required bool                   `json:"required"`
+}
+
+// V3Response response description and schema per media type.
+type V3Response struct {
+	Description string                 `json:"description"`
+	Content     map[string]V3MediaType `json:"content,omitempty"`
+}
+
+// V3MediaType schema of a given media type.
+type V3MediaType struct {
+	Schema extv1.JSONSchemaProps `json:"schema"`
+}
+
+// V3Path returns the path identifying the group-version on the OpenAPI v3 index, as in
+// "apis/stable.example.com/v1".
+func V3Path(gv schema.GroupVersion) string {
+	return strings.TrimPrefix(groupVersionPath(gv.WithKind("")), "/")
+}
+
+// v3Ref returns a schema referencing the component informed.
+func v3Ref(name string) extv1.JSONSchemaProps {
+	ref := fmt.Sprintf("#/components/schemas/%s", name)
+	return extv1.JSONSchemaProps{Ref: &ref}
+}
+
+// v3Schema returns the schema as a generic map, so extensions not modeled by JSONSchemaProps can
+// be added. The x-kubernetes extensions are kept as informed.
+func v3Schema(props extv1.JSONSchemaProps) (map[string]interface{}, error) {
+	data, err := json.Marshal(props)
+	if err != nil {
+		return nil, err
+	}
+	s := map[string]interface{}{}
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// v3ResourceSchemas returns the schemas of resource and its list, having metadata referencing the
+// meta schemas and carrying GVKExtension.
+func v3ResourceSchemas(resource Resource) (map[string]map[string]interface{}, error) {
+	name := DefinitionName(resource.GVK)
+	props := *resource.Schema.DeepCopy()
+	if props.Properties == nil {
+		props.Properties = map[string]extv1.JSONSchemaProps{}
+	}
+	props.Properties["metadata"] = v3Ref(ObjectMetaDefinition)
+	s, err := v3Schema(props)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert schema of '%s': %w", resource.GVK, err)
+	}
+	s[GVKExtension] = []interface{}{gvkExtension(resource.GVK)}
+
+	listGVK := resource.GVK.GroupVersion().WithKind(resource.GVK.Kind + "List")
+	item := v3Ref(name)
+	list, err := v3Schema(extv1.JSONSchemaProps{
+		Type:     jsc.Object,
+		Required: []string{"items"},
+		Properties: map[string]extv1.JSONSchemaProps{
+			"apiVersion": jsc.StringProp,
+			"kind":       jsc.StringProp,
+			"items":      {Type: jsc.Array, Items: &extv1.JSONSchemaPropsOrArray{Schema: &item}},
+			"metadata":   v3Ref(ListMetaDefinition),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	list[GVKExtension] = []interface{}{gvkExtension(listGVK)}
+
+	return map[string]map[string]interface{}{name: s, DefinitionName(listGVK): list}, nil
+}
+
+// v3Content returns the schema informed for each media type.
+func v3Content(s extv1.JSONSchemaProps, mediaTypes ...string) map[string]V3MediaType {
+	content := map[string]V3MediaType{}
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = V3MediaType{Schema: s}
+	}
+	return content
+}
+
+// v3Operation returns the operation served by route.
+func v3Operation(resource Resource, r route) *V3Operation {
+	name := DefinitionName(resource.GVK)
+	response := v3Ref(name)
+	if r.list {
+		response = v3Ref(name + "List")
+	}
+	operation := &V3Operation{
+		OperationID: r.operationID(resource),
+		Tags:        []string{operationTag(resource.GVK)},
+		Responses: map[string]V3Response{
+			strconv.Itoa(http.StatusOK): {
+				Description: "OK",
+				Content:     v3Content(response, "application/json", "application/yaml"),
+			},
+		},
+		Action: r.action,
+		GVK:    gvkExtension(resource.GVK),
+	}
+	if r.body {
+		operation.RequestBody = &V3RequestBody{
+			Content:  v3Content(v3Ref(name), "application/json", "application/yaml"),
+			Required: true,
+		}
+	}
+	if r.patch {
+		operation.RequestBody = &V3RequestBody{
+			Content:  v3Content(extv1.JSONSchemaProps{Type: jsc.Object}, patchTypes...),
+			Required: true,
+		}
+	}
+	return operation
+}
+
+// v3Paths adds the paths serving the resource to the document.
+func v3Paths(doc *V3Document, resource Resource) {
+	for _, r := range routes(resource) {
+		item, found := doc.Paths[r.path]
+		if !found {
+			item = &V3PathItem{}
+			for _, param := range r.params() {
+				item.Parameters = append(item.Parameters, V3Parameter{
+					Name:     param,
+					In:       "path",
+					Required: true,
+					Schema:   jsc.StringProp,
+				})
+			}
+			doc.Paths[r.path] = item
+		}
+		operation := v3Operation(resource, r)
+		switch r.method {
+		case http.MethodGet:
+			item.Get = operation
+		case http.MethodPost:
+			item.Post = operation
+		case http.MethodPut:
+			item.Put = operation
+		case http.MethodPatch:
+			item.Patch = operation
+		case http.MethodDelete:
+			item.Delete = operation
+		}
+	}
+}
+
+// NewV3 generates an OpenAPI v3 document per group-version having served resources, built directly
+// from the OpenAPI v3 schemas informed. It can return errors on converting schemas.
+func NewV3(resources []Resource) (map[schema.GroupVersion]*V3Document, error) {
+	objectMeta, err := v3Schema(jsc.MetaV1ObjectMetaOpenAPIV3Schema())
+	if err != nil {
+		return nil, err
+	}
+	listMeta, err := v3Schema(jsc.MetaV1ListMetaOpenAPIV3Schema())
+	if err != nil {
+		return nil, err
+	}
+
+	docs := map[schema.GroupVersion]*V3Document{}
+	for _, resource := range resources {
+		if resource.Plural == "" {
+			continue
+		}
+		gv := resource.GVK.GroupVersion()
+		doc, found := docs[gv]
+		if !found {
+			doc = &V3Document{
+				OpenAPI: "3.0.0",
+				Info:    V3Info{Title: "Orchid", Version: gv.String()},
+				Paths:   map[string]*V3PathItem{},
+				Components: V3Components{Schemas: map[string]map[string]interface{}{
+					ObjectMetaDefinition: objectMeta,
+					ListMetaDefinition:   listMeta,
+				}},
+			}
+			docs[gv] = doc
+		}
+		schemas, err := v3ResourceSchemas(resource)
+		if err != nil {
+			return nil, err
+		}
+		for name, s := range schemas {
+			doc.Components.Schemas[name] = s
+		}
+		v3Paths(doc, resource)
+	}
+	return docs, nil
+}
@@ -0,0 +1,74 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/isutton/orchid/pkg/orchid/repository"
+	"github.com/isutton/orchid/test/mocks"
+)
+
+func TestOpenAPI_V3Path(t *testing.T) {
+	require.Equal(t, "apis/apiextensions.k8s.io/v1", V3Path(repository.CRDGVK.GroupVersion()))
+	require.Equal(t, "api/v1", V3Path(repository.NSGVK.GroupVersion()))
+}
+
+func TestOpenAPI_NewV3(t *testing.T) {
+	crd := mocks.CRDMock("ns", "complexi.tests.example.com")
+	crd.Spec.Versions[0].Served = true
+	spec := crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+	spec.Properties["port"] = extv1.JSONSchemaProps{XIntOrString: true, Nullable: true}
+	resources := append(BuiltinResources(), CRDResources([]*extv1.CustomResourceDefinition{crd})...)
+
+	docs, err := NewV3(resources)
+	require.NoError(t, err)
+	// namespaces are not served, therefore core has no document
+	require.Len(t, docs, 2)
+
+	gv := schema.GroupVersion{Group: "tests.example.com", Version: "v1"}
+	require.Contains(t, docs, gv)
+	doc := docs[gv]
+
+	t.Run("schemas", func(t *testing.T) {
+		for _, name := range []string{
+			ObjectMetaDefinition,
+			ListMetaDefinition,
+			"com.example.tests.v1.Complex",
+			"com.example.tests.v1.ComplexList",
+		} {
+			require.Contains(t, doc.Components.Schemas, name)
+		}
+
+		s := doc.Components.Schemas["com.example.tests.v1.Complex"]
+		require.Equal(t, []interface{}{map[string]interface{}{
+			"group":   "tests.example.com",
+			"version": "v1",
+			"kind":    "Complex",
+		}}, s[GVKExtension])
+		properties := s["properties"].(map[string]interface{})
+		require.Equal(t, map[string]interface{}{
+			"$ref": "#/components/schemas/" + ObjectMetaDefinition,
+		}, properties["metadata"])
+
+		specProperties := properties["spec"].(map[string]interface{})["properties"]
+		port := specProperties.(map[string]interface{})["port"]
+		require.Equal(t, map[string]interface{}{
+			"nullable":                   true,
+			"x-kubernetes-int-or-string": true,
+		}, port)
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		require.Len(t, doc.Paths, 3)
+		item := doc.Paths["/apis/tests.example.com/v1/namespaces/{namespace}/complexi"]
+		require.NotNil(t, item)
+		require.Len(t, item.Parameters, 1)
+		require.Equal(t, "createTestsExampleComV1NamespacedComplex", item.Post.OperationID)
+		require.Equal(t, "post", item.Post.Action)
+		require.NotNil(t, item.Post.RequestBody)
+		require.Equal(t, "Complex", item.Post.GVK["kind"])
+	})
+}
@@ -0,0 +1,48 @@
+package orm
+
+import (
+	"database/sql"
+	"strconv"
+)
+
+// resourceVersionSequence sequence assigning resource-versions, kept in the public schema of the
+// clock database alone, so resource-versions are ordered across databases and search-paths.
+const resourceVersionSequence = "public.resource_version"
+
+// Clock assigns resource-versions out of a single sequence, kept in the database of its ORM
+// instance, and shared by the objects stored in every database.
+type Clock struct {
+	o *ORM // ORM instance connected to the database holding the sequence
+}
+
+// next takes the next resource-version. Writes on the clock database take it within their own
+// transaction, while writes on other databases take it on the clock connection. It can return
+// error on querying.
+func (c *Clock) next(database string, txn *sql.Tx) (string, error) {
+	row := c.o.DB.QueryRow
+	if database == c.o.database {
+		row = txn.QueryRow
+	}
+	var resourceVersion int64
+	if err := row(NextValStatement(resourceVersionSequence)).Scan(&resourceVersion); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(resourceVersion, 10), nil
+}
+
+// Current returns the last resource-version assigned, or zero when none was assigned yet. It can
+// return error on querying.
+func (c *Clock) Current() (int64, error) {
+	var resourceVersion int64
+	err := c.o.DB.QueryRow(LastValueStatement(resourceVersionSequence)).Scan(&resourceVersion)
+	return resourceVersion, err
+}
+
+// NewClock instantiate a Clock on the database of the ORM informed, creating its sequence when
+// needed. It can return error on creating the sequence.
+func NewClock(o *ORM) (*Clock, error) {
+	if _, err := o.DB.Exec(CreateSequenceStatement(resourceVersionSequence)); err != nil {
+		return nil, err
+	}
+	return &Clock{o: o}, nil
+}
@@ -3,6 +3,8 @@ package orm
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
 	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
 )
@@ -24,6 +26,18 @@ func (c *Column) String() string {
 	return statement
 }
 
+// StoredType returns the column type as reported by the database catalog, where serial types are
+// stored as their underlying integer type, and array dimensions are not enforced.
+func (c *Column) StoredType() string {
+	if c.Type == PgTypeSerial8 {
+		return PgTypeBigInt
+	}
+	if i := strings.Index(c.Type, "["); i > 0 {
+		return fmt.Sprintf("%s[]", c.Type[:i])
+	}
+	return c.Type
+}
+
 // Null returns a null representation for column.
 func (c *Column) Null() (interface{}, error) {
 	switch c.Type {
@@ -47,6 +61,40 @@ func (c *Column) Null() (interface{}, error) {
 	return nil, fmt.Errorf("unable to create a null presentation for type '%s'", c.Type)
 }
 
+// Zero returns the zero value of column type as SQL literal, and false when the type has none.
+func (c *Column) Zero() (string, bool) {
+	if strings.HasSuffix(c.StoredType(), "[]") {
+		return "'{}'", true
+	}
+	switch c.Type {
+	case PgTypeText:
+		return "''", true
+	case PgTypeBoolean:
+		return "false", true
+	case PgTypeBigInt, PgTypeInt, PgTypeDouble, PgTypeReal:
+		return "0", true
+	case PgTypeJSONB:
+		return "'{}'", true
+	}
+	return "", false
+}
+
+// ParseValue converts a value informed as string, as in field selectors, to the column type. It can
+// return errors on parsing the value, or when the column type is not scalar.
+func (c *Column) ParseValue(value string) (interface{}, error) {
+	switch c.Type {
+	case PgTypeText:
+		return value, nil
+	case PgTypeBoolean:
+		return strconv.ParseBool(value)
+	case PgTypeBigInt, PgTypeInt:
+		return strconv.ParseInt(value, 10, 64)
+	case PgTypeDouble, PgTypeReal:
+		return strconv.ParseFloat(value, 64)
+	}
+	return nil, fmt.Errorf("unable to parse value for type '%s'", c.Type)
+}
+
 // NewColumn instantiate a new column using type and format.
 func NewColumn(name, jsonSchemaType, format string, notNull bool) (*Column, error) {
 	columnType, err := ColumnTypeParser(jsonSchemaType, format)
@@ -24,4 +24,31 @@ func TestColumn_New(t *testing.T) {
 		assert.NotEmpty(t, column.String())
 		assert.Contains(t, column.String(), "integer[10]")
 	})
+	t.Run("StoredType", func(t *testing.T) {
+		var max int64 = 10
+		column, err := NewColumnArray("test", "integer", "int32", &max, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "integer[]", column.StoredType())
+
+		column = &Column{Name: "id", Type: PgTypeSerial8}
+		assert.Equal(t, PgTypeBigInt, column.StoredType())
+	})
+
+	t.Run("ParseValue", func(t *testing.T) {
+		column := &Column{Name: "test", Type: PgTypeBigInt}
+		value, err := column.ParseValue("10")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), value)
+		_, err = column.ParseValue("ten")
+		assert.Error(t, err)
+
+		column = &Column{Name: "test", Type: PgTypeBoolean}
+		value, err = column.ParseValue("true")
+		assert.NoError(t, err)
+		assert.Equal(t, true, value)
+
+		column = &Column{Name: "test", Type: PgTypeJSONB}
+		_, err = column.ParseValue("{}")
+		assert.Error(t, err)
+	})
 }
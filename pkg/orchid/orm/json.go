@@ -0,0 +1,210 @@
+package orm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+)
+
+// jsonMaxPairs maximum amount of key-value pairs informed to a single jsonb_build_object call,
+// since functions take at most 100 arguments.
+const jsonMaxPairs = 50
+
+// jsonKey returns the key informed as a SQL string literal.
+func jsonKey(key string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(key, "'", "''"))
+}
+
+// jsonValue returns the expression representing the column of table as JSON, following the same
+// rules of the assembler: strings and arrays are never null.
+func jsonValue(table *Table, column *Column) string {
+	value := fmt.Sprintf("%s.\"%s\"", table.Hint, column.Name)
+	switch column.JSType {
+	case jsc.Array:
+		return fmt.Sprintf("coalesce(to_jsonb(%s), '[]'::jsonb)", value)
+	case jsc.String:
+		if column.Type == PgTypeText {
+			return fmt.Sprintf("coalesce(%s, '')", value)
+		}
+	}
+	return value
+}
+
+// jsonBuilder generates the expressions and joins needed to build objects stored in a schema as
+// JSON documents.
+type jsonBuilder struct {
+	schema *Schema // schema instance
+}
+
+// buildObject returns the expression building an object out of key and value pairs, where keys
+// informed as omitted are left out when null.
+func (j *jsonBuilder) buildObject(pairs []string, omitted map[string]string) string {
+	objects := []string{}
+	for start := 0; start < len(pairs); start += jsonMaxPairs {
+		end := start + jsonMaxPairs
+		if end > len(pairs) {
+			end = len(pairs)
+		}
+		objects = append(objects,
+			fmt.Sprintf("jsonb_build_object(%s)", strings.Join(pairs[start:end], ", ")))
+	}
+	keys := []string{}
+	for key := range omitted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := omitted[key]
+		objects = append(objects, fmt.Sprintf(
+			"(case when %s is null then '{}'::jsonb else jsonb_build_object(%s, %s) end)",
+			value, jsonKey(key), value))
+	}
+	if len(objects) == 0 {
+		return "'{}'::jsonb"
+	}
+	return strings.Join(objects, " || ")
+}
+
+// object returns the expression building the object stored in table, and the joins it depends on.
+// Tables related by one-to-one relationships are left-joined, while tables related by one-to-many
+// relationships are aggregated in sub-queries, joined by foreign-key. Columns and tables are
+// visited by name, since their order in the schema follows properties in the original map. It can
+// return error when tables are not found.
+func (j *jsonBuilder) object(table *Table) (string, []string, error) {
+	columns := append([]*Column{}, table.Columns...)
+	sort.SliceStable(columns, func(i, k int) bool {
+		return columns[i].Name < columns[k].Name
+	})
+	oneToManyTables := j.schema.OneToManyTables(table.Name)
+	sort.Strings(oneToManyTables)
+
+	pairs := []string{}
+	omitted := map[string]string{}
+	joins := []string{}
+	for _, column := range columns {
+		if table.IsPrimaryKey(column.Name) {
+			continue
+		}
+		if !table.IsForeignKey(column.Name) {
+			// objects kept as JSON are omitted when null
+			if column.Type == PgTypeJSONB && column.JSType == jsc.Object {
+				omitted[column.Name] = jsonValue(table, column)
+				continue
+			}
+			pairs = append(pairs, jsonKey(column.Name), jsonValue(table, column))
+			continue
+		}
+
+		relatedTableName := table.ForeignKeyTable(column.Name)
+		// one-to-many tables refer back to the table above them, named after it
+		if column.Name == relatedTableName {
+			continue
+		}
+		related, err := j.schema.GetTable(relatedTableName)
+		if err != nil {
+			return "", nil, err
+		}
+		expr, relatedJoins, err := j.object(related)
+		if err != nil {
+			return "", nil, err
+		}
+		joins = append(joins, fmt.Sprintf("left join %s %s on %s.%s=%s.\"%s\"",
+			related.Name, related.Hint, related.Hint, PKColumnName, table.Hint, column.Name))
+		joins = append(joins, relatedJoins...)
+		pairs = append(pairs, jsonKey(column.Name), expr)
+	}
+
+	for _, relatedTableName := range oneToManyTables {
+		related, err := j.schema.GetTable(relatedTableName)
+		if err != nil {
+			return "", nil, err
+		}
+		aggregate, err := j.aggregate(related, table)
+		if err != nil {
+			return "", nil, err
+		}
+		alias := fmt.Sprintf("agg_%s", related.Hint)
+		joins = append(joins, fmt.Sprintf("left join (%s) %s on %s.fk=%s.%s",
+			aggregate, alias, alias, table.Hint, PKColumnName))
+
+		empty := "'[]'::jsonb"
+		if related.KV {
+			empty = "'{}'::jsonb"
+		}
+		key := related.Path[len(related.Path)-1]
+		pairs = append(pairs, jsonKey(key), fmt.Sprintf("coalesce(%s.doc, %s)", alias, empty))
+	}
+	return j.buildObject(pairs, omitted), joins, nil
+}
+
+// aggregate returns the sub-query aggregating the rows of a one-to-many table per foreign-key,
+// pointing to the table above it. Key-value tables are aggregated as a single object, others as an
+// array of objects in insertion order. It can return error when tables are not found.
+func (j *jsonBuilder) aggregate(table *Table, parent *Table) (string, error) {
+	fk := fmt.Sprintf("%s.\"%s\"", table.Hint, parent.Name)
+	if table.KV {
+		return fmt.Sprintf(
+			"select %s as fk, jsonb_object_agg(%s.\"key\", %s.\"value\") as doc from %s %s group by %s",
+			fk, table.Hint, table.Hint, table.Name, table.Hint, fk), nil
+	}
+	expr, joins, err := j.object(table)
+	if err != nil {
+		return "", err
+	}
+	statement := fmt.Sprintf("select %s as fk, jsonb_agg(%s order by %s.%s) as doc from %s %s",
+		fk, expr, table.Hint, PKColumnName, table.Name, table.Hint)
+	if len(joins) > 0 {
+		statement = fmt.Sprintf("%s %s", statement, strings.Join(joins, " "))
+	}
+	return fmt.Sprintf("%s group by %s", statement, fk), nil
+}
+
+// SelectJSONStatement generates a select statement building each object stored in the schema as a
+// single JSON document, out of the main table and the tables related to it, ordered by name. Nested
+// objects are built with jsonb_build_object, lists with jsonb_agg and key-value tables with
+// jsonb_object_agg; jsonb allows concatenating objects with many keys, and leaving out null ones.
+// Schemas keeping the original object, as CRDs do, select it instead. When limitPosition is
+// positive, the amount of rows is limited by the placeholder on that position. It can return error
+// when tables are not found.
+func SelectJSONStatement(schema *Schema, where []string, limitPosition int) (string, error) {
+	mainTable, err := schema.GetTable(schema.Name)
+	if err != nil {
+		return "", err
+	}
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return "", err
+	}
+
+	var statement string
+	// embedded resources keep the original payload as JSONB, not to be confused with objects
+	raw := mainTable.GetColumn(XEmbeddedResource)
+	if raw != nil && raw.Type == PgTypeJSONB && raw.JSType != jsc.Object {
+		from, _, err := fromStatement(schema, where)
+		if err != nil {
+			return "", err
+		}
+		statement = fmt.Sprintf("select %s.\"%s\" %s", mainTable.Hint, raw.Name, from)
+	} else {
+		builder := &jsonBuilder{schema: schema}
+		expr, joins, err := builder.object(mainTable)
+		if err != nil {
+			return "", err
+		}
+		statement = fmt.Sprintf("select %s from %s %s", expr, mainTable.Name, mainTable.Hint)
+		if len(joins) > 0 {
+			statement = fmt.Sprintf("%s %s", statement, strings.Join(joins, " "))
+		}
+		if len(where) > 0 {
+			statement = fmt.Sprintf("%s where %s", statement, strings.Join(where, " and "))
+		}
+	}
+
+	statement = fmt.Sprintf("%s %s", statement, OrderByName(metadataTable))
+	if limitPosition > 0 {
+		statement = fmt.Sprintf("%s limit $%d", statement, limitPosition)
+	}
+	return statement, nil
+}
@@ -0,0 +1,88 @@
+package orm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/klog/klogr"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+	"github.com/isutton/orchid/test/mocks"
+)
+
+func TestJSON(t *testing.T) {
+	logger := klogr.New().WithName("test")
+
+	t.Run("SelectJSON", func(t *testing.T) {
+		openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+		schema := NewSchema(logger, "cr")
+		err := schema.Generate(&openAPIV3Schema)
+		assert.NoError(t, err)
+
+		statement, err := SelectJSONStatement(schema, []string{`cm.name collate "C">$1`}, 2)
+		t.Logf("select='%s'", statement)
+		assert.NoError(t, err)
+		// columns and tables are visited by name, the statement is the same on every generation
+		expected := `select jsonb_build_object('apiVersion', coalesce(c."apiVersion", ''), 'kind', ` +
+			`coalesce(c."kind", ''), 'metadata', jsonb_build_object('clusterName', ` +
+			`coalesce(cm."clusterName", ''), 'creationTimestamp', ` +
+			`coalesce(cm."creationTimestamp", ''), 'deletionGracePeriodSeconds', ` +
+			`cm."deletionGracePeriodSeconds", 'deletionTimestamp', ` +
+			`coalesce(cm."deletionTimestamp", ''), 'finalizers', ` +
+			`coalesce(to_jsonb(cm."finalizers"), '[]'::jsonb), 'generateName', ` +
+			`coalesce(cm."generateName", ''), 'generation', cm."generation", 'name', ` +
+			`coalesce(cm."name", ''), 'namespace', coalesce(cm."namespace", ''), ` +
+			`'resourceVersion', coalesce(cm."resourceVersion", ''), 'selfLink', ` +
+			`coalesce(cm."selfLink", ''), 'uid', coalesce(cm."uid", ''), 'annotations', ` +
+			`coalesce(agg_cma.doc, '{}'::jsonb), 'labels', coalesce(agg_cml.doc, ` +
+			`'{}'::jsonb), 'managedFields', coalesce(agg_cmm.doc, '[]'::jsonb), ` +
+			`'ownerReferences', coalesce(agg_cmo.doc, '[]'::jsonb)), 'spec', ` +
+			`jsonb_build_object('array', coalesce(to_jsonb(cs."array"), '[]'::jsonb), ` +
+			`'complex', jsonb_build_object('complex_nested', ` +
+			`jsonb_build_object('attribute', coalesce(csccn."attribute", '')), ` +
+			`'simple_nested', coalesce(csc."simple_nested", '')), 'simple', ` +
+			`coalesce(cs."simple", ''))) from cr c left join cr_metadata cm on ` +
+			`cm.id=c."metadata" left join (select cma."cr_metadata" as fk, ` +
+			`jsonb_object_agg(cma."key", cma."value") as doc from cr_metadata_annotations ` +
+			`cma group by cma."cr_metadata") agg_cma on agg_cma.fk=cm.id left join (select ` +
+			`cml."cr_metadata" as fk, jsonb_object_agg(cml."key", cml."value") as doc from ` +
+			`cr_metadata_labels cml group by cml."cr_metadata") agg_cml on agg_cml.fk=cm.id ` +
+			`left join (select cmm."cr_metadata" as fk, ` +
+			`jsonb_agg(jsonb_build_object('apiVersion', coalesce(cmm."apiVersion", ''), ` +
+			`'fieldsType', coalesce(cmm."fieldsType", ''), 'manager', ` +
+			`coalesce(cmm."manager", ''), 'operation', coalesce(cmm."operation", ''), ` +
+			`'time', coalesce(cmm."time", '')) || (case when cmm."fieldsV1" is null then ` +
+			`'{}'::jsonb else jsonb_build_object('fieldsV1', cmm."fieldsV1") end) order by ` +
+			`cmm.id) as doc from cr_metadata_managedfields cmm group by cmm."cr_metadata") ` +
+			`agg_cmm on agg_cmm.fk=cm.id left join (select cmo."cr_metadata" as fk, ` +
+			`jsonb_agg(jsonb_build_object('apiVersion', coalesce(cmo."apiVersion", ''), ` +
+			`'blockOwnerDeletion', cmo."blockOwnerDeletion", 'controller', ` +
+			`cmo."controller", 'kind', coalesce(cmo."kind", ''), 'name', ` +
+			`coalesce(cmo."name", ''), 'uid', coalesce(cmo."uid", '')) order by cmo.id) as ` +
+			`doc from cr_metadata_ownerreferences cmo group by cmo."cr_metadata") agg_cmo ` +
+			`on agg_cmo.fk=cm.id left join cr_spec cs on cs.id=c."spec" left join ` +
+			`cr_spec_complex csc on csc.id=cs."complex" left join ` +
+			`cr_spec_complex_complex_nested csccn on csccn.id=csc."complex_nested" where ` +
+			`cm.name collate "C">$1 order by cm.name collate "C" limit $2`
+		assert.Equal(t, expected, statement)
+	})
+
+	t.Run("SelectJSON-CRD", func(t *testing.T) {
+		openAPIV3Schema := jsc.ExtV1CRDOpenAPIV3Schema()
+		schema := NewSchema(logger, "crd")
+		err := schema.Generate(&openAPIV3Schema)
+		assert.NoError(t, err)
+
+		statement, err := SelectJSONStatement(schema, nil, 0)
+		t.Logf("select='%s'", statement)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(statement, "select c.\"data\" from crd c"))
+		assert.True(t, strings.HasSuffix(statement, `order by cm.name collate "C"`))
+	})
+
+	t.Run("jsonKey", func(t *testing.T) {
+		assert.Equal(t, "'key'", jsonKey("key"))
+		assert.Equal(t, "'it''s'", jsonKey("it's"))
+	})
+}
@@ -0,0 +1,131 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IncompatibleSchemaChangeErr returned when a schema change can't be migrated without changing
+// existing data, as in changing column types, or making columns not-null.
+var IncompatibleSchemaChangeErr = errors.New("incompatible schema change")
+
+// Migration holds the statements to bring the tables of a schema to a new version of it.
+type Migration struct {
+	Statements []string // alter, create and drop statements, in execution order
+}
+
+// Empty checks if migration has no statements.
+func (m *Migration) Empty() bool {
+	return len(m.Statements) == 0
+}
+
+// addTables creates tables not present in current schema, in the same order they are created.
+func (m *Migration) addTables(current, desired *Schema) {
+	for _, table := range desired.Tables {
+		if _, err := current.GetTable(table.Name); err != nil {
+			m.Statements = append(m.Statements, table.String())
+		}
+	}
+}
+
+// setNotNull makes the column not-null, replacing null values in existing rows with the zero value
+// of the column type. Columns without zero value, as foreign-keys, are left nullable.
+func (m *Migration) setNotNull(table *Table, column *Column) {
+	zero, found := column.Zero()
+	if !found || table.IsForeignKey(column.Name) {
+		return
+	}
+	m.Statements = append(m.Statements,
+		FillNullStatement(table, column, zero), AlterColumnNullStatement(table, column))
+}
+
+// alterColumns adds new columns and their constraints, and changes type and nullability of
+// existing columns. Type changes, and columns becoming not-null, are refused with
+// IncompatibleSchemaChangeErr unless forced, since existing rows may not fit.
+func (m *Migration) alterColumns(currentTable, desiredTable *Table, force bool) error {
+	for _, column := range desiredTable.Columns {
+		currentColumn := currentTable.GetColumn(column.Name)
+		if currentColumn == nil {
+			if column.NotNull && !force {
+				return fmt.Errorf("%w: table '%s' column '%s' is added as not-null",
+					IncompatibleSchemaChangeErr, desiredTable.Name, column.Name)
+			}
+			// not-null is set apart, after existing rows are given a value
+			nullable := *column
+			nullable.NotNull = false
+			m.Statements = append(m.Statements, AddColumnStatement(desiredTable, &nullable))
+			if column.NotNull {
+				m.setNotNull(desiredTable, column)
+			}
+			for _, constraint := range desiredTable.Constraints {
+				if constraint.ColumnName == column.Name {
+					m.Statements = append(m.Statements,
+						AddConstraintStatement(desiredTable, constraint))
+				}
+			}
+			continue
+		}
+		if currentColumn.StoredType() != column.StoredType() {
+			if !force {
+				return fmt.Errorf("%w: table '%s' column '%s' type changes from '%s' to '%s'",
+					IncompatibleSchemaChangeErr, desiredTable.Name, column.Name,
+					currentColumn.StoredType(), column.StoredType())
+			}
+			m.Statements = append(m.Statements, AlterColumnTypeStatement(desiredTable, column))
+		}
+		if currentColumn.NotNull == column.NotNull {
+			continue
+		}
+		if !column.NotNull {
+			m.Statements = append(m.Statements, AlterColumnNullStatement(desiredTable, column))
+			continue
+		}
+		if !force {
+			return fmt.Errorf("%w: table '%s' column '%s' changes to not-null",
+				IncompatibleSchemaChangeErr, desiredTable.Name, column.Name)
+		}
+		m.setNotNull(desiredTable, column)
+	}
+	return nil
+}
+
+// dropColumns drops columns no longer present in desired table.
+func (m *Migration) dropColumns(currentTable, desiredTable *Table) {
+	for _, column := range currentTable.Columns {
+		if desiredTable.GetColumn(column.Name) == nil {
+			m.Statements = append(m.Statements, DropColumnStatement(currentTable, column))
+		}
+	}
+}
+
+// dropTables drops tables no longer present in desired schema, in reverse creation order.
+func (m *Migration) dropTables(current, desired *Schema) {
+	for _, table := range current.TablesReversed() {
+		if _, err := desired.GetTable(table.Name); err != nil {
+			m.Statements = append(m.Statements, DropTableStatement(table))
+		}
+	}
+}
+
+// NewMigration compares current and desired versions of a schema, returning the migration to
+// bring existing tables to the desired version. New tables are created first, followed by
+// changes on existing tables, and lastly removed tables are dropped. Column type changes, and
+// columns added or changed to not-null, are refused with IncompatibleSchemaChangeErr, unless force
+// is set, on which case existing data is cast to the new type, and null values are replaced by the
+// zero value of the column type.
+func NewMigration(current, desired *Schema, force bool) (*Migration, error) {
+	m := &Migration{Statements: []string{}}
+	m.addTables(current, desired)
+	for _, desiredTable := range desired.Tables {
+		currentTable, err := current.GetTable(desiredTable.Name)
+		if err != nil {
+			continue
+		}
+		if err = m.alterColumns(currentTable, desiredTable, force); err != nil {
+			return nil, err
+		}
+		m.dropColumns(currentTable, desiredTable)
+	}
+	m.dropTables(current, desired)
+	return m, nil
+}
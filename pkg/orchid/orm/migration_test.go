@@ -0,0 +1,125 @@
+package orm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/klog/klogr"
+
+	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
+	"github.com/isutton/orchid/test/mocks"
+)
+
+func TestMigration_New(t *testing.T) {
+	logger := klogr.New().WithName("test")
+
+	generate := func(t *testing.T, openAPIV3Schema extv1.JSONSchemaProps) *Schema {
+		s := NewSchema(logger, "cr")
+		require.NoError(t, s.Generate(&openAPIV3Schema))
+		return s
+	}
+	current := generate(t, mocks.OpenAPIV3SchemaMock())
+
+	t.Run("unchanged", func(t *testing.T) {
+		m, err := NewMigration(current, generate(t, mocks.OpenAPIV3SchemaMock()), false)
+		require.NoError(t, err)
+		assert.True(t, m.Empty())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+		spec := openAPIV3Schema.Properties["spec"]
+		delete(spec.Properties, "array")
+		spec.Properties["added"] = jsc.StringProp
+		spec.Properties["nested"] = jsc.JSONSchemaProps(
+			jsc.Object, "", nil, nil, map[string]extv1.JSONSchemaProps{"attribute": jsc.StringProp})
+		spec.Required = []string{}
+		openAPIV3Schema.Properties["spec"] = spec
+
+		m, err := NewMigration(current, generate(t, openAPIV3Schema), false)
+		require.NoError(t, err)
+		for _, statement := range m.Statements {
+			t.Logf("%s;", statement)
+		}
+		assert.Contains(t, m.Statements[0], "create table if not exists cr_spec_nested")
+		assert.Contains(t, m.Statements, "alter table cr_spec add column \"added\" text")
+		assert.Contains(t, m.Statements, "alter table cr_spec add column \"nested\" bigint")
+		assert.Contains(t, m.Statements,
+			"alter table cr_spec add foreign key (nested) references cr_spec_nested (id)")
+		assert.Contains(t, m.Statements, "alter table cr_spec alter column \"simple\" drop not null")
+		assert.Contains(t, m.Statements, "alter table cr_spec drop column if exists \"array\"")
+	})
+
+	t.Run("incompatible", func(t *testing.T) {
+		openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+		spec := openAPIV3Schema.Properties["spec"]
+		spec.Properties["simple"] = jsc.JSONSchemaProps(jsc.Integer, "", nil, nil, nil)
+		openAPIV3Schema.Properties["spec"] = spec
+		desired := generate(t, openAPIV3Schema)
+
+		_, err := NewMigration(current, desired, false)
+		require.True(t, errors.Is(err, IncompatibleSchemaChangeErr))
+
+		m, err := NewMigration(current, desired, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"alter table cr_spec alter column \"simple\" type integer using \"simple\"::integer",
+		}, m.Statements)
+	})
+
+	t.Run("added-not-null", func(t *testing.T) {
+		openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+		spec := openAPIV3Schema.Properties["spec"]
+		spec.Properties["added"] = jsc.StringProp
+		spec.Required = append(spec.Required, "added")
+		openAPIV3Schema.Properties["spec"] = spec
+		desired := generate(t, openAPIV3Schema)
+
+		_, err := NewMigration(current, desired, false)
+		require.True(t, errors.Is(err, IncompatibleSchemaChangeErr))
+
+		m, err := NewMigration(current, desired, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"alter table cr_spec add column \"added\" text",
+			"update cr_spec set \"added\" = '' where \"added\" is null",
+			"alter table cr_spec alter column \"added\" set not null",
+		}, m.Statements)
+	})
+
+	t.Run("changed-to-not-null", func(t *testing.T) {
+		openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+		spec := openAPIV3Schema.Properties["spec"]
+		spec.Required = []string{}
+		openAPIV3Schema.Properties["spec"] = spec
+		nullable := generate(t, openAPIV3Schema)
+
+		_, err := NewMigration(nullable, current, false)
+		require.True(t, errors.Is(err, IncompatibleSchemaChangeErr))
+
+		m, err := NewMigration(nullable, current, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"update cr_spec set \"simple\" = '' where \"simple\" is null",
+			"alter table cr_spec alter column \"simple\" set not null",
+		}, m.Statements)
+	})
+
+	t.Run("dropped-table", func(t *testing.T) {
+		openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+		spec := openAPIV3Schema.Properties["spec"]
+		delete(spec.Properties, "complex")
+		openAPIV3Schema.Properties["spec"] = spec
+
+		m, err := NewMigration(current, generate(t, openAPIV3Schema), false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"alter table cr_spec drop column if exists \"complex\"",
+			"drop table if exists cr_spec_complex",
+			"drop table if exists cr_spec_complex_complex_nested",
+		}, m.Statements)
+	})
+}
@@ -0,0 +1,284 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/lib/pq"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Event describes a change on a single object, published to watchers using PostgreSQL NOTIFY.
+type Event struct {
+	Type            watch.EventType `json:"type"`            // added, modified or deleted
+	Schema          string          `json:"schema"`          // schema name
+	Namespace       string          `json:"namespace"`       // object namespace
+	Name            string          `json:"name"`            // object name
+	ResourceVersion string          `json:"resourceVersion"` // resource-version of the change
+}
+
+// NamespacedName returns the namespace and name of the object changed.
+func (e *Event) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: e.Namespace, Name: e.Name}
+}
+
+const (
+	// listenerMinReconnect minimum interval between listener reconnection attempts.
+	listenerMinReconnect = 1 * time.Second
+	// listenerMaxReconnect maximum interval between listener reconnection attempts.
+	listenerMaxReconnect = 30 * time.Second
+)
+
+// NotifyStatement returns the statement to publish a payload in a notification channel.
+func NotifyStatement() string {
+	return "select pg_notify($1, $2)"
+}
+
+// execer represents the common exec interface of sql.DB and sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// newEvent instantiate an event for the object in schema, changed on resource-version.
+func newEvent(
+	eventType watch.EventType,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	resourceVersion string,
+) *Event {
+	return &Event{
+		Type:            eventType,
+		Schema:          schema.Name,
+		Namespace:       namespacedName.Namespace,
+		Name:            namespacedName.Name,
+		ResourceVersion: resourceVersion,
+	}
+}
+
+// publish sends the event on this ORM's channel, the search-path, therefore every group has its own
+// channel. When executed within a transaction, listeners only receive it on commit.
+func (o *ORM) publish(e execer, event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	o.logger.WithValues("channel", o.searchPath, "payload", string(payload)).
+		Info("Publishing event")
+	_, err = e.Exec(NotifyStatement(), o.searchPath, string(payload))
+	return err
+}
+
+// logEvent records the event in the events log using the transaction informed, thus only once the
+// change is committed.
+func (o *ORM) logEvent(txn *sql.Tx, event *Event) error {
+	resourceVersion, err := strconv.ParseInt(event.ResourceVersion, 10, 64)
+	if err != nil {
+		return err
+	}
+	_, err = txn.Exec(InsertEventStatement(),
+		resourceVersion, event.Schema, string(event.Type), event.Namespace, event.Name)
+	return err
+}
+
+// ChangedSince checks whether objects of schema were added, modified or deleted after the
+// resource-version informed, according to the events log. It can return error on querying.
+func (o *ORM) ChangedSince(schema *Schema, resourceVersion int64) (bool, error) {
+	var changed bool
+	err := o.DB.QueryRow(SelectChangedSinceStatement(), schema.Name, resourceVersion).
+		Scan(&changed)
+	return changed, err
+}
+
+// subscriber a single consumer of the events published on a channel, until its context is done.
+type subscriber struct {
+	ctx    context.Context // subscription context
+	events chan Event      // outgoing events
+}
+
+// databaseListener a single listener connection on a database, shared by the subscribers of every
+// channel (search-path) listened on it.
+type databaseListener struct {
+	listener    *pq.Listener                        // listener connection
+	listenMu    sync.Mutex                          // serializes listening and unlistening
+	subscribers map[string]map[*subscriber]struct{} // subscribers per channel
+}
+
+// Listener shares a single PostgreSQL listener connection per database amongst all subscribers,
+// listening on the channels subscribed so far, and fanning events out to subscribers. Once no
+// subscribers are left, the connection is closed.
+type Listener struct {
+	logger    logr.Logger                  // logger instance
+	mu        sync.Mutex                   // guards databases and their subscribers
+	databases map[string]*databaseListener // listener per database
+}
+
+// listen returns the database listener of the ORM's database, opening its connection and starting
+// to dispatch events when needed. It must be called holding the lock.
+func (l *Listener) listen(o *ORM) *databaseListener {
+	if dl, found := l.databases[o.database]; found {
+		return dl
+	}
+	logger := l.logger.WithValues("database", o.database)
+	dl := &databaseListener{
+		listener: pq.NewListener(
+			o.connectionString(o.database, o.searchPath),
+			listenerMinReconnect,
+			listenerMaxReconnect,
+			func(event pq.ListenerEventType, err error) {
+				if err != nil {
+					logger.Error(err, "Listener connection event", "event", event)
+				}
+			},
+		),
+		subscribers: map[string]map[*subscriber]struct{}{},
+	}
+	l.databases[o.database] = dl
+	go l.dispatch(o.database, dl)
+	return dl
+}
+
+// dispatch sends the events received on the database listener to the subscribers of their channel,
+// until the listener connection is closed. It is the only sender on subscriber channels.
+func (l *Listener) dispatch(database string, dl *databaseListener) {
+	logger := l.logger.WithValues("database", database)
+	for notification := range dl.listener.Notify {
+		// a nil notification is sent after reconnecting, events may have been lost
+		if notification == nil {
+			logger.Info("WARNING: listener reconnected, events may have been missed!")
+			l.reconnected(database, dl)
+			continue
+		}
+		event := Event{}
+		if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+			logger.Error(err, "Unable to decode event", "payload", notification.Extra)
+			continue
+		}
+
+		l.mu.Lock()
+		subscribers := make([]*subscriber, 0, len(dl.subscribers[notification.Channel]))
+		for s := range dl.subscribers[notification.Channel] {
+			subscribers = append(subscribers, s)
+		}
+		l.mu.Unlock()
+		for _, s := range subscribers {
+			select {
+			case s.events <- event:
+			case <-s.ctx.Done():
+			}
+		}
+	}
+}
+
+// closeListener closes the listener connection, logging errors.
+func (l *Listener) closeListener(database string, dl *databaseListener) {
+	if err := dl.listener.Close(); err != nil {
+		l.logger.Error(err, "Error on closing listener", "database", database)
+	}
+}
+
+// reconnected drops every subscriber of the database listener, sending them an error event and
+// closing their channels, since events published while disconnected are lost. The connection is
+// closed, and later subscribers open a new one.
+func (l *Listener) reconnected(database string, dl *databaseListener) {
+	l.mu.Lock()
+	active := l.databases[database] == dl
+	if active {
+		delete(l.databases, database)
+	}
+	subscribers := dl.subscribers
+	dl.subscribers = map[string]map[*subscriber]struct{}{}
+	l.mu.Unlock()
+
+	for _, channelSubscribers := range subscribers {
+		for s := range channelSubscribers {
+			select {
+			case s.events <- Event{Type: watch.Error}:
+			case <-s.ctx.Done():
+			}
+			close(s.events)
+		}
+	}
+	// connections without subscribers are closed on unsubscribing
+	if active {
+		l.closeListener(database, dl)
+	}
+}
+
+// unsubscribe removes the subscriber from the database listener, no longer listening on its
+// channel when it was the last subscriber, and closing the connection when no subscribers are left.
+func (l *Listener) unsubscribe(database, channel string, dl *databaseListener, s *subscriber) {
+	l.mu.Lock()
+	if _, found := dl.subscribers[channel][s]; !found {
+		l.mu.Unlock()
+		return
+	}
+	delete(dl.subscribers[channel], s)
+	if len(dl.subscribers[channel]) == 0 {
+		delete(dl.subscribers, channel)
+	}
+	last := len(dl.subscribers) == 0 && l.databases[database] == dl
+	if last {
+		delete(l.databases, database)
+	}
+	l.mu.Unlock()
+
+	if last {
+		l.closeListener(database, dl)
+		return
+	}
+	// network calls are made without holding the lock, which dispatching needs to progress
+	dl.listenMu.Lock()
+	defer dl.listenMu.Unlock()
+	l.mu.Lock()
+	_, subscribed := dl.subscribers[channel]
+	l.mu.Unlock()
+	if subscribed {
+		return
+	}
+	if err := dl.listener.Unlisten(channel); err != nil && err != pq.ErrChannelNotOpen {
+		l.logger.Error(err, "Error on unlistening", "database", database, "channel", channel)
+	}
+}
+
+// Subscribe listens for change events published on the ORM's database and search-path, sending
+// them over the returned channel until the context is done. Since events are published via
+// PostgreSQL, changes made by any instance sharing the database are observed. When the listener
+// connection is re-established, events may have been missed, thus an event of type watch.Error is
+// sent and the channel is closed. It can return error on listening.
+func (l *Listener) Subscribe(ctx context.Context, o *ORM) (<-chan Event, error) {
+	s := &subscriber{ctx: ctx, events: make(chan Event)}
+	l.mu.Lock()
+	dl := l.listen(o)
+	if _, found := dl.subscribers[o.searchPath]; !found {
+		dl.subscribers[o.searchPath] = map[*subscriber]struct{}{}
+	}
+	dl.subscribers[o.searchPath][s] = struct{}{}
+	l.mu.Unlock()
+
+	dl.listenMu.Lock()
+	err := dl.listener.Listen(o.searchPath)
+	dl.listenMu.Unlock()
+	if err != nil && err != pq.ErrChannelAlreadyOpen {
+		l.unsubscribe(o.database, o.searchPath, dl, s)
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		l.unsubscribe(o.database, o.searchPath, dl, s)
+	}()
+	return s.events, nil
+}
+
+// NewListener instantiate a Listener, without connections.
+func NewListener(logger logr.Logger) *Listener {
+	return &Listener{
+		logger:    logger.WithName("listener"),
+		databases: map[string]*databaseListener{},
+	}
+}
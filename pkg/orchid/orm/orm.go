@@ -2,10 +2,14 @@ package orm
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/go-logr/logr"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/isutton/orchid/pkg/orchid/config"
@@ -41,7 +45,21 @@ type MappedEntries map[string][]Entry
 // driverName database driver
 const driverName = "postgres"
 
-// createDatabase create an PostgreSQL database.
+// StatusColumnName column name holding the status of objects, in the main table.
+const StatusColumnName = "status"
+
+// ResourceVersionColumnName metadata column name holding the resource-version.
+const ResourceVersionColumnName = "resourceVersion"
+
+// SchemaMismatchErr returned when existing tables do not match the schema.
+var SchemaMismatchErr = errors.New("existing tables do not match schema")
+
+// ResourceVersionConflictErr returned when the resource-version informed on update does not match
+// the stored one.
+var ResourceVersionConflictErr = errors.New("resource-version does not match")
+
+// createDatabase create an PostgreSQL database, marking it as in use by orchid. Existing databases
+// are marked as well, since they may have been created before marking.
 func (o *ORM) createDatabase() error {
 	var exists int = 0
 	err := o.DB.QueryRow(SelectDatabaseStatement(), o.database).Scan(&exists)
@@ -50,11 +68,13 @@ func (o *ORM) createDatabase() error {
 	}
 	if exists == 1 {
 		o.logger.Info("Database already exists!")
-		return nil
+	} else {
+		o.logger.Info("Creating database...")
+		if _, err = o.DB.Exec(CreateDatabaseStatement(o.database)); err != nil {
+			return err
+		}
 	}
-
-	o.logger.Info("Creating database...")
-	_, err = o.DB.Exec(CreateDatabaseStatement(o.database))
+	_, err = o.DB.Exec(CommentDatabaseStatement(o.database))
 	return err
 }
 
@@ -65,7 +85,8 @@ func (o *ORM) createSchema() error {
 }
 
 // Bootstrap initial connection to make sure database is present, and a second connection to then
-// create schema, making sure subsequent queries will use the schema as search-path.
+// create schema and its events log, making sure subsequent queries will use the schema as
+// search-path.
 func (o *ORM) Bootstrap() error {
 	// connecting with a privileged user first to create database and schema
 	if err := o.connect("postgres", "public"); err != nil {
@@ -87,10 +108,39 @@ func (o *ORM) Bootstrap() error {
 	if err := o.createSchema(); err != nil {
 		return err
 	}
-	_, err := o.DB.Exec(fmt.Sprintf("set search_path='%s'", o.searchPath))
+	if _, err := o.DB.Exec(fmt.Sprintf("set search_path='%s'", o.searchPath)); err != nil {
+		return err
+	}
+	_, err := o.DB.Exec(CreateEventsTableStatement())
 	return err
 }
 
+// Connect to the database and search-path, without creating them as Bootstrap does. It can return
+// error on opening the connection.
+func (o *ORM) Connect() error {
+	return o.connect(o.database, o.searchPath)
+}
+
+// Databases lists the databases marked by orchid in the instance, which represent namespaces. It
+// can return errors on querying and scanning rows.
+func (o *ORM) Databases() ([]string, error) {
+	rows, err := o.DB.Query(SelectDatabasesStatement())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	databases := []string{}
+	for rows.Next() {
+		var database string
+		if err = rows.Scan(&database); err != nil {
+			return nil, err
+		}
+		databases = append(databases, database)
+	}
+	return databases, rows.Err()
+}
+
 // CreateTables create tables for a schema.
 func (o *ORM) CreateTables(schema *Schema) error {
 	for _, statement := range CreateTablesStatement(schema) {
@@ -103,8 +153,60 @@ func (o *ORM) CreateTables(schema *Schema) error {
 	return nil
 }
 
-// connect with the database, instantiate the connection.
-func (o *ORM) connect(dbname, searchPath string) error {
+// Columns returns the existing columns and their stored types, keyed by table name, for the tables
+// in schema. Tables not yet created are absent. It can return error on querying.
+func (o *ORM) Columns(schema *Schema) (map[string]map[string]string, error) {
+	tableNames := make([]string, 0, len(schema.Tables))
+	for _, table := range schema.Tables {
+		tableNames = append(tableNames, table.Name)
+	}
+	rows, err := o.DB.Query(SelectColumnsStatement(), o.searchPath, pq.Array(tableNames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]map[string]string{}
+	for rows.Next() {
+		var tableName, columnName, columnType string
+		if err = rows.Scan(&tableName, &columnName, &columnType); err != nil {
+			return nil, err
+		}
+		if _, exists := columns[tableName]; !exists {
+			columns[tableName] = map[string]string{}
+		}
+		columns[tableName][columnName] = columnType
+	}
+	return columns, rows.Err()
+}
+
+// VerifyTables compares existing tables with the ones in schema, tables not yet created are
+// ignored. It returns SchemaMismatchErr describing the differences found, and can return errors on
+// querying.
+func (o *ORM) VerifyTables(schema *Schema) error {
+	columns, err := o.Columns(schema)
+	if err != nil {
+		return err
+	}
+	differences := []string{}
+	for _, table := range schema.Tables {
+		existing, found := columns[table.Name]
+		if !found {
+			continue
+		}
+		for _, difference := range table.Diff(existing) {
+			differences = append(differences, fmt.Sprintf("table '%s': %s", table.Name, difference))
+		}
+	}
+	if len(differences) > 0 {
+		return fmt.Errorf("%w in database '%s' schema '%s': %s",
+			SchemaMismatchErr, o.database, o.searchPath, strings.Join(differences, ", "))
+	}
+	return nil
+}
+
+// connectionString returns the libpq connection string for database and search-path informed.
+func (o *ORM) connectionString(dbname, searchPath string) string {
 	connStr := fmt.Sprintf(
 		"user=%s password=%s dbname=%s search_path=%s",
 		o.config.Username,
@@ -115,8 +217,13 @@ func (o *ORM) connect(dbname, searchPath string) error {
 	if o.config.Options != "" {
 		connStr = fmt.Sprintf("%s %s", connStr, o.config.Options)
 	}
+	return connStr
+}
+
+// connect with the database, instantiate the connection.
+func (o *ORM) connect(dbname, searchPath string) error {
 	var err error
-	o.DB, err = sql.Open(driverName, connStr)
+	o.DB, err = sql.Open(driverName, o.connectionString(dbname, searchPath))
 	return err
 }
 
@@ -189,9 +296,114 @@ func (o *ORM) scanRows(schema *Schema, rows *sql.Rows) (*ResultSet, error) {
 	return NewResultSet(schema, columnIDs, matrix)
 }
 
-// dbSelect execute a select against the schema tables using where clause and arguments informed.
-// It can return errors on executing the query and building the result-set.
-func (o *ORM) dbSelect(schema *Schema, where []string, arguments []interface{}) (*ResultSet, error) {
+// queryer represents the common query interface of sql.DB and sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// selectRelatedTable selects the rows of table where column is amongst the values informed, adding
+// them to the result-set. When selecting by primary-key, null values stand for related rows which
+// are not present, as in a left-join. It can return errors from querying the database.
+func (o *ORM) selectRelatedTable(
+	q queryer,
+	rs *ResultSet,
+	table *Table,
+	columnName string,
+	values List,
+) error {
+	if _, found := rs.Data[table.Name]; !found {
+		rs.Data[table.Name] = []Entry{}
+	}
+	ids := []int64{}
+	for _, value := range values {
+		id, ok := value.(int64)
+		if !ok {
+			if value == nil && columnName == PKColumnName {
+				rs.addNull(table)
+			}
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	statement := SelectRelatedStatement(table, columnName)
+	o.logger.WithValues("table", table.Name, "ids", len(ids)).Info("Selecting related rows...")
+	rows, err := q.Query(statement, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	columnIDs, matrix, err := o.resultMatrix(rs.schema, rows)
+	if err != nil {
+		return err
+	}
+	return rs.add(columnIDs, matrix)
+}
+
+// selectRelated walks from table towards the tables related to it, selecting the rows of tables
+// under one-to-many relationships, by the keys already present in the result-set. Tables selected
+// together with the main table are only walked through. It can return errors on finding tables and
+// querying the database.
+func (o *ORM) selectRelated(q queryer, schema *Schema, rs *ResultSet, table *Table) error {
+	if rs.Len(table.Name) == 0 {
+		return nil
+	}
+	// one-to-one: tables referred by foreign-keys of this table
+	for _, constraint := range table.ForeignKeys() {
+		// one-to-many tables refer back to the table above them, named after it
+		if constraint.ColumnName == constraint.RelatedTableName {
+			continue
+		}
+		related, err := schema.GetTable(constraint.RelatedTableName)
+		if err != nil {
+			return err
+		}
+		if schema.UnderOneToMany(related.Path) {
+			values, err := rs.GetColumn(table.Name, constraint.ColumnName)
+			if err != nil {
+				return err
+			}
+			if err = o.selectRelatedTable(q, rs, related, PKColumnName, values); err != nil {
+				return err
+			}
+		}
+		if err = o.selectRelated(q, schema, rs, related); err != nil {
+			return err
+		}
+	}
+
+	// one-to-many: tables referring back to this table
+	for _, relatedTableName := range schema.OneToManyTables(table.Name) {
+		related, err := schema.GetTable(relatedTableName)
+		if err != nil {
+			return err
+		}
+		pks, err := rs.GetColumn(table.Name, PKColumnName)
+		if err != nil {
+			return err
+		}
+		if err = o.selectRelatedTable(q, rs, related, table.Name, pks); err != nil {
+			return err
+		}
+		if err = o.selectRelated(q, schema, rs, related); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dbSelect execute a select against the main table, and the tables related to it by one-to-one
+// relationships, using where clause and arguments informed. Tables under one-to-many relationships
+// are selected afterwards, one query per table, by the keys of the rows found. It can return
+// errors on finding tables and querying the database.
+func (o *ORM) dbSelect(
+	q queryer,
+	schema *Schema,
+	where []string,
+	arguments []interface{},
+) (*ResultSet, error) {
 	statement, err := SelectStatement(schema, where)
 	if err != nil {
 		return nil, err
@@ -200,32 +412,54 @@ func (o *ORM) dbSelect(schema *Schema, where []string, arguments []interface{})
 	o.logger.WithValues("where", where, "arguments", arguments).Info("Executing select statement...")
 	fmt.Printf("---\nSET search_path='%s';%s;\n---\n\n", o.searchPath, FormatStatement(statement))
 
-	rows, err := o.DB.Query(statement, arguments...)
+	rows, err := q.Query(statement, arguments...)
 	if err != nil {
 		return nil, err
 	}
-	return o.scanRows(schema, rows)
+	rs, err := o.scanRows(schema, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	mainTable, err := schema.GetTable(schema.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err = o.selectRelated(q, schema, rs, mainTable); err != nil {
+		return nil, err
+	}
+	return rs, nil
 }
 
-// Read namespaced-name from database, returned as a result-set instance.
-// Create stores a given object in the database.
-func (o *ORM) Create(schema *Schema, matrix MappedMatrix) error {
+// insert stores the data matrix using informed transaction, following schema tables sequence in
+// order to have foreign-keys available on the subsequent statements.
+func (o *ORM) insert(txn *sql.Tx, schema *Schema, matrix MappedMatrix) error {
+	_, err := o.insertTables(txn, schema, matrix, nil, map[string]int64{})
+	return err
+}
+
+// insertTables stores the data matrix of the tables informed, or all schema tables when nil, using
+// informed transaction. Foreign-keys are taken from the primary-key cache, which is populated with
+// the primary-key of the last row inserted per table, and returned.
+func (o *ORM) insertTables(
+	txn *sql.Tx,
+	schema *Schema,
+	matrix MappedMatrix,
+	tables map[string]bool,
+	tablePKCache map[string]int64,
+) (map[string]int64, error) {
 	rows := len(matrix)
 	if rows == 0 {
-		return fmt.Errorf("empty data informed")
+		return nil, fmt.Errorf("empty data informed")
 	}
 	logger := o.logger.WithValues("matrix-rows", rows, "schema", schema.Name)
-	logger.Info("Executing create against informed schema.")
-
 	statements := InsertStatement(schema)
 
-	txn, err := o.DB.Begin()
-	if err != nil {
-		return err
-	}
-
-	tablePKCache := make(map[string]int64, len(statements))
+	var err error
 	for i, table := range schema.Tables {
+		if tables != nil && !tables[table.Name] {
+			continue
+		}
 		statement := statements[i]
 		arguments, found := matrix[table.Name]
 		if !found {
@@ -246,54 +480,514 @@ func (o *ORM) Create(schema *Schema, matrix MappedMatrix) error {
 			// completing argument with foreign-keys values, cached from previous statements
 			if len(argument) < len(table.Columns)-1 {
 				if argument, err = o.interpolate(table, argument, tablePKCache); err != nil {
-					return err
+					return nil, err
 				}
 			}
 			// executing insert statement and capturing primary-key
 			var primaryKeyValue int64
 			if err = txn.QueryRow(statement, argument...).Scan(&primaryKeyValue); err != nil {
-				return err
+				return nil, err
 			}
 			tablePKCache[table.Name] = primaryKeyValue
 		}
 	}
-
-	return txn.Commit()
+	return tablePKCache, nil
 }
 
-// Read a single namespaced name from database, building back a result-set. It can return errors
-// from querying the databae and building the result-set.
-func (o *ORM) Read(schema *Schema, namespacedName types.NamespacedName) (*ResultSet, error) {
+// namespacedNameWhere returns the where clause and arguments to find a single namespaced-name.
+func (o *ORM) namespacedNameWhere(
+	schema *Schema,
+	namespacedName types.NamespacedName,
+) ([]string, []interface{}, error) {
 	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	where := []string{
 		fmt.Sprintf("%s.namespace=$1", metadataTable.Hint),
 		fmt.Sprintf("%s.name=$2", metadataTable.Hint),
 	}
 	arguments := []interface{}{namespacedName.Namespace, namespacedName.Name}
-	return o.dbSelect(schema, where, arguments)
+	return where, arguments, nil
 }
 
-// List all items matching labels informed. It can return errors from querying the database,
-// and building a result-set with rows.
-func (o *ORM) List(schema *Schema, labelsSet map[string]string) (*ResultSet, error) {
-	where := []string{}
-	arguments := []interface{}{}
-	if len(labelsSet) > 0 {
-		labelsTable, err := schema.GetTable(fmt.Sprintf("%s_metadata_labels", schema.Name))
+// primaryKeys extract primary-keys from result-set per table name, skipping null values.
+func (o *ORM) primaryKeys(schema *Schema, rs *ResultSet) (map[string][]int64, error) {
+	pks := map[string][]int64{}
+	for _, table := range schema.Tables {
+		if rs.Len(table.Name) == 0 {
+			continue
+		}
+		column, err := rs.GetColumn(table.Name, PKColumnName)
 		if err != nil {
 			return nil, err
 		}
-		for label, value := range labelsSet {
-			where = append(where, fmt.Sprintf("%s.key=$1", labelsTable.Hint))
-			where = append(where, fmt.Sprintf("%s.value=$2", labelsTable.Hint))
-			arguments = append(arguments, label)
-			arguments = append(arguments, value)
+		for _, value := range column {
+			if pk, ok := value.(int64); ok {
+				pks[table.Name] = append(pks[table.Name], pk)
+			}
+		}
+	}
+	return pks, nil
+}
+
+// deleteRows removes every row belonging to a single object, across all schema tables. Tables are
+// visited in reverse order, so rows holding foreign-keys are removed before the rows they point to.
+// The rows removed are returned as a result-set. It returns sql.ErrNoRows when the object is not
+// found.
+func (o *ORM) deleteRows(
+	txn *sql.Tx,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+) (*ResultSet, error) {
+	where, arguments, err := o.namespacedNameWhere(schema, namespacedName)
+	if err != nil {
+		return nil, err
+	}
+	mainTable, err := schema.GetTable(schema.Name)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := o.dbSelect(txn, schema, where, arguments)
+	if err != nil {
+		return nil, err
+	}
+	if rs.Len(mainTable.Name) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	pks, err := o.primaryKeys(schema, rs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range schema.TablesReversed() {
+		ids, found := pks[table.Name]
+		if !found {
+			continue
+		}
+		statement := DeleteStatement(table)
+		o.logger.WithValues("statement", statement, "ids", ids).Info("Executing delete")
+		if _, err = txn.Exec(statement, pq.Array(ids)); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// checkResourceVersion compares the resource-version stored for a single object with the expected
+// one, locking the object's metadata row until the end of the transaction. It returns
+// ResourceVersionConflictErr when versions differ, and sql.ErrNoRows when the object is not found.
+func (o *ORM) checkResourceVersion(
+	txn *sql.Tx,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	expected string,
+) error {
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return err
+	}
+	statement := SelectResourceVersionStatement(metadataTable)
+
+	var stored sql.NullString
+	// when a concurrent transaction replaces the object while waiting for the lock, the row found
+	// is gone, a second attempt finds the new row instead
+	for attempt := 0; attempt < 2; attempt++ {
+		err = txn.QueryRow(statement, namespacedName.Namespace, namespacedName.Name).Scan(&stored)
+		if err != sql.ErrNoRows {
+			break
 		}
 	}
-	return o.dbSelect(schema, where, arguments)
+	if err != nil {
+		return err
+	}
+	if stored.String != expected {
+		o.logger.WithValues("stored", stored.String, "expected", expected).
+			Info("Resource-version does not match")
+		return ResourceVersionConflictErr
+	}
+	return nil
+}
+
+// update replaces the rows of a given object using informed transaction, checking the stored
+// resource-version first, when informed.
+func (o *ORM) update(
+	txn *sql.Tx,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	resourceVersion string,
+	matrix MappedMatrix,
+) error {
+	if resourceVersion != "" {
+		err := o.checkResourceVersion(txn, schema, namespacedName, resourceVersion)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := o.deleteRows(txn, schema, namespacedName); err != nil {
+		return err
+	}
+	return o.insert(txn, schema, matrix)
+}
+
+// statusValue returns the value for status column in main table, either the primary-key of the
+// status row inserted, when stored on a table, or the value in the data matrix.
+func (o *ORM) statusValue(
+	mainTable *Table,
+	matrix MappedMatrix,
+	insertedPKs map[string]int64,
+) (interface{}, error) {
+	if mainTable.IsForeignKey(StatusColumnName) {
+		if pk, found := insertedPKs[mainTable.ForeignKeyTable(StatusColumnName)]; found {
+			return pk, nil
+		}
+		return sql.NullInt64{}, nil
+	}
+	rows := matrix[mainTable.Name]
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("unable to find data for table '%s'", mainTable.Name)
+	}
+	for position, columnName := range mainTable.ColumnNamesStripped() {
+		if columnName == StatusColumnName {
+			return rows[0][position], nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find column '%s' in table '%s'", StatusColumnName, mainTable.Name)
+}
+
+// updateStatus replaces the rows derived from the status property and managed-fields of a given
+// object using informed transaction, and changes its resource-version. New rows are inserted first,
+// having the main table pointing to them, and then the previous rows are removed.
+func (o *ORM) updateStatus(
+	txn *sql.Tx,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	expectedResourceVersion string,
+	resourceVersion string,
+	matrix MappedMatrix,
+) error {
+	if expectedResourceVersion != "" {
+		err := o.checkResourceVersion(txn, schema, namespacedName, expectedResourceVersion)
+		if err != nil {
+			return err
+		}
+	}
+	mainTable, err := schema.GetTable(schema.Name)
+	if err != nil {
+		return err
+	}
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return err
+	}
+	where, arguments, err := o.namespacedNameWhere(schema, namespacedName)
+	if err != nil {
+		return err
+	}
+	rs, err := o.dbSelect(txn, schema, where, arguments)
+	if err != nil {
+		return err
+	}
+	if rs.Len(mainTable.Name) == 0 || rs.Len(metadataTable.Name) == 0 {
+		return sql.ErrNoRows
+	}
+	pks, err := o.primaryKeys(schema, rs)
+	if err != nil {
+		return err
+	}
+	mainPK := pks[mainTable.Name][0]
+
+	statusTables := map[string]bool{}
+	for _, table := range schema.TablesUnderPath([]string{StatusColumnName}) {
+		statusTables[table.Name] = true
+	}
+	// managed-fields record the manager of status writes, and are replaced alongside status
+	for _, table := range schema.TablesUnderPath([]string{"metadata", "managedFields"}) {
+		statusTables[table.Name] = true
+	}
+	// status and managed-fields rows find the main and metadata primary-keys in cache
+	insertedPKs := map[string]int64{
+		mainTable.Name:     mainPK,
+		metadataTable.Name: pks[metadataTable.Name][0],
+	}
+	if len(statusTables) > 0 {
+		insertedPKs, err = o.insertTables(txn, schema, matrix, statusTables, insertedPKs)
+		if err != nil {
+			return err
+		}
+	}
+	if mainTable.GetColumn(StatusColumnName) != nil {
+		value, err := o.statusValue(mainTable, matrix, insertedPKs)
+		if err != nil {
+			return err
+		}
+		statement := UpdateColumnStatement(mainTable, StatusColumnName)
+		if _, err = txn.Exec(statement, value, mainPK); err != nil {
+			return err
+		}
+	}
+
+	// removing previous status rows, no longer referenced by the main table
+	for _, table := range schema.TablesReversed() {
+		ids, found := pks[table.Name]
+		if !found || !statusTables[table.Name] {
+			continue
+		}
+		if _, err = txn.Exec(DeleteStatement(table), pq.Array(ids)); err != nil {
+			return err
+		}
+	}
+
+	statement := UpdateColumnStatement(metadataTable, ResourceVersionColumnName)
+	_, err = txn.Exec(statement, resourceVersion, pks[metadataTable.Name][0])
+	return err
+}
+
+// Read a single namespaced name from database, building back a result-set. It can return errors
+// from querying the databae and building the result-set.
+func (o *ORM) Read(schema *Schema, namespacedName types.NamespacedName) (*ResultSet, error) {
+	where, arguments, err := o.namespacedNameWhere(schema, namespacedName)
+	if err != nil {
+		return nil, err
+	}
+	return o.dbSelect(o.DB, schema, where, arguments)
+}
+
+// labelSelectorWhere appends to where and arguments the clauses matching label selector
+// requirements against the metadata labels table. It can return errors on finding tables and
+// translating requirements.
+func (o *ORM) labelSelectorWhere(
+	schema *Schema,
+	selector labels.Selector,
+	where []string,
+	arguments []interface{},
+) ([]string, []interface{}, error) {
+	if selector == nil {
+		return where, arguments, nil
+	}
+	requirements, selectable := selector.Requirements()
+	if !selectable || len(requirements) == 0 {
+		return where, arguments, nil
+	}
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	kvTable, err := schema.GetTable(fmt.Sprintf("%s_labels", metadataTable.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, requirement := range requirements {
+		clause, args, err := SelectorWhere(
+			kvTable, metadataTable.Name, metadataTable.Hint, requirement, len(arguments)+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, clause)
+		arguments = append(arguments, args...)
+	}
+	return where, arguments, nil
+}
+
+// fieldSelectorWhere appends to where and arguments the clauses matching field selector
+// requirements against the columns storing each field. It can return errors on fields which are
+// not selectable, values not matching column types and unsupported operators.
+func (o *ORM) fieldSelectorWhere(
+	schema *Schema,
+	selector fields.Selector,
+	where []string,
+	arguments []interface{},
+) ([]string, []interface{}, error) {
+	if selector == nil {
+		return where, arguments, nil
+	}
+	for _, requirement := range selector.Requirements() {
+		table, column, err := schema.SelectableColumn(strings.Split(requirement.Field, "."))
+		if err != nil {
+			return nil, nil, err
+		}
+		value, err := column.ParseValue(requirement.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: '%s': %s", FieldNotSelectableErr, requirement.Field, err)
+		}
+		clause, err := FieldSelectorWhere(table, column, requirement.Operator, len(arguments)+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, clause)
+		arguments = append(arguments, value)
+	}
+	return where, arguments, nil
+}
+
+// ListOptions narrow down listed items, where nil selectors match everything.
+type ListOptions struct {
+	LabelSelector labels.Selector // matched against metadata labels
+	FieldSelector fields.Selector // matched against columns storing scalar fields
+	Limit         int64           // maximum amount of items, when positive
+	Continue      string          // name of the last item of the previous page
+}
+
+// pageWhere appends to where and arguments the clause skipping items up to the continue option,
+// and the limit argument, returning the position of its placeholder, or zero when no limit is
+// informed. It can return errors on finding the metadata table.
+func (o *ORM) pageWhere(
+	schema *Schema,
+	options *ListOptions,
+	where []string,
+	arguments []interface{},
+) ([]string, []interface{}, int, error) {
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if options.Continue != "" {
+		where = append(where, NameAfterWhere(metadataTable, len(arguments)+1))
+		arguments = append(arguments, options.Continue)
+	}
+	limitPosition := 0
+	if options.Limit > 0 {
+		arguments = append(arguments, options.Limit)
+		limitPosition = len(arguments)
+	}
+	return where, arguments, limitPosition, nil
+}
+
+// listPage selects the primary-keys of a single page of the main table, ordered by name, and then
+// selects all rows belonging to them. It can return errors from querying the database, and
+// building a result-set with rows.
+func (o *ORM) listPage(
+	schema *Schema,
+	options *ListOptions,
+	where []string,
+	arguments []interface{},
+) (*ResultSet, error) {
+	mainTable, err := schema.GetTable(schema.Name)
+	if err != nil {
+		return nil, err
+	}
+	where, arguments, limitPosition, err := o.pageWhere(schema, options, where, arguments)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := SelectPageStatement(schema, where, limitPosition)
+	if err != nil {
+		return nil, err
+	}
+
+	o.logger.WithValues("where", where, "arguments", arguments).Info("Selecting page...")
+	rows, err := o.DB.Query(statement, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	where = []string{fmt.Sprintf("%s.%s = any($1)", mainTable.Hint, PKColumnName)}
+	return o.dbSelect(o.DB, schema, where, []interface{}{pq.Array(ids)})
+}
+
+// listWhere returns the where clauses and arguments matching the selectors of the options informed.
+// It can return errors from translating selectors.
+func (o *ORM) listWhere(schema *Schema, options *ListOptions) ([]string, []interface{}, error) {
+	where, arguments, err := o.labelSelectorWhere(schema, options.LabelSelector, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return o.fieldSelectorWhere(schema, options.FieldSelector, where, arguments)
+}
+
+// List all items matching the options informed, nil options match everything. When a limit or
+// continue is informed, items are paged by name. It can return errors from translating selectors,
+// querying the database, and building a result-set with rows.
+func (o *ORM) List(schema *Schema, options *ListOptions) (*ResultSet, error) {
+	if options == nil {
+		options = &ListOptions{}
+	}
+	where, arguments, err := o.listWhere(schema, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Limit > 0 || options.Continue != "" {
+		return o.listPage(schema, options, where, arguments)
+	}
+	return o.dbSelect(o.DB, schema, where, arguments)
+}
+
+// selectJSON executes the select statement building objects as JSON documents, returning one
+// document per object. It can return errors from generating the statement and querying.
+func (o *ORM) selectJSON(
+	schema *Schema,
+	where []string,
+	arguments []interface{},
+	limitPosition int,
+) ([][]byte, error) {
+	statement, err := SelectJSONStatement(schema, where, limitPosition)
+	if err != nil {
+		return nil, err
+	}
+
+	o.logger.WithValues("where", where, "arguments", arguments).Info("Selecting JSON documents...")
+	rows, err := o.DB.Query(statement, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	documents := [][]byte{}
+	for rows.Next() {
+		var document []byte
+		if err = rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, rows.Err()
+}
+
+// ReadJSON a single namespaced name from database, built as a JSON document by the database. It
+// returns sql.ErrNoRows when the object is not found, and errors from querying the database.
+func (o *ORM) ReadJSON(schema *Schema, namespacedName types.NamespacedName) ([]byte, error) {
+	where, arguments, err := o.namespacedNameWhere(schema, namespacedName)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := o.selectJSON(schema, where, arguments, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return documents[0], nil
+}
+
+// ListJSON all items matching the options informed, built as JSON documents by the database and
+// ordered by name, nil options match everything. It can return errors from translating selectors
+// and querying the database.
+func (o *ORM) ListJSON(schema *Schema, options *ListOptions) ([][]byte, error) {
+	if options == nil {
+		options = &ListOptions{}
+	}
+	where, arguments, err := o.listWhere(schema, options)
+	if err != nil {
+		return nil, err
+	}
+	where, arguments, limitPosition, err := o.pageWhere(schema, options, where, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return o.selectJSON(schema, where, arguments, limitPosition)
 }
 
 // NewORM instantiate an ORM.
@@ -1,9 +1,12 @@
 package orm
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/klogr"
 
@@ -34,7 +37,7 @@ func TestORM_New(t *testing.T) {
 
 	// t.Run("Create", func(t *testing.T) {
 	// 	arguments := mocks.RepositoryArgumentsMock()
-	// 	err := orm.Create(schema, arguments)
+	// 	err := orm.Create(schema, namespacedName, arguments)
 	// 	require.NoError(t, err)
 	// })
 
@@ -44,4 +47,27 @@ func TestORM_New(t *testing.T) {
 		assert.NoError(t, err)
 		t.Logf("data='%+v'", data)
 	})
+
+	// subscribers on the same database share a single listener connection
+	t.Run("Listener", func(t *testing.T) {
+		// listening blocks until connected
+		require.NoError(t, pgORM.DB.Ping())
+		listener := NewListener(logger)
+		ctx, cancel := context.WithCancel(context.Background())
+		for i := 0; i < 2; i++ {
+			_, err := listener.Subscribe(ctx, pgORM)
+			require.NoError(t, err)
+		}
+		listener.mu.Lock()
+		assert.Len(t, listener.databases, 1)
+		assert.Len(t, listener.databases[pgORM.database].subscribers[pgORM.searchPath], 2)
+		listener.mu.Unlock()
+
+		cancel()
+		require.Eventually(t, func() bool {
+			listener.mu.Lock()
+			defer listener.mu.Unlock()
+			return len(listener.databases) == 0
+		}, 10*time.Second, 100*time.Millisecond)
+	})
 }
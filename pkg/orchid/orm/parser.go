@@ -34,6 +34,13 @@ func (j *Parser) expandAdditionalProperties(
 	return jsc.JSONSchemaProps(jsc.Object, "", required, nil, properties)
 }
 
+// preserveUnknownFields checks if the object is flagged with x-kubernetes-preserve-unknown-fields
+// while not describing its properties.
+func (j *Parser) preserveUnknownFields(jsSchema extv1.JSONSchemaProps) bool {
+	return jsSchema.XPreserveUnknownFields != nil && *jsSchema.XPreserveUnknownFields &&
+		jsSchema.AdditionalProperties == nil && len(jsSchema.Properties) == 0
+}
+
 // object creates extra column and recursively new tables.
 func (j *Parser) object(
 	table *Table,
@@ -46,6 +53,14 @@ func (j *Parser) object(
 	additionalProperties := jsSchema.AdditionalProperties
 	relatedTableName := fmt.Sprintf("%s_%s", table.Name, columnName)
 
+	// objects without a known structure are kept as they are, in a JSONB column
+	if j.preserveUnknownFields(jsSchema) {
+		logger.Info("Adding JSONB column for x-kubernetes-preserve-unknown-fields.")
+		table.AddColumn(
+			&Column{Name: columnName, Type: PgTypeJSONB, JSType: jsc.Object, NotNull: notNull})
+		return nil
+	}
+
 	// making sure either AdditionalProperties or Properties are set
 	if (additionalProperties == nil && len(jsSchema.Properties) == 0) ||
 		(additionalProperties != nil && len(jsSchema.Properties) > 0) {
@@ -197,25 +212,27 @@ func (j *Parser) Parse(
 	}
 
 	var err error
-	for name, jsSchema := range jsSchema.Properties {
-		// checking if property name required, therefore not null column
+	for name, property := range jsSchema.Properties {
+		// checking if property name is required by the object holding it, therefore not null
+		// column; tables created before required was read from the holding object have nullable
+		// columns, which are only made not-null by a forced migration
 		notNull := StringSliceContains(jsSchema.Required, name)
 
-		switch jsSchema.Type {
+		switch property.Type {
 		case jsc.Object:
-			err = j.object(table, name, notNull, jsSchema)
+			err = j.object(table, name, notNull, property)
 		case jsc.Array:
-			err = j.array(table, name, notNull, jsSchema)
+			err = j.array(table, name, notNull, property)
 		case jsc.Boolean:
-			err = j.column(table, name, notNull, jsSchema)
+			err = j.column(table, name, notNull, property)
 		case jsc.String:
-			err = j.column(table, name, notNull, jsSchema)
+			err = j.column(table, name, notNull, property)
 		case jsc.Integer:
-			err = j.column(table, name, notNull, jsSchema)
+			err = j.column(table, name, notNull, property)
 		case jsc.Number:
-			err = j.column(table, name, notNull, jsSchema)
+			err = j.column(table, name, notNull, property)
 		default:
-			return fmt.Errorf("unknown json-schema type '%s'", jsSchema.Type)
+			return fmt.Errorf("unknown json-schema type '%s'", property.Type)
 		}
 	}
 	return err
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/klog/klogr"
 
 	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
@@ -25,6 +26,31 @@ func TestParser_Parse(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("required", func(t *testing.T) {
+		schema := NewSchema(logger, schemaName)
+		parser := NewParser(logger, schema)
+
+		// properties are required by the object holding them, not by themselves
+		openAPIV3Schema := jsc.JSONSchemaProps(jsc.Object, "", []string{"required"}, nil,
+			map[string]extv1.JSONSchemaProps{
+				"required": jsc.StringProp,
+				"optional": jsc.JSONSchemaProps(jsc.String, "", []string{"optional"}, nil, nil),
+			})
+		err := parser.Parse(schema.Name, Relationship{}, &openAPIV3Schema)
+		require.NoError(t, err)
+
+		table, err := parser.schema.GetTable(schemaName)
+		require.NoError(t, err)
+
+		column := table.GetColumn("required")
+		require.NotNil(t, column)
+		assert.True(t, column.NotNull)
+
+		column = table.GetColumn("optional")
+		require.NotNil(t, column)
+		assert.False(t, column.NotNull)
+	})
+
 	t.Run("x-kubernetes-embedded-resource", func(t *testing.T) {
 		schema := NewSchema(logger, schemaName)
 		parser := NewParser(logger, schema)
@@ -41,6 +67,25 @@ func TestParser_Parse(t *testing.T) {
 		assert.Equal(t, PgTypeJSONB, column.Type)
 	})
 
+	t.Run("x-kubernetes-preserve-unknown-fields", func(t *testing.T) {
+		schema := NewSchema(logger, schemaName)
+		parser := NewParser(logger, schema)
+
+		openAPIV3Schema := jsc.JSONSchemaProps(jsc.Object, "", nil, nil,
+			map[string]extv1.JSONSchemaProps{"unknown": jsc.PreserveUnknownFieldsProp()})
+		err := parser.Parse(schema.Name, Relationship{}, &openAPIV3Schema)
+		assert.NoError(t, err)
+
+		table, err := parser.schema.GetTable(schemaName)
+		assert.NoError(t, err)
+
+		column := table.GetColumn("unknown")
+		require.NotNil(t, column)
+		assert.Equal(t, PgTypeJSONB, column.Type)
+		assert.Equal(t, jsc.Object, column.JSType)
+		assert.Len(t, schema.Tables, 1)
+	})
+
 	t.Run("x-list-map-keys", func(t *testing.T) {
 		schema := NewSchema(logger, schemaName)
 		parser := NewParser(logger, schema)
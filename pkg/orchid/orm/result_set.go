@@ -8,6 +8,7 @@ import (
 type ResultSet struct {
 	schema *Schema
 	Data   MappedEntries
+	pks    map[string]map[interface{}]bool // primary-keys added per table hint
 }
 
 func (r *ResultSet) getTableData(tableName string) (*Table, []Entry, error) {
@@ -73,6 +74,11 @@ func (r *ResultSet) GetPK(tableName string, pk interface{}) (Entry, error) {
 	return entries[0], nil
 }
 
+// Len returns the amount of entries found for a given table name.
+func (r *ResultSet) Len(tableName string) int {
+	return len(r.Data[tableName])
+}
+
 func (r *ResultSet) GetColumn(tableName, columnName string) (List, error) {
 	_, data, err := r.getTableData(tableName)
 	if err != nil {
@@ -112,11 +118,10 @@ func (r *ResultSet) processRow(columnIDs map[string]int, row List) (EntryMap, er
 	return hintedEntries, nil
 }
 
-// buildMappedMatrix creates the MappedMatrix data structure.
-func (r *ResultSet) buildMappedMatrix(columnIDs map[string]int, matrix []List) error {
+// add appends rows of a results matrix to the result-set, where column names carry table hints.
+// Rows can be added in several steps, entries repeating primary-keys are skipped.
+func (r *ResultSet) add(columnIDs map[string]int, matrix []List) error {
 	columnIDsLen := len(columnIDs)
-	pkCache := make(map[string][]interface{}, len(r.schema.Tables))
-
 	for _, row := range matrix {
 		rowLen := len(row)
 		if columnIDsLen != rowLen {
@@ -135,28 +140,44 @@ func (r *ResultSet) buildMappedMatrix(columnIDs map[string]int, matrix []List) e
 			if !found {
 				continue
 			}
-			pk, found := entry[PKColumnName]
-			if !found {
-				continue
-			}
-
-			// in all cases, the primary-key should not repeat, therefore ingnoring the one
-			// repeating is a way to avoid duplicated results due one-to-many relationships
-			if InterfaceSliceContains(pkCache[table.Hint], pk) {
-				continue
-			}
-			pkCache[table.Hint] = append(pkCache[table.Hint], pk)
-
-			r.Data[table.Name] = append(r.Data[table.Name], entry)
+			r.addEntry(table, entry)
 		}
 	}
 	return nil
 }
 
+// addEntry appends the entry to table data, unless its primary-key has been added before.
+func (r *ResultSet) addEntry(table *Table, entry Entry) {
+	pk, found := entry[PKColumnName]
+	if !found {
+		return
+	}
+	// in all cases, the primary-key should not repeat, therefore ingnoring the one repeating is a
+	// way to avoid duplicated results due one-to-many relationships
+	if r.pks[table.Hint] == nil {
+		r.pks[table.Hint] = map[interface{}]bool{}
+	}
+	if r.pks[table.Hint][pk] {
+		return
+	}
+	r.pks[table.Hint][pk] = true
+	r.Data[table.Name] = append(r.Data[table.Name], entry)
+}
+
+// addNull appends an entry with null values for every column of table, standing for a related row
+// which is not found, as in a left-join.
+func (r *ResultSet) addNull(table *Table) {
+	entry := Entry{}
+	for _, column := range table.Columns {
+		entry[column.Name] = nil
+	}
+	r.addEntry(table, entry)
+}
+
 // NewResultSet instantiate an ResultSet.
 func NewResultSet(schema *Schema, columnIDs map[string]int, matrix []List) (*ResultSet, error) {
-	r := &ResultSet{schema: schema, Data: MappedEntries{}}
-	if err := r.buildMappedMatrix(columnIDs, matrix); err != nil {
+	r := &ResultSet{schema: schema, Data: MappedEntries{}, pks: map[string]map[interface{}]bool{}}
+	if err := r.add(columnIDs, matrix); err != nil {
 		return nil, err
 	}
 	return r, nil
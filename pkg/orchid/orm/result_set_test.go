@@ -54,4 +54,32 @@ func TestResultSet_New(t *testing.T) {
 	assert.Len(t, pk, 5)
 
 	assert.Equal(t, len(rs.Data), len(schema.Tables))
+
+	t.Run("add", func(t *testing.T) {
+		labelsTable, err := schema.GetTable("result_set_metadata_labels")
+		assert.NoError(t, err)
+		columnIDs := map[string]int{}
+		for i, column := range labelsTable.Columns {
+			columnIDs[fmt.Sprintf("%s.%s", labelsTable.Hint, column.Name)] = i
+		}
+		row := func(id int64) List {
+			list := make(List, len(labelsTable.Columns))
+			list[columnIDs[labelsTable.Hint+".id"]] = id
+			return list
+		}
+
+		rs, err := NewResultSet(schema, columnIDs, []List{row(1), row(2), row(1)})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, rs.Len(labelsTable.Name))
+		err = rs.add(columnIDs, []List{row(2), row(3)})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, rs.Len(labelsTable.Name))
+
+		rs.addNull(labelsTable)
+		rs.addNull(labelsTable)
+		assert.Equal(t, 4, rs.Len(labelsTable.Name))
+		entry, err := rs.GetPK(labelsTable.Name, nil)
+		assert.NoError(t, err)
+		assert.Len(t, entry, len(labelsTable.Columns))
+	})
 }
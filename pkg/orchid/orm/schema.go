@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -35,6 +36,16 @@ func (s *Schema) HasOneToMany(fieldPath []string) bool {
 	return table.OneToMany
 }
 
+// UnderOneToMany checks if the table matching fieldPath, or any table above it, is one-to-many.
+func (s *Schema) UnderOneToMany(fieldPath []string) bool {
+	for i := 1; i <= len(fieldPath); i++ {
+		if s.HasOneToMany(fieldPath[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsKV checks if a table matching fieldPath is key-value.
 func (s *Schema) IsKV(fieldPath []string) bool {
 	table := s.GetTableByPath(fieldPath)
@@ -131,6 +142,54 @@ func (s *Schema) GetTableByPath(fieldPath []string) *Table {
 	return nil
 }
 
+// FieldNotSelectableErr returned when a field path is not stored as a scalar column, reachable
+// without crossing one-to-many relationships.
+var FieldNotSelectableErr = errors.New("field is not selectable")
+
+// SelectableColumn returns the table and scalar column storing informed field path, as in
+// "spec.image". It returns FieldNotSelectableErr when the path is not found, points to arrays,
+// objects or relationships, or is stored under one-to-many tables.
+func (s *Schema) SelectableColumn(fieldPath []string) (*Table, *Column, error) {
+	if len(fieldPath) == 0 {
+		return nil, nil, FieldNotSelectableErr
+	}
+	tablePath := fieldPath[:len(fieldPath)-1]
+	if s.UnderOneToMany(tablePath) {
+		return nil, nil, fmt.Errorf("%w: '%s' is stored under a list",
+			FieldNotSelectableErr, strings.Join(fieldPath, "."))
+	}
+	table := s.GetTableByPath(tablePath)
+	if table == nil {
+		return nil, nil, fmt.Errorf("%w: '%s'", FieldNotSelectableErr, strings.Join(fieldPath, "."))
+	}
+	columnName := fieldPath[len(fieldPath)-1]
+	column := table.GetColumn(columnName)
+	if column == nil || table.IsPrimaryKey(columnName) || table.IsForeignKey(columnName) {
+		return nil, nil, fmt.Errorf("%w: '%s'", FieldNotSelectableErr, strings.Join(fieldPath, "."))
+	}
+	switch column.JSType {
+	case jsc.String, jsc.Integer, jsc.Number, jsc.Boolean:
+		return table, column, nil
+	}
+	return nil, nil, fmt.Errorf("%w: '%s' is not a scalar", FieldNotSelectableErr,
+		strings.Join(fieldPath, "."))
+}
+
+// TablesUnderPath returns the tables storing data found under informed field path, including the
+// table for the field path itself.
+func (s *Schema) TablesUnderPath(fieldPath []string) []*Table {
+	tables := []*Table{}
+	for _, table := range s.Tables {
+		if len(table.Path) < len(fieldPath) {
+			continue
+		}
+		if strings.Join(table.Path[:len(fieldPath)], ".") == strings.Join(fieldPath, ".") {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
 // OneToManyTables return a slice of table names that are having one-to-many relationship.
 func (s *Schema) OneToManyTables(tableName string) []string {
 	tables := []string{}
@@ -1,6 +1,8 @@
 package orm
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -68,6 +70,53 @@ func TestSchema_CR(t *testing.T) {
 		assert.Equal(t, reversedLen, len(schema.Tables))
 		assert.Equal(t, schema.Tables[0].Name, reversed[reversedLen-1].Name)
 	})
+
+	t.Run("TablesUnderPath", func(t *testing.T) {
+		tables := schema.TablesUnderPath([]string{"spec"})
+		require.NotEmpty(t, tables)
+		for _, table := range tables {
+			assert.True(t, strings.HasPrefix(table.Name, "cr_spec"))
+		}
+		specTable, err := schema.GetTable("cr_spec")
+		require.NoError(t, err)
+		assert.Contains(t, tables, specTable)
+
+		assert.Empty(t, schema.TablesUnderPath([]string{"status"}))
+	})
+
+	t.Run("UnderOneToMany", func(t *testing.T) {
+		assert.False(t, schema.UnderOneToMany(nil))
+		assert.False(t, schema.UnderOneToMany([]string{"metadata"}))
+		assert.True(t, schema.UnderOneToMany([]string{"metadata", "labels"}))
+		assert.True(t, schema.UnderOneToMany([]string{"metadata", "ownerReferences", "unknown"}))
+	})
+
+	t.Run("SelectableColumn", func(t *testing.T) {
+		for _, fieldPath := range []string{
+			"metadata.name",
+			"metadata.namespace",
+			"spec.simple",
+			"spec.complex.simple_nested",
+		} {
+			table, column, err := schema.SelectableColumn(strings.Split(fieldPath, "."))
+			require.NoError(t, err, fieldPath)
+			assert.Equal(t, strings.Split(fieldPath, ".")[:strings.Count(fieldPath, ".")], table.Path)
+			assert.True(t, strings.HasSuffix(fieldPath, column.Name))
+		}
+
+		for _, fieldPath := range []string{
+			"spec",
+			"spec.array",
+			"spec.complex",
+			"spec.unknown",
+			"metadata.labels",
+			"metadata.labels.app",
+			"metadata.ownerReferences.name",
+		} {
+			_, _, err := schema.SelectableColumn(strings.Split(fieldPath, "."))
+			assert.True(t, errors.Is(err, FieldNotSelectableErr), fieldPath)
+		}
+	})
 }
 
 func TestSchema_ObjectMeta(t *testing.T) {
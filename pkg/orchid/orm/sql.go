@@ -1,15 +1,21 @@
 package orm
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/lib/pq"
 	sqlfmt "github.com/otaviof/go-sqlfmt/pkg/sqlfmt"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 )
 
-// CreateDatabaseStatement returns create database statement with informed database.
+// CreateDatabaseStatement returns create database statement with informed database, quoted as an
+// identifier.
 func CreateDatabaseStatement(database string) string {
-	return fmt.Sprintf("create database %s template 'template1'", database)
+	return fmt.Sprintf("create database %s template 'template1'", pq.QuoteIdentifier(database))
 }
 
 // SelectDatabaseStatement returns select statement to check if database exists.
@@ -17,11 +23,172 @@ func SelectDatabaseStatement() string {
 	return "select 1 from pg_database where datname = $1"
 }
 
+// DatabaseComment comment marking databases in use by orchid, telling them apart from unrelated
+// databases in the same instance.
+const DatabaseComment = "orchid"
+
+// CommentDatabaseStatement returns the statement marking the database, quoted as an identifier, as
+// in use by orchid.
+func CommentDatabaseStatement(database string) string {
+	return fmt.Sprintf(
+		"comment on database %s is '%s'", pq.QuoteIdentifier(database), DatabaseComment)
+}
+
+// SelectDatabasesStatement returns select statement to list the databases marked by orchid,
+// ignoring templates and any other database in the instance.
+func SelectDatabasesStatement() string {
+	return fmt.Sprintf(
+		"select datname from pg_database where datistemplate = false and "+
+			"shobj_description(oid, 'pg_database') = '%s'", DatabaseComment)
+}
+
 // CreateSchemaStatement returns create schema statement, with informed search-path.
 func CreateSchemaStatement(searchPath string) string {
 	return fmt.Sprintf("create schema if not exists %s", searchPath)
 }
 
+// SetSearchPathStatement returns the statement to change the search-path until the end of the
+// current transaction.
+func SetSearchPathStatement(searchPath string) string {
+	return fmt.Sprintf("set local search_path = '%s'", searchPath)
+}
+
+// PrepareTransactionStatement returns the statement to prepare the current transaction for
+// two-phase commit, using informed global identifier.
+func PrepareTransactionStatement(gid string) string {
+	return fmt.Sprintf("prepare transaction '%s'", gid)
+}
+
+// SelectMaxPreparedTransactionsStatement returns select statement for the maximum amount of
+// prepared transactions, zero when two-phase commit is disabled.
+func SelectMaxPreparedTransactionsStatement() string {
+	return "select current_setting('max_prepared_transactions')::integer"
+}
+
+// CommitPreparedStatement returns the statement to commit a prepared transaction.
+func CommitPreparedStatement(gid string) string {
+	return fmt.Sprintf("commit prepared '%s'", gid)
+}
+
+// RollbackPreparedStatement returns the statement to roll back a prepared transaction.
+func RollbackPreparedStatement(gid string) string {
+	return fmt.Sprintf("rollback prepared '%s'", gid)
+}
+
+// CreateSequenceStatement returns create sequence statement, with informed name.
+func CreateSequenceStatement(name string) string {
+	return fmt.Sprintf("create sequence if not exists %s", name)
+}
+
+// NextValStatement returns select statement to obtain the next value of informed sequence.
+func NextValStatement(name string) string {
+	return fmt.Sprintf("select nextval('%s')", name)
+}
+
+// LastValueStatement returns select statement to obtain the last value taken from informed
+// sequence, zero when no value was taken yet.
+func LastValueStatement(name string) string {
+	return fmt.Sprintf("select case when is_called then last_value else 0 end from %s", name)
+}
+
+// EventsTable table logging the change events of every schema in the search-path, keyed by their
+// resource-version and schema, telling whether objects changed since a given resource-version.
+const EventsTable = "orchid_events"
+
+// CreateEventsTableStatement returns create table statement for the events log, in the current
+// search-path.
+func CreateEventsTableStatement() string {
+	return fmt.Sprintf("create table if not exists %s (resource_version bigint not null, "+
+		"schema_name text not null, type text not null, namespace text not null, "+
+		"name text not null, primary key (resource_version, schema_name))", EventsTable)
+}
+
+// InsertEventStatement returns insert statement for a single event in the events log.
+func InsertEventStatement() string {
+	return fmt.Sprintf("insert into %s (resource_version, schema_name, type, namespace, name) "+
+		"values ($1, $2, $3, $4, $5)", EventsTable)
+}
+
+// SelectChangedSinceStatement returns select statement checking whether the events log has events
+// of a schema after a given resource-version.
+func SelectChangedSinceStatement() string {
+	return fmt.Sprintf("select exists (select 1 from %s where schema_name = $1 and "+
+		"resource_version > $2)", EventsTable)
+}
+
+// SelectResourceVersionStatement returns select statement to obtain the resource-version of a
+// single object, identified by namespace and name, locking its metadata row until the end of the
+// transaction.
+func SelectResourceVersionStatement(metadataTable *Table) string {
+	return fmt.Sprintf(
+		"select \"%s\" from %s where namespace = $1 and name = $2 for update",
+		ResourceVersionColumnName,
+		metadataTable.Name,
+	)
+}
+
+// SelectColumnsStatement returns select statement to list columns and their types, out of the
+// tables informed as array, in the informed schema (search-path). Dropped and system columns are
+// not listed.
+func SelectColumnsStatement() string {
+	return `select c.relname, a.attname, format_type(a.atttypid, a.atttypmod)
+		from pg_attribute a
+		join pg_class c on c.oid = a.attrelid
+		join pg_namespace n on n.oid = c.relnamespace
+		where n.nspname = $1 and c.relname = any($2) and a.attnum > 0 and not a.attisdropped`
+}
+
+// UpdateColumnStatement returns update statement to change a single column of a single row,
+// identified by primary-key.
+func UpdateColumnStatement(table *Table, columnName string) string {
+	return fmt.Sprintf("update %s set \"%s\" = $1 where %s = $2", table.Name, columnName, PKColumnName)
+}
+
+// AddColumnStatement returns alter table statement to add informed column.
+func AddColumnStatement(table *Table, column *Column) string {
+	return fmt.Sprintf("alter table %s add column %s", table.Name, column.String())
+}
+
+// AddConstraintStatement returns alter table statement to add informed constraint.
+func AddConstraintStatement(table *Table, constraint *Constraint) string {
+	return fmt.Sprintf("alter table %s add %s", table.Name, constraint.String())
+}
+
+// AlterColumnTypeStatement returns alter table statement to change column type, casting existing
+// data to the new type.
+func AlterColumnTypeStatement(table *Table, column *Column) string {
+	return fmt.Sprintf("alter table %s alter column \"%s\" type %s using \"%s\"::%s",
+		table.Name, column.Name, column.Type, column.Name, column.Type)
+}
+
+// AlterColumnNullStatement returns alter table statement to set or drop not-null on column,
+// according to the column's flag.
+func AlterColumnNullStatement(table *Table, column *Column) string {
+	action := "drop"
+	if column.NotNull {
+		action = "set"
+	}
+	return fmt.Sprintf("alter table %s alter column \"%s\" %s not null",
+		table.Name, column.Name, action)
+}
+
+// FillNullStatement returns update statement to replace null values of column with value, informed
+// as SQL literal.
+func FillNullStatement(table *Table, column *Column, value string) string {
+	return fmt.Sprintf("update %s set \"%s\" = %s where \"%s\" is null",
+		table.Name, column.Name, value, column.Name)
+}
+
+// DropColumnStatement returns alter table statement to drop informed column.
+func DropColumnStatement(table *Table, column *Column) string {
+	return fmt.Sprintf("alter table %s drop column if exists \"%s\"", table.Name, column.Name)
+}
+
+// DropTableStatement returns drop table statement for informed table.
+func DropTableStatement(table *Table) string {
+	return fmt.Sprintf("drop table if exists %s", table.Name)
+}
+
 // valuesPlaceholders creates dollar based notation for the amount specified.
 func valuesPlaceholders(amount int) []string {
 	placeholders := []string{}
@@ -53,6 +220,12 @@ func InsertStatement(schema *Schema) []string {
 	return inserts
 }
 
+// DeleteStatement returns the statement to delete rows from table, where the primary-key is in the
+// array informed as argument.
+func DeleteStatement(table *Table) string {
+	return fmt.Sprintf("delete from %s where %s = any($1)", table.Name, PKColumnName)
+}
+
 // hintedColumns returns a slice of column names using table hint. Does not include foreign-keys.
 func hintedColumns(table *Table) []string {
 	columnNames := []string{PKColumnName}
@@ -85,12 +258,15 @@ func leftJoin(schema *Schema, table *Table, constraint *Constraint, related *Tab
 	)
 }
 
-// SelectStatement generates a select statement based on schema, using the primary schema table
-// as from, and other tables as left-join entries. It can return error when tables are not found.
-func SelectStatement(schema *Schema, where []string) (string, error) {
+// fromStatement generates the "from" clause based on schema, using the primary schema table as
+// from, and the tables related to it by one-to-one relationships as left-join entries, followed by
+// the where clauses informed. Tables under one-to-many relationships are left out, so each object
+// is represented by a single row. It also returns the hinted columns of the tables joined. It can
+// return error when tables are not found.
+func fromStatement(schema *Schema, where []string) (string, []string, error) {
 	mainTable, err := schema.GetTable(schema.Name)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	// preparing statement "from" clause based on main schema table
 	from := []string{fmt.Sprintf("%s %s", mainTable.Name, mainTable.Hint)}
@@ -98,44 +274,167 @@ func SelectStatement(schema *Schema, where []string) (string, error) {
 	leftJoins := []string{}
 	columns := []string{}
 	for _, table := range schema.Tables {
+		if schema.UnderOneToMany(table.Path) {
+			continue
+		}
 		columns = append(columns, hintedColumns(table)...)
 
-		for _, constraint := range table.Constraints {
-			if constraint.Type != PgConstraintFK {
-				continue
-			}
-
+		for _, constraint := range table.ForeignKeys() {
 			related, err := schema.GetTable(constraint.RelatedTableName)
 			if err != nil {
-				return "", err
-			}
-
-			// in order to keep the correct sql statement sequence, when dealing with a table having
-			// one-to-many relationships, moving the statement to the end of left-joins group, while
-			// when not having one-to-many, it will be prepended
-			leftJoinStmt := leftJoin(schema, table, constraint, related)
-			if schema.HasOneToMany(table.Path) {
-				leftJoins = append(leftJoins, leftJoinStmt)
-			} else {
-				leftJoins = StringSlicePrepend(leftJoins, leftJoinStmt)
+				return "", nil, err
 			}
+			// related tables are listed before the ones referring to them, therefore prepending
+			// joins keeps them in order
+			leftJoins = StringSlicePrepend(leftJoins, leftJoin(schema, table, constraint, related))
 		}
 	}
 
-	statement := fmt.Sprintf(
-		"select %s from %s",
-		strings.Join(columns, ", "),
-		strings.Join(from, ", "),
-	)
+	statement := fmt.Sprintf("from %s", strings.Join(from, ", "))
 	if len(leftJoins) > 0 {
 		statement = fmt.Sprintf("%s %s", statement, strings.Join(leftJoins, " "))
 	}
 	if len(where) > 0 {
 		statement = fmt.Sprintf("%s where %s", statement, strings.Join(where, " and "))
 	}
+	return statement, columns, nil
+}
+
+// NameCollation collation used to order and compare object names, "C" compares bytes, as Go does,
+// regardless of the database default collation, keeping continue tokens consistent.
+const NameCollation = `collate "C"`
+
+// OrderByName generates the clause ordering objects by name, on metadata table.
+func OrderByName(metadataTable *Table) string {
+	return fmt.Sprintf("order by %s.name %s", metadataTable.Hint, NameCollation)
+}
+
+// NameAfterWhere generates the where clause for objects named after the placeholder on position,
+// on metadata table.
+func NameAfterWhere(metadataTable *Table, position int) string {
+	return fmt.Sprintf("%s.name %s>$%d", metadataTable.Hint, NameCollation, position)
+}
+
+// SelectStatement generates a select statement based on schema, using the primary schema table
+// as from, and the tables related to it by one-to-one relationships as left-join entries, ordered
+// by name when the schema has a metadata table. Tables under one-to-many relationships are
+// selected apart, with SelectRelatedStatement. It can return error when tables are not found.
+func SelectStatement(schema *Schema, where []string) (string, error) {
+	from, columns, err := fromStatement(schema, where)
+	if err != nil {
+		return "", err
+	}
+	statement := fmt.Sprintf("select %s %s", strings.Join(columns, ", "), from)
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return statement, nil
+	}
+	return fmt.Sprintf("%s %s", statement, OrderByName(metadataTable)), nil
+}
+
+// SelectRelatedStatement generates a select statement for a single table, where the informed
+// column is in the array of values informed as argument.
+func SelectRelatedStatement(table *Table, columnName string) string {
+	return fmt.Sprintf("select %s from %s %s where %s.\"%s\" = any($1)",
+		strings.Join(hintedColumns(table), ", "), table.Name, table.Hint, table.Hint, columnName)
+}
+
+// SelectPageStatement generates a select statement listing the primary-keys of the main table for
+// objects matching where clauses, ordered by name. When limitPosition is positive, the amount of
+// rows is limited by the placeholder on that position. It can return error when tables are not
+// found.
+func SelectPageStatement(schema *Schema, where []string, limitPosition int) (string, error) {
+	mainTable, err := schema.GetTable(schema.Name)
+	if err != nil {
+		return "", err
+	}
+	metadataTable, err := schema.GetTable(fmt.Sprintf("%s_metadata", schema.Name))
+	if err != nil {
+		return "", err
+	}
+	from, _, err := fromStatement(schema, where)
+	if err != nil {
+		return "", err
+	}
+	statement := fmt.Sprintf("select %s.%s, %s.name %s %s",
+		mainTable.Hint, PKColumnName, metadataTable.Hint, from, OrderByName(metadataTable))
+	if limitPosition > 0 {
+		statement = fmt.Sprintf("%s limit $%d", statement, limitPosition)
+	}
 	return statement, nil
 }
 
+// UnsupportedOperatorErr selector requirement operator can't be translated into SQL.
+var UnsupportedOperatorErr = errors.New("unsupported selector operator")
+
+// SelectorWhere translates the requirement into a correlated "exists" sub-query against the
+// key-value table, where fkColumn points to the primary-key of the related table, known by hint
+// in the outer select. Placeholders are numbered starting on position, and the arguments for
+// them are returned. It can return error on unsupported operators and invalid values.
+func SelectorWhere(
+	kvTable *Table,
+	fkColumn string,
+	relatedHint string,
+	requirement labels.Requirement,
+	position int,
+) (string, []interface{}, error) {
+	subQuery := fmt.Sprintf("select 1 from %s where %s.%s=%s.%s and %s.key=$%d",
+		kvTable.Name, kvTable.Name, fkColumn, relatedHint, PKColumnName, kvTable.Name, position)
+	arguments := []interface{}{requirement.Key()}
+	values := requirement.Values().List()
+
+	switch requirement.Operator() {
+	case selection.Exists:
+		return fmt.Sprintf("exists (%s)", subQuery), arguments, nil
+	case selection.DoesNotExist:
+		return fmt.Sprintf("not exists (%s)", subQuery), arguments, nil
+	case selection.Equals, selection.DoubleEquals, selection.In:
+		subQuery = fmt.Sprintf("%s and %s.value = any($%d)", subQuery, kvTable.Name, position+1)
+		return fmt.Sprintf("exists (%s)", subQuery), append(arguments, pq.Array(values)), nil
+	case selection.NotEquals, selection.NotIn:
+		// following kubernetes, objects without the key also match
+		subQuery = fmt.Sprintf("%s and %s.value = any($%d)", subQuery, kvTable.Name, position+1)
+		return fmt.Sprintf("not exists (%s)", subQuery), append(arguments, pq.Array(values)), nil
+	case selection.GreaterThan, selection.LessThan:
+		if len(values) != 1 {
+			return "", nil, fmt.Errorf("%w: '%s' takes a single value", UnsupportedOperatorErr,
+				requirement.Operator())
+		}
+		value, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s", UnsupportedOperatorErr, err)
+		}
+		operator := ">"
+		if requirement.Operator() == selection.LessThan {
+			operator = "<"
+		}
+		// values which are not integers never match, and are kept away from the cast
+		subQuery = fmt.Sprintf(
+			"%s and (case when %s.value ~ '^-?[0-9]+$' then %s.value::bigint end) %s $%d",
+			subQuery, kvTable.Name, kvTable.Name, operator, position+1)
+		return fmt.Sprintf("exists (%s)", subQuery), append(arguments, value), nil
+	}
+	return "", nil, fmt.Errorf("%w: '%s'", UnsupportedOperatorErr, requirement.Operator())
+}
+
+// FieldSelectorWhere returns the where clause comparing the column of table, known by its hint, to
+// the placeholder on position. It can return error on unsupported operators.
+func FieldSelectorWhere(
+	table *Table,
+	column *Column,
+	operator selection.Operator,
+	position int,
+) (string, error) {
+	switch operator {
+	case selection.Equals, selection.DoubleEquals:
+		return fmt.Sprintf("%s.\"%s\"=$%d", table.Hint, column.Name, position), nil
+	case selection.NotEquals:
+		// null columns, as in fields not informed, differ from any value
+		return fmt.Sprintf("%s.\"%s\" is distinct from $%d", table.Hint, column.Name, position), nil
+	}
+	return "", fmt.Errorf("%w: '%s'", UnsupportedOperatorErr, operator)
+}
+
 func FormatStatement(statement string) string {
 	opts := &sqlfmt.Options{Distance: 0}
 	formatted, _ := sqlfmt.Format(statement, opts)
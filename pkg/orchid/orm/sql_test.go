@@ -1,10 +1,13 @@
 package orm
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/klog/klogr"
 
 	"github.com/isutton/orchid/test/mocks"
@@ -45,10 +48,148 @@ func TestSQL(t *testing.T) {
 		}
 	})
 
+	t.Run("Delete", func(t *testing.T) {
+		for _, table := range schema.TablesReversed() {
+			statement := DeleteStatement(table)
+			t.Logf("delete='%s'", statement)
+			assert.Contains(t, statement, "delete from "+table.Name)
+		}
+	})
+
+	t.Run("Databases", func(t *testing.T) {
+		assert.Equal(t, `comment on database "ns" is 'orchid'`, CommentDatabaseStatement("ns"))
+		assert.Equal(t, `create database "ns; drop database orchid" template 'template1'`,
+			CreateDatabaseStatement("ns; drop database orchid"))
+		statement := SelectDatabasesStatement()
+		assert.Contains(t, statement, "shobj_description(oid, 'pg_database') = 'orchid'")
+	})
+
+	t.Run("ResourceVersion", func(t *testing.T) {
+		statement := NextValStatement("public.resource_version")
+		assert.Equal(t, "select nextval('public.resource_version')", statement)
+		statement = LastValueStatement("public.resource_version")
+		assert.Equal(t,
+			"select case when is_called then last_value else 0 end from public.resource_version",
+			statement)
+
+		metadataTable, err := schema.GetTable("cr_metadata")
+		assert.NoError(t, err)
+		statement = SelectResourceVersionStatement(metadataTable)
+		t.Logf("select='%s'", statement)
+		assert.Contains(t, statement, "from cr_metadata")
+		assert.Contains(t, statement, "for update")
+
+		statement = UpdateColumnStatement(metadataTable, ResourceVersionColumnName)
+		assert.Equal(t,
+			"update cr_metadata set \"resourceVersion\" = $1 where id = $2", statement)
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		statement := CreateEventsTableStatement()
+		assert.Contains(t, statement, "create table if not exists orchid_events")
+		assert.Contains(t, statement, "primary key (resource_version, schema_name)")
+		assert.Contains(t, InsertEventStatement(), "insert into orchid_events")
+		assert.Equal(t,
+			"select exists (select 1 from orchid_events where schema_name = $1 and "+
+				"resource_version > $2)",
+			SelectChangedSinceStatement())
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		assert.Equal(t, "set local search_path = 'public'", SetSearchPathStatement("public"))
+		assert.Equal(t, "prepare transaction 'gid'", PrepareTransactionStatement("gid"))
+		assert.Equal(t, "commit prepared 'gid'", CommitPreparedStatement("gid"))
+		assert.Equal(t, "rollback prepared 'gid'", RollbackPreparedStatement("gid"))
+		assert.Contains(t, SelectMaxPreparedTransactionsStatement(), "max_prepared_transactions")
+	})
+
+	t.Run("Selector", func(t *testing.T) {
+		labelsTable, err := schema.GetTable("cr_metadata_labels")
+		assert.NoError(t, err)
+		selector, err := labels.Parse("a=1,b!=2,c in (3,4),d notin (5),e,!f,g>6")
+		assert.NoError(t, err)
+		requirements, _ := selector.Requirements()
+
+		position := 1
+		for _, requirement := range requirements {
+			clause, arguments, err := SelectorWhere(
+				labelsTable, "cr_metadata", "cm", requirement, position)
+			assert.NoError(t, err)
+			t.Logf("where='%s'", clause)
+			assert.Contains(t, clause, "from cr_metadata_labels where")
+			assert.Contains(t, clause, "cr_metadata_labels.cr_metadata=cm.id")
+			assert.Contains(t, clause, fmt.Sprintf("cr_metadata_labels.key=$%d", position))
+			assert.Equal(t, requirement.Key(), arguments[0])
+
+			switch requirement.Key() {
+			case "a", "c", "e", "g":
+				assert.True(t, strings.HasPrefix(clause, "exists ("))
+			default:
+				assert.True(t, strings.HasPrefix(clause, "not exists ("))
+			}
+			if len(arguments) > 1 {
+				assert.Contains(t, clause, fmt.Sprintf("$%d", position+1))
+			}
+			position += len(arguments)
+		}
+		assert.Equal(t, 13, position)
+	})
+
+	t.Run("FieldSelector", func(t *testing.T) {
+		table, column, err := schema.SelectableColumn([]string{"spec", "simple"})
+		assert.NoError(t, err)
+
+		clause, err := FieldSelectorWhere(table, column, selection.Equals, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, table.Hint+".\"simple\"=$3", clause)
+
+		clause, err = FieldSelectorWhere(table, column, selection.NotEquals, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, table.Hint+".\"simple\" is distinct from $1", clause)
+
+		_, err = FieldSelectorWhere(table, column, selection.In, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("SelectPage", func(t *testing.T) {
+		metadataTable, err := schema.GetTable("cr_metadata")
+		assert.NoError(t, err)
+		where := []string{NameAfterWhere(metadataTable, 1)}
+		assert.Equal(t, `cm.name collate "C">$1`, where[0])
+
+		statement, err := SelectPageStatement(schema, where, 2)
+		t.Logf("select='%s'", statement)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(statement, "select c.id, cm.name from cr c"))
+		assert.True(t, strings.HasSuffix(
+			statement, `where cm.name collate "C">$1 order by cm.name collate "C" limit $2`))
+
+		statement, err = SelectPageStatement(schema, nil, 0)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(statement, `order by cm.name collate "C"`))
+	})
+
 	t.Run("Select", func(t *testing.T) {
 		selectStmt, err := SelectStatement(schema, nil)
 		t.Logf("select='%s'", selectStmt)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, selectStmt)
+		assert.Contains(t, selectStmt, "left join cr_metadata ")
+		// tables under one-to-many relationships are selected apart
+		assert.NotContains(t, selectStmt, "cr_metadata_labels")
+		assert.NotContains(t, selectStmt, "cr_metadata_ownerreferences")
+		// objects are ordered by name in byte order, as continue tokens compare them
+		assert.True(t, strings.HasSuffix(selectStmt, `order by cm.name collate "C"`))
+	})
+
+	t.Run("SelectRelated", func(t *testing.T) {
+		labelsTable, err := schema.GetTable("cr_metadata_labels")
+		assert.NoError(t, err)
+		statement := SelectRelatedStatement(labelsTable, "cr_metadata")
+		t.Logf("select='%s'", statement)
+		assert.True(t, strings.HasPrefix(statement, "select "+labelsTable.Hint+".\"id\""))
+		assert.True(t, strings.HasSuffix(statement, fmt.Sprintf(
+			"from cr_metadata_labels %s where %s.\"cr_metadata\" = any($1)",
+			labelsTable.Hint, labelsTable.Hint)))
 	})
 }
@@ -2,6 +2,7 @@ package orm
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
@@ -146,6 +147,30 @@ func (t *Table) ForeignKeyTable(columnName string) string {
 	return ""
 }
 
+// Diff compares the table columns with existing columns, informed as column name and stored type,
+// returning a description of each difference found.
+func (t *Table) Diff(existing map[string]string) []string {
+	differences := []string{}
+	for _, column := range t.Columns {
+		storedType, found := existing[column.Name]
+		if !found {
+			differences = append(differences, fmt.Sprintf("column '%s' is missing", column.Name))
+			continue
+		}
+		if storedType != column.StoredType() {
+			differences = append(differences, fmt.Sprintf(
+				"column '%s' has type '%s' instead of '%s'", column.Name, storedType, column.StoredType()))
+		}
+	}
+	for name := range existing {
+		if t.GetColumn(name) == nil {
+			differences = append(differences, fmt.Sprintf("column '%s' is unexpected", name))
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
+
 // String returns the respective create table statement.
 func (t *Table) String() string {
 	columns := []string{}
@@ -69,6 +69,23 @@ func TestTable_New(t *testing.T) {
 		assert.Equal(t, "onTable", table.ForeignKeyTable("column"))
 	})
 
+	t.Run("Diff", func(t *testing.T) {
+		table := NewTable("test")
+		table.AddSerialPK()
+		table.AddColumn(&Column{Name: "name", Type: PgTypeText})
+		table.AddColumn(&Column{Name: "tags", Type: "text[3]"})
+
+		existing := map[string]string{"id": PgTypeBigInt, "name": PgTypeText, "tags": "text[]"}
+		assert.Empty(t, table.Diff(existing))
+
+		existing = map[string]string{"id": PgTypeBigInt, "name": PgTypeInt, "other": PgTypeText}
+		assert.Equal(t, []string{
+			"column 'name' has type 'integer' instead of 'text'",
+			"column 'other' is unexpected",
+			"column 'tags' is missing",
+		}, table.Diff(existing))
+	})
+
 	t.Run("String", func(t *testing.T) {
 		table := NewTable("test")
 		createTable := table.String()
@@ -0,0 +1,313 @@
+package orm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// pendingEvent event waiting for the transaction to be committed, and the ORM to publish it.
+type pendingEvent struct {
+	orm   *ORM   // ORM instance publishing the event
+	event *Event // change event
+}
+
+// Transaction spans writes on several ORM instances, possibly on different databases, committing or
+// rolling them back together. A single database transaction is kept per database, and the
+// search-path is switched before each operation. When more than one database is involved,
+// PostgreSQL two-phase commit is employed, which requires "max_prepared_transactions" enabled.
+// Resource-versions are taken from the clock, within the transaction when on the clock database.
+type Transaction struct {
+	logger    logr.Logger        // logger instance
+	clock     *Clock             // clock assigning resource-versions
+	id        string             // transaction identifier, base of two-phase commit identifiers
+	databases []string           // databases involved, in order of appearance
+	orms      map[string]*ORM    // ORM instance which started the transaction, per database
+	txns      map[string]*sql.Tx // database transaction per database
+	events    []pendingEvent     // events published on commit
+}
+
+// txn returns the database transaction for the ORM's database, starting a new one when needed, with
+// search-path set to the ORM's. It can return errors on starting the transaction and setting the
+// search-path.
+func (t *Transaction) txn(o *ORM) (*sql.Tx, error) {
+	txn, found := t.txns[o.database]
+	if !found {
+		var err error
+		if txn, err = o.DB.Begin(); err != nil {
+			return nil, err
+		}
+		t.databases = append(t.databases, o.database)
+		t.orms[o.database] = o
+		t.txns[o.database] = txn
+	}
+	if _, err := txn.Exec(SetSearchPathStatement(o.searchPath)); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// ResourceVersion takes the next resource-version from the clock, for a write on the ORM's
+// database as part of the transaction. It can return errors on starting the transaction and
+// querying the clock.
+func (t *Transaction) ResourceVersion(o *ORM) (string, error) {
+	txn, err := t.txn(o)
+	if err != nil {
+		return "", err
+	}
+	return t.clock.next(o.database, txn)
+}
+
+// enqueue a change event to be published on commit, recording it in the events log as part of the
+// transaction. It can return error on recording the event.
+func (t *Transaction) enqueue(
+	txn *sql.Tx,
+	o *ORM,
+	eventType watch.EventType,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	resourceVersion string,
+) error {
+	event := newEvent(eventType, schema, namespacedName, resourceVersion)
+	if err := o.logEvent(txn, event); err != nil {
+		return err
+	}
+	t.events = append(t.events, pendingEvent{orm: o, event: event})
+	return nil
+}
+
+// Create stores a given object, identified by namespaced-name, as part of the transaction. The
+// resource-version is the one stored in the matrix, taken with ResourceVersion.
+func (t *Transaction) Create(
+	o *ORM,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	resourceVersion string,
+	matrix MappedMatrix,
+) error {
+	txn, err := t.txn(o)
+	if err != nil {
+		return err
+	}
+	if err = o.insert(txn, schema, matrix); err != nil {
+		return err
+	}
+	return t.enqueue(txn, o, watch.Added, schema, namespacedName, resourceVersion)
+}
+
+// Update replaces a given object, identified by namespaced-name, as part of the transaction. When
+// expected resource-version is informed it must match the stored one, otherwise
+// ResourceVersionConflictErr is returned. It returns sql.ErrNoRows when the object is not found.
+func (t *Transaction) Update(
+	o *ORM,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	expected string,
+	resourceVersion string,
+	matrix MappedMatrix,
+) error {
+	txn, err := t.txn(o)
+	if err != nil {
+		return err
+	}
+	if err = o.update(txn, schema, namespacedName, expected, matrix); err != nil {
+		return err
+	}
+	return t.enqueue(txn, o, watch.Modified, schema, namespacedName, resourceVersion)
+}
+
+// UpdateStatus replaces the status of a given object, identified by namespaced-name, as part of
+// the transaction, as described by ORM's updateStatus. When expected resource-version is informed
+// it must match the stored one, otherwise ResourceVersionConflictErr is returned. It returns
+// sql.ErrNoRows when the object is not found.
+func (t *Transaction) UpdateStatus(
+	o *ORM,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	expected string,
+	resourceVersion string,
+	matrix MappedMatrix,
+) error {
+	txn, err := t.txn(o)
+	if err != nil {
+		return err
+	}
+	err = o.updateStatus(txn, schema, namespacedName, expected, resourceVersion, matrix)
+	if err != nil {
+		return err
+	}
+	return t.enqueue(txn, o, watch.Modified, schema, namespacedName, resourceVersion)
+}
+
+// Delete removes a given object, identified by namespaced-name, as part of the transaction,
+// publishing its removal under the resource-version informed. The removed rows are returned as a
+// result-set. It returns sql.ErrNoRows when the object is not found.
+func (t *Transaction) Delete(
+	o *ORM,
+	schema *Schema,
+	namespacedName types.NamespacedName,
+	resourceVersion string,
+) (*ResultSet, error) {
+	txn, err := t.txn(o)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := o.deleteRows(txn, schema, namespacedName)
+	if err != nil {
+		return nil, err
+	}
+	err = t.enqueue(txn, o, watch.Deleted, schema, namespacedName, resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Migrate executes the migration statements on the ORM's database and search-path, as part of the
+// transaction.
+func (t *Transaction) Migrate(o *ORM, migration *Migration) error {
+	txn, err := t.txn(o)
+	if err != nil {
+		return err
+	}
+	for _, statement := range migration.Statements {
+		t.logger.WithValues("database", o.database, "statement", statement).
+			Info("Executing migration statement.")
+		if _, err = txn.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gid returns the two-phase commit global identifier for database, unique in the instance.
+func (t *Transaction) gid(database string) string {
+	return fmt.Sprintf("%s_%s", t.id, database)
+}
+
+// commitSingle commits the transaction on a single database, publishing events within it.
+func (t *Transaction) commitSingle(database string) error {
+	txn := t.txns[database]
+	for _, pending := range t.events {
+		if err := pending.orm.publish(txn, pending.event); err != nil {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				t.logger.Error(rollbackErr, "Error on rolling back transaction.")
+			}
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+// TwoPhaseCommitDisabledErr returned when a transaction spans several databases, and PostgreSQL
+// does not allow prepared transactions.
+var TwoPhaseCommitDisabledErr = errors.New(
+	"two-phase commit is disabled, PostgreSQL's max_prepared_transactions must be above zero")
+
+// checkTwoPhase checks whether PostgreSQL allows prepared transactions. It returns
+// TwoPhaseCommitDisabledErr when "max_prepared_transactions" is zero, and errors on querying.
+func (t *Transaction) checkTwoPhase() error {
+	var maxPrepared int
+	err := t.txns[t.databases[0]].QueryRow(SelectMaxPreparedTransactionsStatement()).
+		Scan(&maxPrepared)
+	if err != nil {
+		return err
+	}
+	if maxPrepared == 0 {
+		return TwoPhaseCommitDisabledErr
+	}
+	return nil
+}
+
+// commitTwoPhase prepares transactions on all databases, committing them only when all are
+// prepared. Prepared transactions can't notify, therefore events are published after commit. It
+// returns TwoPhaseCommitDisabledErr, rolling back, when PostgreSQL does not allow preparing.
+func (t *Transaction) commitTwoPhase() error {
+	if err := t.checkTwoPhase(); err != nil {
+		if rollbackErr := t.Rollback(); rollbackErr != nil {
+			t.logger.Error(rollbackErr, "Error on rolling back transaction.")
+		}
+		return err
+	}
+	for i, database := range t.databases {
+		txn := t.txns[database]
+		_, err := txn.Exec(PrepareTransactionStatement(t.gid(database)))
+		// once prepared, the session is no longer in a transaction, and the driver refuses to commit
+		// or roll it back; finishing it only releases the connection, therefore errors are ignored
+		_ = txn.Rollback()
+		if err == nil {
+			continue
+		}
+
+		t.logger.WithValues("database", database).Error(err, "Error on preparing transaction.")
+		// rolling back transactions already prepared, and the ones not prepared yet
+		for _, prepared := range t.databases[:i] {
+			_, rollbackErr := t.orms[prepared].DB.Exec(RollbackPreparedStatement(t.gid(prepared)))
+			if rollbackErr != nil {
+				t.logger.Error(rollbackErr, "Error on rolling back prepared transaction.")
+			}
+		}
+		for _, pending := range t.databases[i+1:] {
+			if rollbackErr := t.txns[pending].Rollback(); rollbackErr != nil {
+				t.logger.Error(rollbackErr, "Error on rolling back transaction.")
+			}
+		}
+		return err
+	}
+
+	for _, database := range t.databases {
+		_, err := t.orms[database].DB.Exec(CommitPreparedStatement(t.gid(database)))
+		if err != nil {
+			t.logger.WithValues("database", database, "gid", t.gid(database)).
+				Error(err, "Error on committing prepared transaction, manual intervention required!")
+			return err
+		}
+	}
+	for _, pending := range t.events {
+		if err := pending.orm.publish(pending.orm.DB, pending.event); err != nil {
+			t.logger.Error(err, "Error on publishing event after commit.")
+		}
+	}
+	return nil
+}
+
+// Commit commits all operations, using two-phase commit when more than one database is involved,
+// and then publishes the change events. It can return errors on preparing and committing, and
+// TwoPhaseCommitDisabledErr when two-phase commit is needed but not allowed.
+func (t *Transaction) Commit() error {
+	switch len(t.databases) {
+	case 0:
+		return nil
+	case 1:
+		return t.commitSingle(t.databases[0])
+	}
+	return t.commitTwoPhase()
+}
+
+// Rollback discards all operations. It returns the first error found on rolling back.
+func (t *Transaction) Rollback() error {
+	var firstErr error
+	for _, database := range t.databases {
+		if err := t.txns[database].Rollback(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// NewTransaction instantiate a Transaction, taking resource-versions from the clock informed.
+func NewTransaction(logger logr.Logger, clock *Clock) *Transaction {
+	id := fmt.Sprintf("orchid_%s", rand.String(16))
+	return &Transaction{
+		logger: logger.WithName("transaction").WithValues("id", id),
+		clock:  clock,
+		id:     id,
+		orms:   map[string]*ORM{},
+		txns:   map[string]*sql.Tx{},
+	}
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -62,9 +63,18 @@ func (a *Assembler) slice(
 		return nil, err
 	}
 
+	table, err := a.schema.GetTable(relatedTableName)
+	if err != nil {
+		return nil, err
+	}
+
 	strippedEntries := []interface{}{}
 	for _, relatedEntry := range relatedEntries {
-		strippedEntry := a.rs.Strip(relatedEntry, columns)
+		amendedEntry, err := a.amend(table, relatedEntry)
+		if err != nil {
+			return nil, err
+		}
+		strippedEntry := a.rs.Strip(amendedEntry, columns)
 		strippedEntries = append(strippedEntries, strippedEntry)
 	}
 	return strippedEntries, nil
@@ -126,6 +136,20 @@ func (a *Assembler) amend(
 			return nil, fmt.Errorf("column '%s' not found on entry '%#v'", column.Name, entry)
 		}
 
+		// objects kept as JSON are deserialized back, and omitted when nil
+		if column.Type == orm.PgTypeJSONB && column.JSType == jsc.Object {
+			byteSlice, ok := value.([]byte)
+			if !ok {
+				continue
+			}
+			var data interface{}
+			if err := json.Unmarshal(byteSlice, &data); err != nil {
+				return nil, err
+			}
+			amended[column.Name] = data
+			continue
+		}
+
 		// converting values back to their original json type, when nil returning an empty data
 		// structure on the same type
 		switch column.JSType {
@@ -204,6 +228,12 @@ func (a *Assembler) Build() ([]*unstructured.Unstructured, error) {
 	// to find schema named table, it must be lowered string
 	schemaName := strings.ToLower(a.schema.Name)
 
+	objects := []*unstructured.Unstructured{}
+	// when no entries are found for the primary table, there are no objects to assemble
+	if a.rs.Len(schemaName) == 0 {
+		return objects, nil
+	}
+
 	// getting the primary-keys for schema named table
 	pks, err := a.rs.GetColumn(schemaName, orm.PKColumnName)
 	if err != nil {
@@ -212,7 +242,6 @@ func (a *Assembler) Build() ([]*unstructured.Unstructured, error) {
 
 	a.logger.WithValues("entries", len(pks)).Info("Building objects based on ResultSet.")
 
-	objects := []*unstructured.Unstructured{}
 	for _, pk := range pks {
 		object, err := a.object(schemaName, pk)
 		if err != nil {
@@ -241,6 +270,19 @@ func (a *Assembler) Build() ([]*unstructured.Unstructured, error) {
 	return objects, nil
 }
 
+// objectsFromJSON create unstructured objects out of JSON documents, as assembled by the database.
+func objectsFromJSON(documents [][]byte) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	for _, document := range documents {
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(document); err != nil {
+			return nil, err
+		}
+		objects = append(objects, u)
+	}
+	return objects, nil
+}
+
 // NewAssembler instantiate Assembler.
 func NewAssembler(logger logr.Logger, schema *orm.Schema, rs *orm.ResultSet) *Assembler {
 	return &Assembler{
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/isutton/orchid/pkg/orchid/conversion"
+)
+
+// storedKind describes how objects of a CRD kind are stored, the version they are stored as, and
+// how to convert them from and to the versions served.
+type storedKind struct {
+	version    string               // storage version
+	converter  conversion.Converter // converter between versions
+	namespaced bool                 // objects are namespace scoped
+}
+
+// newStoredKind extracts the storage version and the converter from a CRD object. It can return
+// errors on extracting CRD data and on instantiating the converter.
+func newStoredKind(obj map[string]interface{}) (schema.GroupKind, *storedKind, error) {
+	crd, err := ExtractCRD(obj)
+	if err != nil {
+		return schema.GroupKind{}, nil, err
+	}
+	gvk, err := ExtractCRGVKFromCRD(obj)
+	if err != nil {
+		return schema.GroupKind{}, nil, err
+	}
+	converter, err := conversion.NewConverter(crd)
+	if err != nil {
+		return schema.GroupKind{}, nil, err
+	}
+	return gvk.GroupKind(), &storedKind{
+		version:    gvk.Version,
+		converter:  converter,
+		namespaced: crd.Spec.Scope != extv1.ClusterScoped,
+	}, nil
+}
+
+// registerCRD keeps the storage version and converter of the kind described by a CRD object. It
+// can return errors on extracting CRD data and on instantiating the converter.
+func (r *Repository) registerCRD(obj map[string]interface{}) error {
+	groupKind, kind, err := newStoredKind(obj)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds[groupKind] = kind
+	return nil
+}
+
+// lookupKind returns the stored kind registered for the group-kind, and false when not registered.
+func (r *Repository) lookupKind(groupKind schema.GroupKind) (*storedKind, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kind, found := r.kinds[groupKind]
+	return kind, found
+}
+
+// storageGVK returns the GVK objects are stored as, which differs from the informed one when the
+// CRD declares another storage version.
+func (r *Repository) storageGVK(gvk schema.GroupVersionKind) schema.GroupVersionKind {
+	kind, found := r.lookupKind(gvk.GroupKind())
+	if !found {
+		return gvk
+	}
+	return gvk.GroupKind().WithVersion(kind.version)
+}
+
+// namespaced checks if objects of the GVK are namespace scoped. CRDs and kinds of cluster scoped
+// CRDs are not, while kinds not registered are assumed to be.
+func (r *Repository) namespaced(gvk schema.GroupVersionKind) bool {
+	if gvk.GroupKind() == CRDGVK.GroupKind() {
+		return false
+	}
+	kind, found := r.lookupKind(gvk.GroupKind())
+	return !found || kind.namespaced
+}
+
+// convert objects to the informed GVK, objects already in its version are not converted. It can
+// return errors from the converter.
+func (r *Repository) convert(
+	gvk schema.GroupVersionKind,
+	objects []*unstructured.Unstructured,
+) ([]*unstructured.Unstructured, error) {
+	apiVersion := gvk.GroupVersion().String()
+	pending := []*unstructured.Unstructured{}
+	for _, u := range objects {
+		if u.GetAPIVersion() != apiVersion {
+			pending = append(pending, u)
+		}
+	}
+	kind, found := r.lookupKind(gvk.GroupKind())
+	if len(pending) == 0 || !found {
+		return objects, nil
+	}
+
+	converted, err := kind.converter.Convert(pending, apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, u := range objects {
+		if u.GetAPIVersion() != apiVersion {
+			u, converted = converted[0], converted[1:]
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// convertOne converts a single object to the informed GVK.
+func (r *Repository) convertOne(
+	gvk schema.GroupVersionKind,
+	u *unstructured.Unstructured,
+) (*unstructured.Unstructured, error) {
+	converted, err := r.convert(gvk, []*unstructured.Unstructured{u})
+	if err != nil {
+		return nil, err
+	}
+	return converted[0], nil
+}
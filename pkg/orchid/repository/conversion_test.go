@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/isutton/orchid/test/mocks"
+)
+
+// multiVersionCRDMock returns the CRD mock serving "v1alpha1" and "v1", where "v1" is the storage
+// version.
+func multiVersionCRDMock(t *testing.T) *unstructured.Unstructured {
+	crd := mocks.CRDMock(DefaultNamespace, "complexes.tests.example.com")
+	alpha := *crd.Spec.Versions[0].DeepCopy()
+	alpha.Name = "v1alpha1"
+	alpha.Served = true
+	alpha.Storage = false
+	crd.Spec.Versions = append(crd.Spec.Versions, alpha)
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+	require.NoError(t, err)
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestRepository_conversion(t *testing.T) {
+	_, repo := buildTestRepository(t)
+	crd := multiVersionCRDMock(t)
+
+	cr, err := mocks.UnstructuredCRMock("ns", "name")
+	require.NoError(t, err)
+	storageGVK := cr.GroupVersionKind()
+	alphaGVK := storageGVK.GroupKind().WithVersion("v1alpha1")
+
+	t.Run("unregistered", func(t *testing.T) {
+		assert.Equal(t, alphaGVK, repo.storageGVK(alphaGVK))
+	})
+
+	require.NoError(t, repo.registerCRD(crd.Object))
+
+	t.Run("storageGVK", func(t *testing.T) {
+		assert.Equal(t, storageGVK, repo.storageGVK(alphaGVK))
+		assert.Equal(t, storageGVK, repo.storageGVK(storageGVK))
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		alpha := cr.DeepCopy()
+		alpha.SetGroupVersionKind(alphaGVK)
+
+		converted, err := repo.convert(storageGVK, []*unstructured.Unstructured{cr, alpha})
+		require.NoError(t, err)
+		require.Len(t, converted, 2)
+		assert.Same(t, cr, converted[0])
+		assert.Equal(t, storageGVK, converted[1].GroupVersionKind())
+		assert.Equal(t, alphaGVK, alpha.GroupVersionKind())
+
+		u, err := repo.convertOne(alphaGVK, cr)
+		require.NoError(t, err)
+		assert.Equal(t, alphaGVK, u.GroupVersionKind())
+		assert.Equal(t, cr.Object["spec"], u.Object["spec"])
+	})
+}
+
+func TestRepository_scope(t *testing.T) {
+	_, repo := buildTestRepository(t)
+	crd := multiVersionCRDMock(t)
+	cr, err := mocks.UnstructuredCRMock("ns", "name")
+	require.NoError(t, err)
+	gvk := cr.GroupVersionKind()
+	namespacedName := types.NamespacedName{Namespace: "ns", Name: "name"}
+
+	t.Run("unregistered", func(t *testing.T) {
+		assert.True(t, repo.namespaced(gvk))
+		assert.Equal(t, "ns", repo.namespaceForGVK(gvk, "ns"))
+		assert.Equal(t, namespacedName, repo.scopedName(gvk, namespacedName))
+	})
+
+	t.Run("crd", func(t *testing.T) {
+		assert.False(t, repo.namespaced(CRDGVK))
+		assert.Equal(t, DefaultNamespace, repo.namespaceForGVK(CRDGVK, "ns"))
+		assert.Equal(t, namespacedName, repo.scopedName(CRDGVK, namespacedName))
+		namespaces, err := repo.listNamespaces(metav1.NamespaceAll, CRDGVK)
+		require.NoError(t, err)
+		assert.Equal(t, []string{DefaultNamespace}, namespaces)
+	})
+
+	require.NoError(t, unstructured.SetNestedField(
+		crd.Object, string(extv1.ClusterScoped), "spec", "scope"))
+	require.NoError(t, repo.registerCRD(crd.Object))
+
+	t.Run("cluster scoped", func(t *testing.T) {
+		assert.False(t, repo.namespaced(gvk))
+		assert.Equal(t, DefaultNamespace, repo.namespaceForGVK(gvk, "ns"))
+		assert.Equal(t, types.NamespacedName{Name: "name"}, repo.scopedName(gvk, namespacedName))
+		for _, ns := range []string{metav1.NamespaceAll, "ns"} {
+			namespaces, err := repo.listNamespaces(ns, gvk)
+			require.NoError(t, err)
+			assert.Equal(t, []string{DefaultNamespace}, namespaces)
+		}
+	})
+}
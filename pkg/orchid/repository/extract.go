@@ -121,6 +121,22 @@ func extractPath(
 	return data, err
 }
 
+// extractJSON extract informed field path serialized as JSON, or nil when not found.
+func extractJSON(obj map[string]interface{}, fieldPath []string) (interface{}, error) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fieldPath...)
+	if err != nil {
+		return nil, err
+	}
+	if !found || value == nil {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
 // extractKV loops the object and build a sequence of key-value entries.
 func extractKV(obj map[string]interface{}) []orm.List {
 	data := make([]orm.List, 0, len(obj))
@@ -146,7 +162,21 @@ func extractColumns(
 
 		var data interface{}
 		// extracting columns' data either as JSON or regular field-path approach
-		if column.Type == orm.PgTypeJSONB {
+		if column.Type == orm.PgTypeJSONB && column.JSType == jsc.Object {
+			var err error
+			if data, err = extractJSON(obj, columnFieldPath); err != nil {
+				return nil, err
+			}
+			if data == nil {
+				if column.NotNull {
+					return nil, fmt.Errorf(
+						"unable to find data for not-null column at '%+v'", columnFieldPath)
+				}
+				if data, err = column.Null(); err != nil {
+					return nil, err
+				}
+			}
+		} else if column.Type == orm.PgTypeJSONB {
 			bytes, err := json.Marshal(obj)
 			if err != nil {
 				return nil, err
@@ -169,16 +199,38 @@ func extractColumns(
 	return dataColumns, nil
 }
 
-// ExtractCRDOpenAPIV3Schema extract known field path to store OpenAPI schema in a CRD unstructured
-// Object, and returns as an actual JSONSchemaProps.
-func ExtractCRDOpenAPIV3Schema(obj map[string]interface{}) (*extv1.JSONSchemaProps, error) {
+// storageVersion returns the CRD version entry marked as storage, or the first entry when none is
+// marked. It can return errors when versions are not found.
+func storageVersion(obj map[string]interface{}) (map[string]interface{}, error) {
 	versions, err := nestedSlice(obj, []string{"spec", "versions"})
 	if err != nil {
 		return nil, err
 	}
-	version, ok := versions[0].(map[string]interface{})
-	if !ok {
-		return nil, errors.New("")
+	if len(versions) == 0 {
+		return nil, errors.New("no versions found")
+	}
+	var first map[string]interface{}
+	for i, entry := range versions {
+		version, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unable to read version at index %d", i)
+		}
+		if i == 0 {
+			first = version
+		}
+		if storage, _, _ := unstructured.NestedBool(version, "storage"); storage {
+			return version, nil
+		}
+	}
+	return first, nil
+}
+
+// ExtractCRDOpenAPIV3Schema extract known field path to store OpenAPI schema in a CRD unstructured
+// Object, and returns as an actual JSONSchemaProps. The schema of the storage version is employed.
+func ExtractCRDOpenAPIV3Schema(obj map[string]interface{}) (*extv1.JSONSchemaProps, error) {
+	version, err := storageVersion(obj)
+	if err != nil {
+		return nil, err
 	}
 	data, err := nestedMap(version, []string{"schema", "openAPIV3Schema"})
 	if err != nil {
@@ -192,7 +244,16 @@ func ExtractCRDOpenAPIV3Schema(obj map[string]interface{}) (*extv1.JSONSchemaPro
 	return openAPIV3Schema, nil
 }
 
-// ExtractCRGVKFromCRD extract target CR GVK from a CRD object.
+// ExtractCRD converts a CRD object into its typed representation.
+func ExtractCRD(obj map[string]interface{}) (*extv1.CustomResourceDefinition, error) {
+	crd := &extv1.CustomResourceDefinition{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, crd); err != nil {
+		return nil, err
+	}
+	return crd, nil
+}
+
+// ExtractCRGVKFromCRD extract target CR GVK from a CRD object, using the storage version.
 func ExtractCRGVKFromCRD(obj map[string]interface{}) (schema.GroupVersionKind, error) {
 	gvk := schema.GroupVersionKind{}
 
@@ -206,19 +267,12 @@ func ExtractCRGVKFromCRD(obj map[string]interface{}) (schema.GroupVersionKind, e
 	} else {
 		gvk.Group = group.(string)
 	}
-	versions, err := nestedSlice(data, []string{"versions"})
+	version, err := storageVersion(obj)
 	if err != nil {
 		return gvk, err
 	}
-	if len(versions) == 0 {
-		return gvk, errors.New("no versions found")
-	}
-	version, ok := versions[0].(map[string]interface{})
-	if ok {
-		gvk.Version, err = nestedString(version, []string{"name"})
-		if err != nil {
-			return gvk, err
-		}
+	if gvk.Version, err = nestedString(version, []string{"name"}); err != nil {
+		return gvk, err
 	}
 	if kind, err := nestedString(obj, []string{"spec", "names", "kind"}); err != nil {
 		return gvk, err
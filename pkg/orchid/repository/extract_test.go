@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
 	"github.com/isutton/orchid/test/mocks"
@@ -42,3 +43,22 @@ func TestExtract_extractCRGVKFromCRD(t *testing.T) {
 	assert.Equal(t, "v1", gvk.Version)
 	assert.Equal(t, "Complex", gvk.Kind)
 }
+
+func TestExtract_storageVersion(t *testing.T) {
+	crd := multiVersionCRDMock(t)
+
+	gvk, err := ExtractCRGVKFromCRD(crd.Object)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", gvk.Version)
+
+	// moving storage to the second version entry
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	require.NoError(t, err)
+	versions[0].(map[string]interface{})["storage"] = false
+	versions[1].(map[string]interface{})["storage"] = true
+	require.NoError(t, unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+
+	gvk, err = ExtractCRGVKFromCRD(crd.Object)
+	require.NoError(t, err)
+	assert.Equal(t, "v1alpha1", gvk.Version)
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/isutton/orchid/pkg/orchid/orm"
+)
+
+// ForceMigrationAnnotation CRD annotation allowing schema changes which change existing data, as in
+// changing column types, or making columns not-null, when set to "true".
+const ForceMigrationAnnotation = "orchid.io/force-migration"
+
+// IncompatibleSchemaChangeErr returned when a CRD change can't be migrated, unless forced.
+var IncompatibleSchemaChangeErr = orm.IncompatibleSchemaChangeErr
+
+// migratedORMs returns the ORM instances, per namespace, where tables of the schema are in place.
+// Every namespace known by orchid is inspected, since tables may have been created before a restart
+// or by another instance sharing the database. It can return errors on listing namespaces, on
+// inspecting tables and on instantiating ORMs.
+func (r *Repository) migratedORMs(searchPath string, s *orm.Schema) (map[string]*orm.ORM, error) {
+	namespaces, err := r.namespaces()
+	if err != nil {
+		return nil, err
+	}
+	migrated := map[string]*orm.ORM{}
+	for _, ns := range namespaces {
+		exists, err := r.tablesExist(ns, searchPath, s)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if migrated[ns], err = r.ormFactory(ns, searchPath); err != nil {
+			return nil, err
+		}
+	}
+	return migrated, nil
+}
+
+// tablesExist checks if the main table of the schema exists in the namespace and search-path,
+// inspecting the database on a short lived connection unless tables are known to be in place. It
+// can return errors on connecting and querying.
+func (r *Repository) tablesExist(ns, searchPath string, s *orm.Schema) (bool, error) {
+	if r.tablesInPlace(tablesKey(ns, searchPath), s.Name) {
+		return true, nil
+	}
+	o := orm.NewORM(r.logger, ns, searchPath, r.config)
+	if err := o.Connect(); err != nil {
+		return false, err
+	}
+	defer o.DB.Close()
+	columns, err := o.Columns(s)
+	if err != nil {
+		return false, err
+	}
+	_, exists := columns[s.Name]
+	return exists, nil
+}
+
+// migrateTables alters the tables of the current schema in every namespace where they exist, to
+// match the desired schema, as part of the transaction. It can return IncompatibleSchemaChangeErr
+// when the migration is refused, and errors on executing it.
+func (r *Repository) migrateTables(
+	txn *orm.Transaction,
+	gvk schema.GroupVersionKind,
+	current *orm.Schema,
+	desired *orm.Schema,
+	force bool,
+) error {
+	migration, err := orm.NewMigration(current, desired, force)
+	if err != nil {
+		return err
+	}
+	if migration.Empty() {
+		return nil
+	}
+	migrated, err := r.migratedORMs(searchPathForGroup(gvk.Group), current)
+	if err != nil {
+		return err
+	}
+	for ns, o := range migrated {
+		r.logger.WithValues("namespace", ns, "schema", desired.Name).
+			Info("Migrating schema tables")
+		if err = txn.Migrate(o, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateStorage moves objects stored in the previous storage version to the desired schema, as
+// part of the transaction, converting them with the CRD's converter. It can return errors on
+// reading, converting and storing objects.
+func (r *Repository) migrateStorage(
+	txn *orm.Transaction,
+	crd *unstructured.Unstructured,
+	previousGVK schema.GroupVersionKind,
+	desired *orm.Schema,
+) error {
+	previous, found := r.lookupSchema(r.schemaNameforGVK(previousGVK))
+	if !found {
+		return nil
+	}
+	_, kind, err := newStoredKind(crd.Object)
+	if err != nil {
+		return err
+	}
+	apiVersion := previousGVK.GroupKind().WithVersion(kind.version).GroupVersion().String()
+	createTables := &orm.Migration{Statements: orm.CreateTablesStatement(desired)}
+
+	migrated, err := r.migratedORMs(searchPathForGroup(previousGVK.Group), previous)
+	if err != nil {
+		return err
+	}
+	for ns, o := range migrated {
+		rs, err := o.List(previous, nil)
+		if err != nil {
+			return err
+		}
+		objects, err := NewAssembler(r.logger, previous, rs).Build()
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		for _, u := range objects {
+			u.SetGroupVersionKind(previousGVK)
+		}
+		converted, err := kind.converter.Convert(objects, apiVersion)
+		if err != nil {
+			return err
+		}
+
+		r.logger.WithValues("namespace", ns, "from", previous.Name, "to", desired.Name).
+			Info("Migrating objects to the new storage version")
+		if err = txn.Migrate(o, createTables); err != nil {
+			return err
+		}
+		for _, u := range converted {
+			namespacedName := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
+			// objects moved are removed and added back under a single resource-version
+			resourceVersion, err := txn.ResourceVersion(o)
+			if err != nil {
+				return err
+			}
+			if _, err = txn.Delete(o, previous, namespacedName, resourceVersion); err != nil {
+				return err
+			}
+			u.SetResourceVersion(resourceVersion)
+			arguments, err := r.decompose(desired, u)
+			if err != nil {
+				return err
+			}
+			err = txn.Create(o, desired, namespacedName, resourceVersion, arguments)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrate generates the schema out of the CRD informed, and when it changes the schema in use,
+// migrates the tables of every namespace where they are in place, as part of the transaction. When
+// the storage version changes, stored objects are moved to the new storage version. The generated
+// schema is returned, and must only replace the one in use once the transaction is committed. It
+// can return errors on extracting CRD data, generating the schema, migrating objects, and
+// IncompatibleSchemaChangeErr when the migration is refused.
+func (r *Repository) migrate(
+	txn *orm.Transaction,
+	crd *unstructured.Unstructured,
+) (*orm.Schema, error) {
+	gvk, err := ExtractCRGVKFromCRD(crd.Object)
+	if err != nil {
+		return nil, err
+	}
+	openAPIV3Schema, err := ExtractCRDOpenAPIV3Schema(crd.Object)
+	if err != nil {
+		return nil, err
+	}
+	desired := orm.NewSchema(r.logger, r.schemaNameforGVK(gvk))
+	if err = desired.Generate(openAPIV3Schema); err != nil {
+		return nil, err
+	}
+
+	if current, found := r.lookupSchema(desired.Name); found {
+		force := crd.GetAnnotations()[ForceMigrationAnnotation] == "true"
+		if err = r.migrateTables(txn, gvk, current, desired, force); err != nil {
+			return nil, err
+		}
+	}
+	if kind, found := r.lookupKind(gvk.GroupKind()); found && kind.version != gvk.Version {
+		previousGVK := gvk.GroupKind().WithVersion(kind.version)
+		if err = r.migrateStorage(txn, crd, previousGVK, desired); err != nil {
+			return nil, err
+		}
+	}
+	return desired, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// InvalidContinueErr returned when the continue token informed can't be decoded.
+var InvalidContinueErr = errors.New("continue token is not valid")
+
+// continueToken position where the next page of a list starts, handed to clients as an opaque
+// string.
+type continueToken struct {
+	ResourceVersion int64  `json:"rv"`   // resource-version of the first page
+	Namespace       string `json:"ns"`   // namespace storing the last item listed
+	Name            string `json:"name"` // name of the last item listed
+}
+
+// encode the token as base64 encoded JSON.
+func (c *continueToken) encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeContinueToken decodes a token created by encode. It returns InvalidContinueErr when the
+// token can't be decoded, or does not carry the last item listed.
+func decodeContinueToken(encoded string) (*continueToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", InvalidContinueErr, err)
+	}
+	token := &continueToken{}
+	if err = json.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("%w: %s", InvalidContinueErr, err)
+	}
+	if token.Name == "" {
+		return nil, fmt.Errorf("%w: last item is not informed", InvalidContinueErr)
+	}
+	return token, nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagination_continueToken(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		token := &continueToken{ResourceVersion: 10, Namespace: "example", Name: "crontab"}
+		encoded, err := token.encode()
+		require.NoError(t, err)
+		require.NotContains(t, encoded, "crontab")
+
+		decoded, err := decodeContinueToken(encoded)
+		require.NoError(t, err)
+		require.Equal(t, token, decoded)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, encoded := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+			_, err := decodeContinueToken(encoded)
+			require.True(t, errors.Is(err, InvalidContinueErr), encoded)
+		}
+	})
+}
@@ -1,16 +1,25 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/go-logr/logr"
+	"github.com/lib/pq"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 
 	"github.com/isutton/orchid/pkg/orchid/config"
 	jsc "github.com/isutton/orchid/pkg/orchid/jsonschema"
@@ -20,20 +29,54 @@ import (
 // ResourceRepository is the repository interface
 type ResourceRepository interface {
 	Create(u *unstructured.Unstructured) error
+	Update(u *unstructured.Unstructured) error
+	UpdateStatus(u *unstructured.Unstructured) error
+	Delete(gvk schema.GroupVersionKind, namespacedName types.NamespacedName) (*unstructured.Unstructured, error)
 	Read(gvk schema.GroupVersionKind, namespacedName types.NamespacedName) (*unstructured.Unstructured, error)
 	List(ns string, gvk schema.GroupVersionKind, options metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	Watch(ctx context.Context, ns string, gvk schema.GroupVersionKind, options metav1.ListOptions) (<-chan watch.Event, error)
+	Transaction(operations []Operation) ([]*unstructured.Unstructured, error)
 }
 
 // Repository on which data is handled regarding ORM Schemas and data extrated from Unstructured,
-// being ready to store CRD data in a sightly different way than regular CRs.
+// being ready to store CRD data in a sightly different way than regular CRs. Its maps are shared by
+// request handlers and watches, thus only accessed holding the lock.
 type Repository struct {
-	logger          logr.Logger                    // logger instance
-	config          *config.Config                 // configuration instance
-	schemas         map[string]*orm.Schema         // schema name and instance
-	orms            map[string]map[string]*orm.ORM // namespace and instances by name
-	gvkPerNamespace map[string][]string            // cached list of where GVK has been applied
+	logger          logr.Logger                      // logger instance
+	config          *config.Config                   // configuration instance
+	mu              sync.RWMutex                     // guards the maps below
+	schemas         map[string]*orm.Schema           // schema name and instance
+	orms            map[string]map[string]*orm.ORM   // namespace and instances by name
+	gvkPerNamespace map[string][]string              // schemas with tables in place per namespace/group
+	kinds           map[schema.GroupKind]*storedKind // storage version and converter per CRD kind
+	clock           *orm.Clock                       // resource-versions clock, on first use
+	listener        *orm.Listener                    // database listener shared by watches
 }
 
+// ObjectNotFoundErr returned when the object informed is not present in the database.
+var ObjectNotFoundErr = errors.New("object not found")
+
+// ResourceVersionConflictErr returned when the object informed carries a resource-version which
+// does not match the stored one, meaning it has been modified in the meantime.
+var ResourceVersionConflictErr = errors.New(
+	"the object has been modified; please apply your changes to the latest version and try again")
+
+// ObjectAlreadyExistsErr returned when an object with the same namespaced-name, or unique fields,
+// is already stored.
+var ObjectAlreadyExistsErr = errors.New("object already exists")
+
+// ReferenceViolationErr returned when stored rows referencing each other are changed concurrently,
+// leaving references behind.
+var ReferenceViolationErr = errors.New("object references have been modified")
+
+// InvalidSelectorErr returned when list options carry a selector which can't be parsed, or
+// translated into a query.
+var InvalidSelectorErr = errors.New("invalid selector")
+
+// NamespaceNotFoundErr returned when watching a namespace which does not exist, since namespaces
+// are only created by writes.
+var NamespaceNotFoundErr = errors.New("namespace not found")
+
 // DefaultNamespace namespace name or orchid's metadata
 const DefaultNamespace = "orchid"
 
@@ -51,20 +94,75 @@ var NSGVK = schema.GroupVersionKind{
 	Kind:    "Namespace",
 }
 
-// ormFactory creates a single ORM bootstrapped instance per namespace GVK.Group combination.
-func (r *Repository) ormFactory(ns string, group string) *orm.ORM {
-	if _, exists := r.orms[ns]; !exists {
+// ormFactory creates a single ORM bootstrapped instance per namespace GVK.Group combination. The
+// instance is only shared once bootstrapped, so its database connection is established once. It can
+// return errors on bootstrapping.
+func (r *Repository) ormFactory(ns string, group string) (*orm.ORM, error) {
+	r.mu.RLock()
+	o, exists := r.orms[ns][group]
+	r.mu.RUnlock()
+	if exists {
+		return o, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if o, exists = r.orms[ns][group]; exists {
+		return o, nil
+	}
+	logger := r.logger.WithValues("database", ns, "group", group)
+	logger.Info("Instantiating ORM...")
+	o = orm.NewORM(r.logger, ns, group, r.config)
+	logger.Info("Bootstrapping database connection...")
+	if err := o.Bootstrap(); err != nil {
+		return nil, err
+	}
+	if _, exists = r.orms[ns]; !exists {
 		r.orms[ns] = map[string]*orm.ORM{}
 	}
-	if _, exists := r.orms[ns][group]; !exists {
-		r.logger.WithValues("database", ns, "group", group).Info("Instantiating ORM...")
-		r.orms[ns][group] = orm.NewORM(r.logger, ns, group, r.config)
+	r.orms[ns][group] = o
+	return o, nil
+}
+
+// clockFactory creates the single clock instance, assigning resource-versions to objects of every
+// namespace out of the default namespace database. It can return errors on instantiating the ORM
+// and the clock.
+func (r *Repository) clockFactory() (*orm.Clock, error) {
+	r.mu.RLock()
+	clock := r.clock
+	r.mu.RUnlock()
+	if clock != nil {
+		return clock, nil
 	}
-	return r.orms[ns][group]
+
+	o, err := r.ormFactory(DefaultNamespace, searchPathForGroup(CRDGVK.Group))
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.clock == nil {
+		if r.clock, err = orm.NewClock(o); err != nil {
+			return nil, err
+		}
+	}
+	return r.clock, nil
+}
+
+// newTransaction instantiate a transaction taking resource-versions from the clock. It can return
+// errors on instantiating the clock.
+func (r *Repository) newTransaction() (*orm.Transaction, error) {
+	clock, err := r.clockFactory()
+	if err != nil {
+		return nil, err
+	}
+	return orm.NewTransaction(r.logger, clock), nil
 }
 
 // schemaFactory creates a single schema instance per name.
 func (r *Repository) schemaFactory(schemaName string) *orm.Schema {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	_, exists := r.schemas[schemaName]
 	if !exists {
 		r.logger.WithValues("schema", schemaName).Info("Instantiating Schema...")
@@ -73,11 +171,33 @@ func (r *Repository) schemaFactory(schemaName string) *orm.Schema {
 	return r.schemas[schemaName]
 }
 
+// lookupSchema returns the schema instance by name, and false when not instantiated yet.
+func (r *Repository) lookupSchema(schemaName string) (*orm.Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, found := r.schemas[schemaName]
+	return s, found
+}
+
 // schemaNameforGVK returns a orm.Schema name for a given GVK.
 func (r *Repository) schemaNameforGVK(gvk schema.GroupVersionKind) string {
 	return fmt.Sprintf("%s_%s", gvk.Version, gvk.Kind)
 }
 
+// searchPathForGroup returns the database schema (search-path) name for a GVK group, where empty
+// group is known as "core".
+func searchPathForGroup(group string) string {
+	if group == "" {
+		group = "core"
+	}
+	return strings.ReplaceAll(group, ".", "_")
+}
+
+// tablesKey returns the key employed to keep track of schemas with tables in place.
+func tablesKey(ns, searchPath string) string {
+	return fmt.Sprintf("%s/%s", ns, searchPath)
+}
+
 // factory instantiate the schema and ORM instances, making sure a single instance is in use for
 // the combination of namespace and GVK.
 func (r *Repository) factory(ns string, gvk schema.GroupVersionKind) (*orm.ORM, *orm.Schema, error) {
@@ -89,31 +209,86 @@ func (r *Repository) factory(ns string, gvk schema.GroupVersionKind) (*orm.ORM,
 	}
 	if gvk.Group == "" {
 		logger.Info("Assuming 'core' since GVK's group is empty")
-		gvk.Group = "core"
 	}
 
-	group := strings.ReplaceAll(gvk.Group, ".", "_")
-	o := r.ormFactory(ns, group)
+	group := searchPathForGroup(gvk.Group)
+	o, err := r.ormFactory(ns, group)
+	if err != nil {
+		return nil, nil, err
+	}
 	s := r.schemaFactory(r.schemaNameforGVK(gvk))
-
-	// checking when database connection is not yet instantiated to verify if schemas has tables
-	// defined, and therefore create them. After those steps the database connection will be
-	// instantiated and thus won't be subject to connect or create-tables again
-	if o.DB == nil {
-		logger.Info("Bootstrapping database connection...")
-		if err := o.Bootstrap(); err != nil {
-			return nil, nil, err
-		}
-		if len(s.Tables) > 0 {
-			logger.Info("Creating schema tables")
-			if err := o.CreateTables(s); err != nil {
-				return nil, nil, err
-			}
-		}
+	if err = r.ensureTables(tablesKey(ns, group), o, s); err != nil {
+		return nil, nil, err
 	}
 	return o, s, nil
 }
 
+// namespaceExists checks if the namespace database is in place, either instantiated before by this
+// repository or marked by orchid. It can return errors on querying namespaces.
+func (r *Repository) namespaceExists(ns string) (bool, error) {
+	r.mu.RLock()
+	_, instantiated := r.orms[ns]
+	r.mu.RUnlock()
+	if ns == DefaultNamespace || instantiated {
+		return true, nil
+	}
+	namespaces, err := r.namespaces()
+	if err != nil {
+		return false, err
+	}
+	return orm.StringSliceContains(namespaces, ns), nil
+}
+
+// lookupFactory instantiate the schema and ORM instances as factory does, for namespaces already in
+// place alone, so reading never creates databases. It returns NamespaceNotFoundErr when the
+// namespace does not exist, and errors from factory.
+func (r *Repository) lookupFactory(
+	ns string,
+	gvk schema.GroupVersionKind,
+) (*orm.ORM, *orm.Schema, error) {
+	exists, err := r.namespaceExists(ns)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: '%s'", NamespaceNotFoundErr, ns)
+	}
+	return r.factory(ns, gvk)
+}
+
+// ensureTables verifies existing tables against the schema, and creates the missing ones, once per
+// namespace and group combination. Schemas without tables are skipped, since they are not yet
+// generated. It can return errors on verifying, including orm.SchemaMismatchErr, and on creating
+// tables.
+func (r *Repository) ensureTables(key string, o *orm.ORM, s *orm.Schema) error {
+	if len(s.Tables) == 0 || r.tablesInPlace(key, s.Name) {
+		return nil
+	}
+	logger := r.logger.WithValues("key", key, "schema", s.Name)
+	logger.Info("Verifying existing schema tables")
+	if err := o.VerifyTables(s); err != nil {
+		return err
+	}
+	logger.Info("Creating schema tables")
+	if err := o.CreateTables(s); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// tables may have been ensured concurrently, both statements being idempotent
+	if !orm.StringSliceContains(r.gvkPerNamespace[key], s.Name) {
+		r.gvkPerNamespace[key] = append(r.gvkPerNamespace[key], s.Name)
+	}
+	return nil
+}
+
+// tablesInPlace checks if the tables of the schema are known to be in place under the key.
+func (r *Repository) tablesInPlace(key, schemaName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return orm.StringSliceContains(r.gvkPerNamespace[key], schemaName)
+}
+
 // decompose prepare the data matrix from any CR resource, informed as unstructured. It can return
 // error on trying to find expected data entries.
 func (r *Repository) decompose(
@@ -155,9 +330,12 @@ func (r *Repository) decompose(
 	return cr, nil
 }
 
-// initializeSchema extracts the GVK and OpenAPI Schema from CRD object, and initialize orm.Schema.
-// It can return errors on extracting data.
+// initializeSchema extracts the GVK and OpenAPI Schema from CRD object, and initialize orm.Schema,
+// registering the kind's storage version. It can return errors on extracting data.
 func (r *Repository) initializeSchema(obj map[string]interface{}) error {
+	if err := r.registerCRD(obj); err != nil {
+		return err
+	}
 	gvk, err := ExtractCRGVKFromCRD(obj)
 	if err != nil {
 		return err
@@ -170,108 +348,395 @@ func (r *Repository) initializeSchema(obj map[string]interface{}) error {
 	return crSchema.Generate(openAPIV3Schema)
 }
 
-// Create will persist a given resource, informed as unstructured, using the ORM instance. It gives
-// special treatment for CRD objects, besides of being stored, they also trigger parsing of
-// OpenAPI Schema and creation of respective tables. When not an CRD object, it will only take care
-// of storing the data. It can return error on extracting object data and on storing.
-func (r *Repository) Create(u *unstructured.Unstructured) error {
-	gvk := u.GetObjectKind().GroupVersionKind()
-	isCRD := gvk.String() == CRDGVK.String()
+// namespaceForGVK returns the namespace where objects are stored, CRDs and objects of cluster scoped
+// kinds are always kept on the default namespace.
+func (r *Repository) namespaceForGVK(gvk schema.GroupVersionKind, ns string) string {
+	if gvk.String() == CRDGVK.String() || !r.namespaced(gvk) {
+		return DefaultNamespace
+	}
+	return ns
+}
 
-	var ns string
-	if isCRD {
-		ns = DefaultNamespace
-	} else {
-		ns = u.GetNamespace()
+// scopedName returns the namespaced-name objects are stored under, objects of cluster scoped kinds
+// are stored without namespace.
+func (r *Repository) scopedName(
+	gvk schema.GroupVersionKind,
+	namespacedName types.NamespacedName,
+) types.NamespacedName {
+	if gvk.String() != CRDGVK.String() && !r.namespaced(gvk) {
+		namespacedName.Namespace = metav1.NamespaceNone
 	}
+	return namespacedName
+}
 
-	o, s, err := r.factory(ns, gvk)
+// listNamespaces returns the namespaces objects of the GVK are listed from, where all namespaces
+// known are employed when the namespace informed is empty. Cluster scoped kinds are listed from the
+// default namespace alone. It can return errors on querying namespaces.
+func (r *Repository) listNamespaces(ns string, gvk schema.GroupVersionKind) ([]string, error) {
+	if !r.namespaced(gvk) {
+		return []string{r.namespaceForGVK(gvk, ns)}, nil
+	}
+	if ns == metav1.NamespaceAll {
+		return r.namespaces()
+	}
+	return []string{ns}, nil
+}
+
+// prepareWrite instantiate ORM and schema for the resource, assigning a new resource-version to it
+// within the transaction, and decompose it in a data matrix. Resources are converted to the storage
+// version before being decomposed. It can return errors on instantiating the ORM, obtaining the
+// resource-version, converting and extracting object data.
+func (r *Repository) prepareWrite(
+	txn *orm.Transaction,
+	u *unstructured.Unstructured,
+) (*orm.ORM, *orm.Schema, orm.MappedMatrix, error) {
+	gvk := r.storageGVK(u.GetObjectKind().GroupVersionKind())
+	// objects of cluster scoped kinds are stored without namespace
+	if !isCRD(u) && !r.namespaced(gvk) {
+		u.SetNamespace(metav1.NamespaceNone)
+	}
+	o, s, err := r.factory(r.namespaceForGVK(gvk, u.GetNamespace()), gvk)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
-	if o.DB == nil {
-		if err = o.CreateTables(s); err != nil {
-			return err
-		}
+
+	resourceVersion, err := txn.ResourceVersion(o)
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	u.SetResourceVersion(resourceVersion)
 
-	arguments, err := r.decompose(s, u)
+	stored, err := r.convertOne(gvk, u)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
+	}
+	arguments, err := r.decompose(s, stored)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	if len(arguments) == 0 {
-		return fmt.Errorf("unable to parse arguments from object")
+		return nil, nil, nil, fmt.Errorf("unable to parse arguments from object")
 	}
-	if err = o.Create(s, arguments); err != nil {
-		return err
+	return o, s, arguments, nil
+}
+
+// ormErr translates ORM and database errors into repository errors.
+func ormErr(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ObjectNotFoundErr
+	case errors.Is(err, orm.ResourceVersionConflictErr):
+		return ResourceVersionConflictErr
+	case errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation":
+		return fmt.Errorf("%w: %s", ObjectAlreadyExistsErr, pqErr.Detail)
+	case errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation":
+		return fmt.Errorf("%w: %s", ReferenceViolationErr, pqErr.Detail)
 	}
+	return err
+}
+
+// isCRD checks if the object informed is a CRD.
+func isCRD(u *unstructured.Unstructured) bool {
+	return u.GroupVersionKind().String() == CRDGVK.String()
+}
 
-	if isCRD {
-		return r.initializeSchema(u.Object)
+// single executes a single operation in its own transaction, returning the operation error as is.
+func (r *Repository) single(operation Operation) (*unstructured.Unstructured, error) {
+	objects, err := r.Transaction([]Operation{operation})
+	opErr := &OperationErr{}
+	if errors.As(err, &opErr) {
+		return nil, opErr.Err
 	}
-	return nil
+	if err != nil {
+		return nil, err
+	}
+	return objects[0], nil
+}
+
+// Create will persist a given resource, informed as unstructured, using the ORM instance. It gives
+// special treatment for CRD objects, besides of being stored, they also trigger parsing of
+// OpenAPI Schema and creation of respective tables. When not an CRD object, it will only take care
+// of storing the data. A new resource-version is assigned to the resource. It can return error on
+// extracting object data, on storing, and ObjectAlreadyExistsErr when the object is already stored.
+func (r *Repository) Create(u *unstructured.Unstructured) error {
+	_, err := r.single(Operation{Type: CreateOperation, Object: u})
+	return err
+}
+
+// Update replaces a given resource, informed as unstructured, by its new version. The resource is
+// found by namespace and name. When the resource carries a resource-version, it must match the
+// stored one. CRDs are updated together with the migration of existing tables. It can return
+// ObjectNotFoundErr when the resource does not exist, ResourceVersionConflictErr when
+// resource-versions differ, IncompatibleSchemaChangeErr on CRD changes which can't be migrated,
+// and errors on extracting object data and on storing.
+func (r *Repository) Update(u *unstructured.Unstructured) error {
+	_, err := r.single(Operation{Type: UpdateOperation, Object: u})
+	return err
+}
+
+// UpdateStatus stores only the status of informed object, leaving the remaining data untouched, and
+// assigns a new resource-version. The informed resource-version, when set, is expected to be
+// stored. It can return errors on extracting object data, on storing, ObjectNotFoundErr and
+// ResourceVersionConflictErr.
+func (r *Repository) UpdateStatus(u *unstructured.Unstructured) error {
+	_, err := r.single(Operation{Type: updateStatusOperation, Object: u})
+	return err
 }
 
-// Read a single object from ORM, searching for a namespaced-name. It can return errors from
-// querying the database, preparing the result-set, and assembling an unstructured object.
+// Read a single object from ORM, searching for a namespaced-name. The object is converted from the
+// storage version to the informed GVK. It can return errors from querying the database, preparing
+// the result-set, assembling and converting an unstructured object, and ObjectNotFoundErr when the
+// object does not exist.
 func (r *Repository) Read(
 	gvk schema.GroupVersionKind,
 	namespacedName types.NamespacedName,
 ) (*unstructured.Unstructured, error) {
-	o, s, err := r.factory(namespacedName.Namespace, gvk)
+	storageGVK := r.storageGVK(gvk)
+	o, s, err := r.lookupFactory(r.namespaceForGVK(gvk, namespacedName.Namespace), storageGVK)
+	if errors.Is(err, NamespaceNotFoundErr) {
+		return nil, ObjectNotFoundErr
+	}
 	if err != nil {
 		return nil, err
 	}
-	rs, err := o.Read(s, namespacedName)
+	if r.config.AssembleJSON {
+		return r.readJSON(o, s, gvk, namespacedName)
+	}
+	rs, err := o.Read(s, r.scopedName(gvk, namespacedName))
 	if err != nil {
 		return nil, err
 	}
+	return r.assembleOne(s, gvk, rs)
+}
 
+// readJSON reads a single object assembled as JSON by the database, converted from storage version
+// to the GVK informed. It returns ObjectNotFoundErr when the object does not exist.
+func (r *Repository) readJSON(
+	o *orm.ORM,
+	s *orm.Schema,
+	gvk schema.GroupVersionKind,
+	namespacedName types.NamespacedName,
+) (*unstructured.Unstructured, error) {
+	document, err := o.ReadJSON(s, r.scopedName(gvk, namespacedName))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ObjectNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	objects, err := objectsFromJSON([][]byte{document})
+	if err != nil {
+		return nil, err
+	}
+
+	u := objects[0]
+	u.SetGroupVersionKind(r.storageGVK(gvk))
+	return r.convertOne(gvk, u)
+}
+
+// assembleOne builds a single object out of result-set, converted from storage version to the GVK
+// informed. It returns ObjectNotFoundErr when the result-set is empty.
+func (r *Repository) assembleOne(
+	s *orm.Schema,
+	gvk schema.GroupVersionKind,
+	rs *orm.ResultSet,
+) (*unstructured.Unstructured, error) {
 	assembler := NewAssembler(r.logger, s, rs)
 	objects, err := assembler.Build()
 	if err != nil {
 		return nil, err
 	}
+	if len(objects) == 0 {
+		return nil, ObjectNotFoundErr
+	}
 	if len(objects) != 1 {
 		r.logger.WithValues("objects", len(objects)).Info("WARNING: unexpected number of objects!")
 	}
 
 	u := objects[0]
+	u.SetGroupVersionKind(r.storageGVK(gvk))
+	return r.convertOne(gvk, u)
+}
+
+// Delete removes a single object, searching for a namespaced-name, together with all its nested
+// data, returning the object as it was before removal, carrying the resource-version of its
+// removal. It can return ObjectNotFoundErr when the object does not exist, and errors from the
+// database and assembling the object.
+func (r *Repository) Delete(
+	gvk schema.GroupVersionKind,
+	namespacedName types.NamespacedName,
+) (*unstructured.Unstructured, error) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
 	u.SetGroupVersionKind(gvk)
-	return u, nil
+	u.SetNamespace(namespacedName.Namespace)
+	u.SetName(namespacedName.Name)
+	return r.single(Operation{Type: DeleteOperation, Object: u})
 }
 
-// List objects from schema based on metav1.ListOptions.
-func (r *Repository) List(
+// parseSelectors parses label and field selectors out of list options. It returns
+// InvalidSelectorErr when selectors can't be parsed.
+func parseSelectors(options metav1.ListOptions) (labels.Selector, fields.Selector, error) {
+	labelSelector, err := labels.Parse(options.LabelSelector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", InvalidSelectorErr, err)
+	}
+	fieldSelector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", InvalidSelectorErr, err)
+	}
+	return labelSelector, fieldSelector, nil
+}
+
+// assembleList lists the objects matching the options informed, either assembled as JSON by the
+// database, or out of a result-set by the assembler, depending on configuration.
+func (r *Repository) assembleList(
+	o *orm.ORM,
+	s *orm.Schema,
+	options *orm.ListOptions,
+) ([]*unstructured.Unstructured, error) {
+	if r.config.AssembleJSON {
+		documents, err := o.ListJSON(s, options)
+		if err != nil {
+			return nil, err
+		}
+		return objectsFromJSON(documents)
+	}
+	rs, err := o.List(s, options)
+	if err != nil {
+		return nil, err
+	}
+	return NewAssembler(r.logger, s, rs).Build()
+}
+
+// listNamespace list objects from a single namespace based on metav1.ListOptions, converted from
+// the storage version to the informed GVK and ordered by name. Here, continue carries the name of
+// the last object of the previous page. Namespaces which do not exist have no objects.
+func (r *Repository) listNamespace(
 	ns string,
 	gvk schema.GroupVersionKind,
 	options metav1.ListOptions,
-) (*unstructured.UnstructuredList, error) {
-	o, s, err := r.factory(ns, gvk)
+) ([]*unstructured.Unstructured, error) {
+	storageGVK := r.storageGVK(gvk)
+	o, s, err := r.lookupFactory(ns, storageGVK)
+	if errors.Is(err, NamespaceNotFoundErr) {
+		return []*unstructured.Unstructured{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	labelsSet, err := labels.ConvertSelectorToLabelsMap(options.LabelSelector)
+	labelSelector, fieldSelector, err := parseSelectors(options)
 	if err != nil {
 		return nil, err
 	}
 
-	rs, err := o.List(s, labelsSet)
+	objects, err := r.assembleList(o, s, &orm.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fieldSelector,
+		Limit:         options.Limit,
+		Continue:      options.Continue,
+	})
+	if errors.Is(err, orm.UnsupportedOperatorErr) || errors.Is(err, orm.FieldNotSelectableErr) {
+		return nil, fmt.Errorf("%w: %s", InvalidSelectorErr, err)
+	}
 	if err != nil {
 		return nil, err
 	}
+	for _, u := range objects {
+		u.SetGroupVersionKind(storageGVK)
+	}
+	return r.convert(gvk, objects)
+}
 
-	assembler := NewAssembler(r.logger, s, rs)
-	objects, err := assembler.Build()
+// namespaces returns the name of all namespaces, represented as databases, where objects may be
+// stored. It can return errors on instantiating the default namespace ORM and querying.
+func (r *Repository) namespaces() ([]string, error) {
+	o, _, err := r.factory(DefaultNamespace, CRDGVK)
 	if err != nil {
 		return nil, err
 	}
+	return o.Databases()
+}
+
+// List objects from schema based on metav1.ListOptions. When namespace is empty (all namespaces),
+// objects are listed from every namespace known. Objects of cluster scoped kinds are listed
+// regardless of the namespace informed. Objects are ordered by namespace and name, and when a limit
+// is informed, the list carries a continue token for the next page. The list resource-version is
+// the clock's, taken before listing, and kept by following pages. It can return InvalidContinueErr
+// on tokens which can't be decoded, and errors on querying the clock.
+func (r *Repository) List(
+	ns string,
+	gvk schema.GroupVersionKind,
+	options metav1.ListOptions,
+) (*unstructured.UnstructuredList, error) {
+	namespaces, err := r.listNamespaces(ns, gvk)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(namespaces)
 
 	list := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{}}
-	for _, u := range objects {
-		u.SetGroupVersionKind(gvk)
-		list.Items = append(list.Items, *u)
+	var listResourceVersion int64
+	var token *continueToken
+	if options.Continue != "" {
+		if token, err = decodeContinueToken(options.Continue); err != nil {
+			return nil, err
+		}
+		// following pages keep the resource-version of the first
+		listResourceVersion = token.ResourceVersion
+	} else {
+		clock, err := r.clockFactory()
+		if err != nil {
+			return nil, err
+		}
+		if listResourceVersion, err = clock.Current(); err != nil {
+			return nil, err
+		}
+	}
+
+	var last *continueToken
+	more := false
+	for _, ns := range namespaces {
+		if token != nil && ns < token.Namespace {
+			continue
+		}
+		pageOptions := options
+		pageOptions.Continue = ""
+		if token != nil && ns == token.Namespace {
+			pageOptions.Continue = token.Name
+		}
+		// asking for an extra object, to find out whether there are more
+		if options.Limit > 0 {
+			pageOptions.Limit = options.Limit - int64(len(list.Items)) + 1
+		}
+
+		objects, err := r.listNamespace(ns, gvk, pageOptions)
+		if err != nil {
+			return nil, err
+		}
+		if options.Limit > 0 && int64(len(list.Items)+len(objects)) > options.Limit {
+			objects = objects[:options.Limit-int64(len(list.Items))]
+			more = true
+		}
+		for _, u := range objects {
+			list.Items = append(list.Items, *u)
+			last = &continueToken{Namespace: ns, Name: u.GetName()}
+		}
+		if more {
+			break
+		}
+	}
+	if listResourceVersion > 0 {
+		list.SetResourceVersion(strconv.FormatInt(listResourceVersion, 10))
+	}
+	if more && last != nil {
+		last.ResourceVersion = listResourceVersion
+		encoded, err := last.encode()
+		if err != nil {
+			return nil, err
+		}
+		list.SetContinue(encoded)
 	}
 	return list, nil
 }
@@ -291,8 +756,58 @@ func (r *Repository) bootstrapGVK(
 	return err
 }
 
+// verifyTables verifies the existing tables of the GVK schema in a namespace, on a short lived
+// connection, without creating database, schema or tables; those are left for factory, on first
+// use. It can return errors on connecting, and orm.SchemaMismatchErr.
+func (r *Repository) verifyTables(ns string, gvk schema.GroupVersionKind) error {
+	s := r.schemaFactory(r.schemaNameforGVK(gvk))
+	o := orm.NewORM(r.logger, ns, searchPathForGroup(gvk.Group), r.config)
+	if err := o.Connect(); err != nil {
+		return err
+	}
+	defer o.DB.Close()
+	return o.VerifyTables(s)
+}
+
+// rehydrate regenerates the schemas of every stored CRD, and verifies their tables in every
+// namespace known by orchid, so objects stored before a restart remain reachable. It can return
+// errors on listing CRDs and namespaces, on generating schemas, and when existing tables do not
+// match.
+func (r *Repository) rehydrate() error {
+	crds, err := r.List(DefaultNamespace, CRDGVK, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	gvks := make([]schema.GroupVersionKind, 0, len(crds.Items))
+	for _, crd := range crds.Items {
+		r.logger.WithValues("name", crd.GetName()).Info("Rehydrating CRD schema...")
+		if err = r.initializeSchema(crd.Object); err != nil {
+			return err
+		}
+		gvk, err := ExtractCRGVKFromCRD(crd.Object)
+		if err != nil {
+			return err
+		}
+		gvks = append(gvks, gvk)
+	}
+
+	namespaces, err := r.namespaces()
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		for _, gvk := range gvks {
+			if err = r.verifyTables(ns, gvk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Bootstrap the repository instance by instantiating CRD schema, and making sure the CRD storage
-// has tables created. It can return error on creating CRD tables.
+// has tables created. Schemas of stored CRDs are rehydrated. It can return error on creating CRD
+// tables, and on rehydrating schemas.
 func (r *Repository) Bootstrap() error {
 	// instantiating CRD storage
 	crdAPISchema := jsc.ExtV1CRDOpenAPIV3Schema()
@@ -301,7 +816,10 @@ func (r *Repository) Bootstrap() error {
 	}
 	// instantiating core/v1 Namespace storage
 	nsAPISchema := jsc.CoreV1NamespaceOpenAPIV3Schema()
-	return r.bootstrapGVK(NSGVK, &nsAPISchema)
+	if err := r.bootstrapGVK(NSGVK, &nsAPISchema); err != nil {
+		return err
+	}
+	return r.rehydrate()
 }
 
 // NewRepository instantiate repository.
@@ -312,5 +830,7 @@ func NewRepository(logger logr.Logger, config *config.Config) *Repository {
 		orms:            map[string]map[string]*orm.ORM{},
 		schemas:         map[string]*orm.Schema{},
 		gvkPerNamespace: map[string][]string{},
+		kinds:           map[schema.GroupKind]*storedKind{},
+		listener:        orm.NewListener(logger),
 	}
 }
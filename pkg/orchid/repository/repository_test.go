@@ -1,15 +1,25 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/go-test/deep"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/klog/klogr"
 
 	"github.com/isutton/orchid/pkg/orchid/config"
@@ -18,7 +28,7 @@ import (
 	"github.com/isutton/orchid/test/mocks"
 )
 
-func buildTestRepository(t *testing.T) (logr.Logger, *Repository) {
+func buildTestRepository(t testing.TB) (logr.Logger, *Repository) {
 	logger := klogr.New().WithName("test")
 	config := &config.Config{Username: "postgres", Password: "1", Options: "sslmode=disable"}
 
@@ -78,6 +88,55 @@ func TestRepository_decompose(t *testing.T) {
 	assert.Equal(t, jsonData, string(bytes))
 }
 
+func TestRepository_ormErr(t *testing.T) {
+	t.Run("unique violation", func(t *testing.T) {
+		err := ormErr(&pq.Error{Code: "23505", Detail: "Key (name)=(example) already exists."})
+		require.True(t, errors.Is(err, ObjectAlreadyExistsErr))
+		assert.Contains(t, err.Error(), "Key (name)=(example) already exists.")
+	})
+
+	t.Run("foreign key violation", func(t *testing.T) {
+		err := ormErr(&pq.Error{Code: "23503"})
+		require.True(t, errors.Is(err, ReferenceViolationErr))
+	})
+
+	t.Run("other database errors", func(t *testing.T) {
+		pqErr := &pq.Error{Code: "42P01"}
+		require.Equal(t, pqErr, ormErr(pqErr))
+	})
+}
+
+func TestRepository_concurrentAccess(t *testing.T) {
+	_, repo := buildTestRepository(t)
+	crd := multiVersionCRDMock(t)
+	cr, err := mocks.UnstructuredCRMock("ns", "name")
+	require.NoError(t, err)
+	gvk := cr.GroupVersionKind()
+
+	// handlers and watches share the repository maps, which must hold under -race
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, repo.registerCRD(crd.Object))
+			repo.schemaFactory(repo.schemaNameforGVK(gvk))
+		}()
+		go func() {
+			defer wg.Done()
+			repo.storageGVK(gvk)
+			repo.namespaced(gvk)
+			repo.lookupSchema(repo.schemaNameforGVK(gvk))
+			repo.tablesInPlace(tablesKey(DefaultNamespace, "core"), repo.schemaNameforGVK(gvk))
+		}()
+	}
+	wg.Wait()
+
+	_, found := repo.lookupSchema(repo.schemaNameforGVK(gvk))
+	require.True(t, found)
+	require.Equal(t, gvk, repo.storageGVK(gvk.GroupKind().WithVersion("v1alpha1")))
+}
+
 func TestRepository_New(t *testing.T) {
 	_, repo := buildTestRepository(t)
 	err := repo.Bootstrap()
@@ -129,6 +188,237 @@ func TestRepository_New(t *testing.T) {
 		}
 	})
 
+	t.Run("Update-CR", func(t *testing.T) {
+		updated := cr.DeepCopy()
+		updated.SetLabels(map[string]string{"label": "label", "updated": "true"})
+		err = repo.Update(updated)
+		require.NoError(t, err)
+
+		namespacedName := types.NamespacedName{
+			Namespace: cr.GetNamespace(),
+			Name:      cr.GetName(),
+		}
+		u, err := repo.Read(gvk, namespacedName)
+		require.NoError(t, err)
+		assert.Equal(t, updated.GetLabels(), u.GetLabels())
+		assert.NotEqual(t, cr.GetResourceVersion(), u.GetResourceVersion())
+
+		stale := cr.DeepCopy()
+		err = repo.Update(stale)
+		require.Equal(t, ResourceVersionConflictErr, err)
+
+		missing := cr.DeepCopy()
+		missing.SetName(mocks.RandomString(12))
+		err = repo.Update(missing)
+		require.Equal(t, ObjectNotFoundErr, err)
+	})
+
+	t.Run("UpdateStatus-CR", func(t *testing.T) {
+		namespacedName := types.NamespacedName{
+			Namespace: cr.GetNamespace(),
+			Name:      cr.GetName(),
+		}
+		current, err := repo.Read(gvk, namespacedName)
+		require.NoError(t, err)
+
+		// only status is stored, other changes are ignored
+		changed := current.DeepCopy()
+		changed.SetLabels(map[string]string{"ignored": "true"})
+		err = repo.UpdateStatus(changed)
+		require.NoError(t, err)
+
+		u, err := repo.Read(gvk, namespacedName)
+		require.NoError(t, err)
+		assert.Equal(t, current.GetLabels(), u.GetLabels())
+		assert.NotEqual(t, current.GetResourceVersion(), u.GetResourceVersion())
+
+		err = repo.UpdateStatus(current.DeepCopy())
+		require.Equal(t, ResourceVersionConflictErr, err)
+
+		missing := current.DeepCopy()
+		missing.SetName(mocks.RandomString(12))
+		err = repo.UpdateStatus(missing)
+		require.Equal(t, ObjectNotFoundErr, err)
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		created, err := mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
+		require.NoError(t, err)
+		updated := cr.DeepCopy()
+		updated.SetLabels(map[string]string{"transaction": "true"})
+
+		objects, err := repo.Transaction([]Operation{
+			{Type: CreateOperation, Object: created},
+			{Type: UpdateOperation, Object: updated},
+		})
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+
+		// second operation fails, therefore the first must be rolled back
+		rolledBack, err := mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
+		require.NoError(t, err)
+		missing := cr.DeepCopy()
+		missing.SetName(mocks.RandomString(12))
+		_, err = repo.Transaction([]Operation{
+			{Type: CreateOperation, Object: rolledBack},
+			{Type: DeleteOperation, Object: missing},
+		})
+		opErr := &OperationErr{}
+		require.True(t, errors.As(err, &opErr))
+		require.Equal(t, 1, opErr.Index)
+		require.Equal(t, ObjectNotFoundErr, opErr.Err)
+
+		namespacedName := types.NamespacedName{
+			Namespace: rolledBack.GetNamespace(),
+			Name:      rolledBack.GetName(),
+		}
+		_, err = repo.Read(gvk, namespacedName)
+		require.Equal(t, ObjectNotFoundErr, err)
+	})
+
+	t.Run("Delete-CR", func(t *testing.T) {
+		deleted, err := mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(deleted))
+
+		namespacedName := types.NamespacedName{
+			Namespace: deleted.GetNamespace(),
+			Name:      deleted.GetName(),
+		}
+		u, err := repo.Delete(gvk, namespacedName)
+		require.NoError(t, err)
+		assert.Equal(t, deleted.GetName(), u.GetName())
+		assert.Equal(t, deleted.GetLabels(), u.GetLabels())
+
+		_, err = repo.Read(gvk, namespacedName)
+		require.Equal(t, ObjectNotFoundErr, err)
+
+		_, err = repo.Delete(gvk, namespacedName)
+		require.Equal(t, ObjectNotFoundErr, err)
+	})
+
+	// resource-versions are taken from a single clock, across namespaces, deletes included
+	t.Run("Resource-versions", func(t *testing.T) {
+		ns := fmt.Sprintf("ns-%s", strings.ToLower(mocks.RandomString(8)))
+		first, err := mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(first))
+		second, err := mocks.UnstructuredCRMock(ns, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(second))
+		deleted, err := repo.Delete(gvk, types.NamespacedName{
+			Namespace: first.GetNamespace(),
+			Name:      first.GetName(),
+		})
+		require.NoError(t, err)
+
+		resourceVersions := []int64{}
+		for _, u := range []*unstructured.Unstructured{first, second, deleted} {
+			resourceVersion, err := strconv.ParseInt(u.GetResourceVersion(), 10, 64)
+			require.NoError(t, err)
+			resourceVersions = append(resourceVersions, resourceVersion)
+		}
+		require.Less(t, resourceVersions[0], resourceVersions[1])
+		require.Less(t, resourceVersions[1], resourceVersions[2])
+
+		list, err := repo.List(metav1.NamespaceAll, gvk, metav1.ListOptions{})
+		require.NoError(t, err)
+		listResourceVersion, err := strconv.ParseInt(list.GetResourceVersion(), 10, 64)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, listResourceVersion, resourceVersions[2])
+	})
+
+	// reading a namespace which does not exist must not create its database
+	t.Run("Read-missing-namespace", func(t *testing.T) {
+		missing := strings.ToLower(fmt.Sprintf("missing-%s", mocks.RandomString(8)))
+		_, err := repo.Read(gvk, types.NamespacedName{Namespace: missing, Name: "name"})
+		require.Equal(t, ObjectNotFoundErr, err)
+		_, err = repo.Delete(gvk, types.NamespacedName{Namespace: missing, Name: "name"})
+		require.Equal(t, ObjectNotFoundErr, err)
+
+		list, err := repo.List(missing, gvk, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Empty(t, list.Items)
+
+		_, err = repo.Watch(context.Background(), missing, gvk, metav1.ListOptions{})
+		require.True(t, errors.Is(err, NamespaceNotFoundErr))
+
+		namespaces, err := repo.namespaces()
+		require.NoError(t, err)
+		require.NotContains(t, namespaces, missing)
+	})
+
+	// Rehydrate simulates a restart, a new repository instance must be able to reach objects stored
+	// by the previous one, using schemas generated out of stored CRDs
+	t.Run("Rehydrate", func(t *testing.T) {
+		// databases not created by orchid are left alone
+		unrelated := strings.ToLower(fmt.Sprintf("unrelated_%s", mocks.RandomString(8)))
+		o, _, err := repo.factory(DefaultNamespace, CRDGVK)
+		require.NoError(t, err)
+		_, err = o.DB.Exec(orm.CreateDatabaseStatement(unrelated))
+		require.NoError(t, err)
+		defer o.DB.Exec(fmt.Sprintf("drop database %s", unrelated))
+
+		_, restarted := buildTestRepository(t)
+		require.NoError(t, restarted.Bootstrap())
+		namespaces, err := restarted.namespaces()
+		require.NoError(t, err)
+		require.Contains(t, namespaces, DefaultNamespace)
+		require.NotContains(t, namespaces, unrelated)
+
+		namespacedName := types.NamespacedName{
+			Namespace: cr.GetNamespace(),
+			Name:      cr.GetName(),
+		}
+		u, err := restarted.Read(gvk, namespacedName)
+		require.NoError(t, err)
+		assert.Equal(t, cr.GetName(), u.GetName())
+	})
+
+	// Migrate-restarted makes sure CRD changes migrate the tables of every namespace, including the
+	// ones a restarted repository did not touch yet
+	t.Run("Migrate-restarted", func(t *testing.T) {
+		ns := strings.ToLower(fmt.Sprintf("migrate-%s", mocks.RandomString(8)))
+		stored, err := mocks.UnstructuredCRMock(ns, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(stored))
+
+		_, restarted := buildTestRepository(t)
+		require.NoError(t, restarted.Bootstrap())
+		crds, err := restarted.List(DefaultNamespace, CRDGVK, metav1.ListOptions{})
+		require.NoError(t, err)
+		var crd *unstructured.Unstructured
+		for i := range crds.Items {
+			crdGVK, err := ExtractCRGVKFromCRD(crds.Items[i].Object)
+			require.NoError(t, err)
+			if crdGVK == gvk {
+				crd = &crds.Items[i]
+			}
+		}
+		require.NotNil(t, crd)
+
+		// adding a new property to the spec of the stored version
+		versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		require.NoError(t, err)
+		version := versions[0].(map[string]interface{})
+		err = unstructured.SetNestedField(version, map[string]interface{}{"type": "string"},
+			"schema", "openAPIV3Schema", "properties", "spec", "properties", "added")
+		require.NoError(t, err)
+		require.NoError(t, unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"))
+		require.NoError(t, restarted.Update(crd))
+
+		added, err := mocks.UnstructuredCRMock(ns, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, unstructured.SetNestedField(added.Object, "added", "spec", "added"))
+		require.NoError(t, restarted.Create(added))
+
+		u, err := restarted.Read(gvk, types.NamespacedName{Namespace: ns, Name: added.GetName()})
+		require.NoError(t, err)
+		value, _, err := unstructured.NestedString(u.Object, "spec", "added")
+		require.NoError(t, err)
+		require.Equal(t, "added", value)
+	})
+
 	t.Run("List-CR", func(t *testing.T) {
 		cr, _ = mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
 		err = repo.Create(cr)
@@ -157,4 +447,172 @@ func TestRepository_New(t *testing.T) {
 			require.NoError(t, err)
 		}
 	})
+
+	t.Run("List-CR-paginated", func(t *testing.T) {
+		// "a-c" sorts before "ab" in byte order, but after it in collations such as en_US.utf8
+		prefix := mocks.RandomString(8)
+		for _, suffix := range []string{"ab", "a-c", "a-a", "aa"} {
+			cr, _ = mocks.UnstructuredCRMock(DefaultNamespace, prefix+suffix)
+			require.NoError(t, repo.Create(cr))
+		}
+		all, err := repo.List(DefaultNamespace, gvk, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.True(t, len(all.Items) >= 2)
+		require.Empty(t, all.GetContinue())
+
+		names := []string{}
+		options := metav1.ListOptions{Limit: 1}
+		for {
+			page, err := repo.List(DefaultNamespace, gvk, options)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.Items), 1)
+			for _, item := range page.Items {
+				names = append(names, item.GetName())
+			}
+			if page.GetContinue() == "" {
+				break
+			}
+			options.Continue = page.GetContinue()
+		}
+		require.Len(t, names, len(all.Items))
+		require.True(t, sort.StringsAreSorted(names))
+		for i, item := range all.Items {
+			require.Equal(t, item.GetName(), names[i])
+		}
+
+		_, err = repo.List(DefaultNamespace, gvk, metav1.ListOptions{Continue: "invalid"})
+		require.True(t, errors.Is(err, InvalidContinueErr))
+	})
+
+	t.Run("List-CR-json", func(t *testing.T) {
+		assembled, err := repo.List(DefaultNamespace, gvk, metav1.ListOptions{})
+		require.NoError(t, err)
+
+		repo.config.AssembleJSON = true
+		defer func() { repo.config.AssembleJSON = false }()
+
+		built, err := repo.List(DefaultNamespace, gvk, metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, built.Items, len(assembled.Items))
+		for i, item := range built.Items {
+			assert.Equal(t, assembled.Items[i].GetName(), item.GetName())
+			assert.Equal(t, assembled.Items[i].GetLabels(), item.GetLabels())
+			assert.Equal(t, assembled.Items[i].GetResourceVersion(), item.GetResourceVersion())
+		}
+
+		namespacedName := types.NamespacedName{
+			Namespace: cr.GetNamespace(),
+			Name:      cr.GetName(),
+		}
+		u, err := repo.Read(gvk, namespacedName)
+		require.NoError(t, err)
+		assert.Equal(t, cr.GetName(), u.GetName())
+
+		namespacedName.Name = mocks.RandomString(12)
+		_, err = repo.Read(gvk, namespacedName)
+		require.Equal(t, ObjectNotFoundErr, err)
+	})
+
+	// resuming a watch is refused once objects changed, deletions included
+	t.Run("Watch-resume", func(t *testing.T) {
+		deleted, err := mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(deleted))
+		u, err := repo.Delete(gvk, types.NamespacedName{
+			Namespace: deleted.GetNamespace(),
+			Name:      deleted.GetName(),
+		})
+		require.NoError(t, err)
+
+		tests := []struct {
+			name            string
+			resourceVersion string
+			wantErr         bool
+		}{
+			{name: "after delete", resourceVersion: u.GetResourceVersion()},
+			{name: "before delete", resourceVersion: deleted.GetResourceVersion(), wantErr: true},
+			{name: "not a number", resourceVersion: "abc", wantErr: true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				_, err := repo.Watch(ctx, DefaultNamespace, gvk, metav1.ListOptions{
+					ResourceVersion: tt.resourceVersion,
+				})
+				if tt.wantErr {
+					require.True(t, errors.Is(err, ResourceVersionExpiredErr))
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("Watch-new-namespace", func(t *testing.T) {
+		interval := namespacesPollInterval
+		namespacesPollInterval = 100 * time.Millisecond
+		defer func() { namespacesPollInterval = interval }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := repo.Watch(ctx, metav1.NamespaceAll, gvk, metav1.ListOptions{})
+		require.NoError(t, err)
+
+		// namespace created after the watch started
+		ns := strings.ToLower(fmt.Sprintf("ns_%s", mocks.RandomString(8)))
+		created, err := mocks.UnstructuredCRMock(ns, mocks.RandomString(12))
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(created))
+
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case event := <-events:
+				u, ok := event.Object.(*unstructured.Unstructured)
+				if !ok || u.GetNamespace() != ns {
+					continue
+				}
+				assert.Equal(t, watch.Added, event.Type)
+				assert.Equal(t, created.GetName(), u.GetName())
+				return
+			case <-timeout:
+				t.Fatal("object created on a new namespace was not observed")
+			}
+		}
+	})
+}
+
+// BenchmarkRepository_List compares listing objects with the assembler, and assembling them as
+// JSON in the database.
+func BenchmarkRepository_List(b *testing.B) {
+	_, repo := buildTestRepository(b)
+	require.NoError(b, repo.Bootstrap())
+
+	crd, err := mocks.UnstructuredCRDMock(DefaultNamespace, mocks.RandomString(8))
+	require.NoError(b, err)
+	require.NoError(b, repo.Create(crd))
+
+	var cr *unstructured.Unstructured
+	for i := 0; i < 500; i++ {
+		cr, err = mocks.UnstructuredCRMock(DefaultNamespace, mocks.RandomString(12))
+		require.NoError(b, err)
+		require.NoError(b, repo.Create(cr))
+	}
+	gvk := cr.GetObjectKind().GroupVersionKind()
+
+	for _, assembleJSON := range []bool{false, true} {
+		name := "assembler"
+		if assembleJSON {
+			name = "json"
+		}
+		b.Run(name, func(b *testing.B) {
+			repo.config.AssembleJSON = assembleJSON
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := repo.List(DefaultNamespace, gvk, metav1.ListOptions{})
+				require.NoError(b, err)
+			}
+		})
+	}
 }
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/isutton/orchid/pkg/orchid/orm"
+)
+
+// OperationType type of write operation in a transaction.
+type OperationType string
+
+const (
+	// CreateOperation stores a new object.
+	CreateOperation OperationType = "create"
+	// UpdateOperation replaces an existing object.
+	UpdateOperation OperationType = "update"
+	// DeleteOperation removes an existing object, identified by its type, namespace and name.
+	DeleteOperation OperationType = "delete"
+	// updateStatusOperation replaces the status of an existing object, as UpdateStatus does.
+	updateStatusOperation OperationType = "updateStatus"
+)
+
+// Operation is a single write in a transaction.
+type Operation struct {
+	Type   OperationType              // operation type
+	Object *unstructured.Unstructured // object subject to the operation
+}
+
+// UnknownOperationErr returned when the operation type is not supported.
+var UnknownOperationErr = errors.New("unknown operation type")
+
+// TwoPhaseCommitDisabledErr returned when a transaction spans several namespaces, and PostgreSQL
+// does not allow prepared transactions.
+var TwoPhaseCommitDisabledErr = orm.TwoPhaseCommitDisabledErr
+
+// OperationErr returned when a single operation fails, causing the whole transaction to be rolled
+// back.
+type OperationErr struct {
+	Index int   // operation index
+	Err   error // operation error
+}
+
+// Error returns the failing operation index and its error.
+func (e *OperationErr) Error() string {
+	return fmt.Sprintf("operation %d failed: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the operation error.
+func (e *OperationErr) Unwrap() error {
+	return e.Err
+}
+
+// execute a single operation as part of the transaction, returning the object stored, or the object
+// removed for delete operations, carrying the resource-version of its removal.
+func (r *Repository) execute(
+	txn *orm.Transaction,
+	operation Operation,
+) (*unstructured.Unstructured, error) {
+	u := operation.Object
+	namespacedName := r.scopedName(
+		u.GroupVersionKind(),
+		types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()},
+	)
+
+	switch operation.Type {
+	case CreateOperation:
+		o, s, arguments, err := r.prepareWrite(txn, u)
+		if err != nil {
+			return nil, err
+		}
+		return u, txn.Create(o, s, namespacedName, u.GetResourceVersion(), arguments)
+	case UpdateOperation, updateStatusOperation:
+		// replacing informed resource-version by a new one, the informed is expected to be stored
+		expected := u.GetResourceVersion()
+		o, s, arguments, err := r.prepareWrite(txn, u)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Type == updateStatusOperation {
+			return u, txn.UpdateStatus(
+				o, s, namespacedName, expected, u.GetResourceVersion(), arguments)
+		}
+		return u, txn.Update(o, s, namespacedName, expected, u.GetResourceVersion(), arguments)
+	case DeleteOperation:
+		gvk := u.GroupVersionKind()
+		o, s, err := r.lookupFactory(r.namespaceForGVK(gvk, u.GetNamespace()), r.storageGVK(gvk))
+		if errors.Is(err, NamespaceNotFoundErr) {
+			return nil, ObjectNotFoundErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		resourceVersion, err := txn.ResourceVersion(o)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := txn.Delete(o, s, namespacedName, resourceVersion)
+		if err != nil {
+			return nil, err
+		}
+		deleted, err := r.assembleOne(s, gvk, rs)
+		if err != nil {
+			return nil, err
+		}
+		deleted.SetResourceVersion(resourceVersion)
+		return deleted, nil
+	}
+	return nil, UnknownOperationErr
+}
+
+// Transaction executes the operations in order, committing all of them at once, even when spanning
+// several namespaces. CRD updates migrate existing tables within the transaction. When a single
+// operation fails, the transaction is rolled back and OperationErr is returned. The objects stored
+// are returned in the same order as operations, where deleted objects are returned as they were
+// before removal. It can return errors on committing, and TwoPhaseCommitDisabledErr when
+// operations span several namespaces but PostgreSQL does not allow prepared transactions.
+func (r *Repository) Transaction(operations []Operation) ([]*unstructured.Unstructured, error) {
+	txn, err := r.newTransaction()
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]*unstructured.Unstructured, 0, len(operations))
+	migrated := []*orm.Schema{}
+	for i, operation := range operations {
+		// CRD updates carry the migration of existing tables
+		if operation.Type == UpdateOperation && isCRD(operation.Object) {
+			s, err := r.migrate(txn, operation.Object)
+			if err != nil {
+				if rollbackErr := txn.Rollback(); rollbackErr != nil {
+					r.logger.Error(rollbackErr, "Error on rolling back transaction.")
+				}
+				return nil, &OperationErr{Index: i, Err: err}
+			}
+			migrated = append(migrated, s)
+		}
+
+		u, err := r.execute(txn, operation)
+		if err != nil {
+			if rollbackErr := txn.Rollback(); rollbackErr != nil {
+				r.logger.Error(rollbackErr, "Error on rolling back transaction.")
+			}
+			return nil, &OperationErr{Index: i, Err: ormErr(err)}
+		}
+		objects = append(objects, u)
+	}
+	if err = txn.Commit(); err != nil {
+		return nil, err
+	}
+
+	// CRDs created and migrated are only taken into account when committed
+	r.mu.Lock()
+	for _, s := range migrated {
+		r.schemas[s.Name] = s
+	}
+	r.mu.Unlock()
+	for i, operation := range operations {
+		if !isCRD(objects[i]) {
+			continue
+		}
+		var err error
+		switch operation.Type {
+		case CreateOperation:
+			err = r.initializeSchema(objects[i].Object)
+		case UpdateOperation:
+			err = r.registerCRD(objects[i].Object)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/isutton/orchid/pkg/orchid/orm"
+)
+
+// ResourceVersionExpiredErr returned when watching from a resource-version older than the latest
+// change on the objects watched, since changes in between can't be replayed.
+var ResourceVersionExpiredErr = errors.New("too old resource version")
+
+// watcher keeps track of objects sent to a single watch, in order to tell apart added and modified
+// objects, and to send the last known state of deleted objects.
+type watcher struct {
+	r        *Repository                                         // repository instance
+	gvk      schema.GroupVersionKind                             // watched GVK
+	selector labels.Selector                                     // label selector
+	fields   fields.Selector                                     // field selector
+	known    map[types.NamespacedName]*unstructured.Unstructured // objects sent so far
+	events   chan watch.Event                                    // outgoing events
+}
+
+// send an event, giving up when context is done.
+func (w *watcher) send(
+	ctx context.Context,
+	eventType watch.EventType,
+	u *unstructured.Unstructured,
+) {
+	select {
+	case w.events <- watch.Event{Type: eventType, Object: u}:
+	case <-ctx.Done():
+	}
+}
+
+// sendError sends an error event carrying a status object, taken from status errors, or describing
+// an internal error otherwise.
+func (w *watcher) sendError(ctx context.Context, err error) {
+	statusErr, ok := err.(apierrors.APIStatus)
+	if !ok {
+		statusErr = apierrors.NewInternalError(err)
+	}
+	status := statusErr.Status()
+	select {
+	case w.events <- watch.Event{Type: watch.Error, Object: &status}:
+	case <-ctx.Done():
+	}
+}
+
+// added records and sends an object as added.
+func (w *watcher) added(ctx context.Context, u *unstructured.Unstructured) {
+	w.known[types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}] = u
+	w.send(ctx, watch.Added, u)
+}
+
+// deletedStub returns an object carrying only type and identification, used when the object deleted
+// was not seen by this watch before.
+func (w *watcher) deletedStub(namespacedName types.NamespacedName) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	u.SetGroupVersionKind(w.gvk)
+	u.SetNamespace(namespacedName.Namespace)
+	u.SetName(namespacedName.Name)
+	return u
+}
+
+// objectFields returns the values of the fields required by selector, formatted as strings. Fields
+// not informed in the object are left out.
+func objectFields(u *unstructured.Unstructured, selector fields.Selector) fields.Set {
+	set := fields.Set{}
+	for _, requirement := range selector.Requirements() {
+		fieldPath := strings.Split(requirement.Field, ".")
+		value, found, err := unstructured.NestedFieldNoCopy(u.Object, fieldPath...)
+		if err != nil || !found || value == nil {
+			continue
+		}
+		set[requirement.Field] = fmt.Sprint(value)
+	}
+	return set
+}
+
+// matches checks if the object matches both label and field selectors.
+func (w *watcher) matches(u *unstructured.Unstructured) bool {
+	return w.selector.Matches(labels.Set(u.GetLabels())) &&
+		w.fields.Matches(objectFields(u, w.fields))
+}
+
+// handle translates a database event into a watch event. Objects are read back from the repository,
+// being sent as added when not seen before. Objects no longer matching the selectors are sent
+// as deleted.
+func (w *watcher) handle(ctx context.Context, event orm.Event) {
+	namespacedName := event.NamespacedName()
+	previous, found := w.known[namespacedName]
+
+	if event.Type == watch.Deleted {
+		if !found {
+			if !w.selector.Empty() || !w.fields.Empty() {
+				return
+			}
+			previous = w.deletedStub(namespacedName)
+		}
+		delete(w.known, namespacedName)
+		w.send(ctx, watch.Deleted, previous)
+		return
+	}
+
+	u, err := w.r.Read(w.gvk, namespacedName)
+	if errors.Is(err, ObjectNotFoundErr) {
+		// object removed in the meantime, a delete event follows
+		return
+	}
+	if err != nil {
+		w.sendError(ctx, err)
+		return
+	}
+	if !w.matches(u) {
+		if found {
+			delete(w.known, namespacedName)
+			w.send(ctx, watch.Deleted, u)
+		}
+		return
+	}
+	if !found {
+		w.added(ctx, u)
+		return
+	}
+	w.known[namespacedName] = u
+	w.send(ctx, watch.Modified, u)
+}
+
+// namespacesPollInterval interval between checks for namespaces created after an all namespaces
+// watch started, represented as databases, which do not share notifications.
+var namespacesPollInterval = 5 * time.Second
+
+// listener merges the database events of every namespace listened in a single channel, where
+// namespaces can be added while listening. Events stop when the context is done.
+type listener struct {
+	r          *Repository             // repository instance
+	gvk        schema.GroupVersionKind // storage GVK
+	namespaces map[string]bool         // namespaces listened so far
+	events     chan orm.Event          // merged events
+}
+
+// newListener instantiate a listener for GVK, without namespaces.
+func (r *Repository) newListener(gvk schema.GroupVersionKind) *listener {
+	return &listener{
+		r:          r,
+		gvk:        gvk,
+		namespaces: map[string]bool{},
+		events:     make(chan orm.Event),
+	}
+}
+
+// listen starts listening for database events on the namespace, when not listened yet. It returns
+// NamespaceNotFoundErr when the namespace does not exist, and errors on instantiating the ORM and on
+// listening.
+func (l *listener) listen(ctx context.Context, ns string) error {
+	if l.namespaces[ns] {
+		return nil
+	}
+	o, _, err := l.r.lookupFactory(l.r.namespaceForGVK(l.gvk, ns), l.gvk)
+	if err != nil {
+		return err
+	}
+	events, err := l.r.listener.Subscribe(ctx, o)
+	if err != nil {
+		return err
+	}
+	l.namespaces[ns] = true
+	go func() {
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case l.events <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// discover listens on namespaces created after the watch started, sending their objects matching
+// options as added, since they may have been created before listening. Errors are logged, and
+// discovery is attempted again on the next poll.
+func (w *watcher) discover(ctx context.Context, l *listener, options metav1.ListOptions) {
+	logger := w.r.logger.WithValues("GVK", w.gvk)
+	namespaces, err := w.r.namespaces()
+	if err != nil {
+		logger.Error(err, "Unable to discover namespaces")
+		return
+	}
+	for _, ns := range namespaces {
+		if l.namespaces[ns] {
+			continue
+		}
+		logger.WithValues("namespace", ns).Info("Watching namespace created after watch started")
+		if err = l.listen(ctx, ns); err != nil {
+			logger.Error(err, "Unable to listen on namespace", "namespace", ns)
+			continue
+		}
+		list, err := w.r.List(ns, w.gvk, options)
+		if err != nil {
+			logger.Error(err, "Unable to list objects on namespace", "namespace", ns)
+			continue
+		}
+		for i := range list.Items {
+			u := &list.Items[i]
+			namespacedName := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
+			if _, found := w.known[namespacedName]; !found {
+				w.added(ctx, u)
+			}
+		}
+	}
+}
+
+// validateFieldSelector makes sure the fields required by selector are selectable in the GVK
+// schema, and their values match column types, as listing requires. Otherwise watches would never
+// match a single change. It returns InvalidSelectorErr when they are not.
+func (r *Repository) validateFieldSelector(
+	gvk schema.GroupVersionKind,
+	selector fields.Selector,
+) error {
+	s := r.schemaFactory(r.schemaNameforGVK(gvk))
+	for _, requirement := range selector.Requirements() {
+		_, column, err := s.SelectableColumn(strings.Split(requirement.Field, "."))
+		if err == nil {
+			_, err = column.ParseValue(requirement.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: '%s': %s", InvalidSelectorErr, requirement.Field, err)
+		}
+	}
+	return nil
+}
+
+// checkResourceVersion makes sure objects of GVK were not added, modified nor deleted after the
+// resource-version informed in any of the namespaces, according to their events log. It returns
+// ResourceVersionExpiredErr when objects changed, or when the resource-version is not a number, so
+// clients list again, and errors on instantiating the ORM and querying.
+func (r *Repository) checkResourceVersion(
+	namespaces []string,
+	gvk schema.GroupVersionKind,
+	resourceVersion string,
+) error {
+	requested, err := strconv.ParseInt(resourceVersion, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: '%s' is not valid", ResourceVersionExpiredErr, resourceVersion)
+	}
+	for _, ns := range namespaces {
+		o, s, err := r.lookupFactory(r.namespaceForGVK(gvk, ns), gvk)
+		if err != nil {
+			return err
+		}
+		changed, err := o.ChangedSince(s, requested)
+		if err != nil {
+			return err
+		}
+		if changed {
+			return fmt.Errorf("%w: (%d) objects changed since in namespace '%s'",
+				ResourceVersionExpiredErr, requested, ns)
+		}
+	}
+	return nil
+}
+
+// Watch streams the changes on objects of GVK, in the informed namespace or in all namespaces when
+// empty. Changes are captured via database notifications, thus changes made by other instances
+// sharing the database are observed. Watching all namespaces, namespaces created afterwards are
+// picked up on the next poll. Unless options carry a resource-version other than "0", existing
+// objects are sent as added events first. Otherwise, watching resumes from the resource-version
+// informed, as long as objects were not added, modified nor deleted since. Events stop, and the
+// channel is closed, when context is done, or after an error event carrying an expired status when
+// the database connection is re-established. It can return InvalidSelectorErr on selectors which
+// can't be parsed, or fields which are not selectable, ResourceVersionExpiredErr when objects
+// changed after the resource-version, NamespaceNotFoundErr when the namespace does not exist, and
+// errors on listening and on listing existing objects.
+func (r *Repository) Watch(
+	ctx context.Context,
+	ns string,
+	gvk schema.GroupVersionKind,
+	options metav1.ListOptions,
+) (<-chan watch.Event, error) {
+	selector, fieldSelector, err := parseSelectors(options)
+	if err != nil {
+		return nil, err
+	}
+	storageGVK := r.storageGVK(gvk)
+	if err = r.validateFieldSelector(storageGVK, fieldSelector); err != nil {
+		return nil, err
+	}
+	namespaces, err := r.listNamespaces(ns, gvk)
+	if err != nil {
+		return nil, err
+	}
+
+	// listening before listing existing objects, so changes in between are not missed
+	ctx, cancel := context.WithCancel(ctx)
+	l := r.newListener(storageGVK)
+	for _, listenNS := range namespaces {
+		if err = l.listen(ctx, listenNS); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	// existing objects are listed at once, regardless of pagination
+	listOptions := options
+	listOptions.Limit, listOptions.Continue, listOptions.ResourceVersion = 0, "", ""
+	existing, err := r.List(ns, gvk, listOptions)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resume := options.ResourceVersion != "" && options.ResourceVersion != "0"
+	if resume {
+		err = r.checkResourceVersion(namespaces, storageGVK, options.ResourceVersion)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	w := &watcher{
+		r:        r,
+		gvk:      gvk,
+		selector: selector,
+		fields:   fieldSelector,
+		known:    map[types.NamespacedName]*unstructured.Unstructured{},
+		events:   make(chan watch.Event),
+	}
+	// only all namespaces watches of namespaced kinds span namespaces created afterwards
+	var poll <-chan time.Time
+	var ticker *time.Ticker
+	if ns == metav1.NamespaceAll && r.namespaced(gvk) {
+		ticker = time.NewTicker(namespacesPollInterval)
+		poll = ticker.C
+	}
+	go func() {
+		defer close(w.events)
+		defer cancel()
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for i := range existing.Items {
+			u := &existing.Items[i]
+			// objects are known by the client resuming, changes on them are sent as modified
+			if resume {
+				w.known[types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}] = u
+				continue
+			}
+			w.added(ctx, u)
+		}
+		schemaName := r.schemaNameforGVK(storageGVK)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-l.events:
+				// events may have been missed while reconnecting, clients must list again
+				if event.Type == watch.Error {
+					w.sendError(ctx, apierrors.NewResourceExpired(
+						"listener reconnected, events may have been missed"))
+					return
+				}
+				// channels are shared by all kinds in the same group
+				if event.Schema != schemaName {
+					continue
+				}
+				w.handle(ctx, event)
+			case <-poll:
+				w.discover(ctx, l, listOptions)
+			}
+		}
+	}()
+	return w.events, nil
+}
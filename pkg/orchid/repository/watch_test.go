@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/klogr"
+
+	"github.com/isutton/orchid/pkg/orchid/config"
+	"github.com/isutton/orchid/pkg/orchid/orm"
+	"github.com/isutton/orchid/test/mocks"
+)
+
+func TestWatcher_handle(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "CronTab"}
+	namespacedName := types.NamespacedName{Namespace: "example", Name: "example"}
+	deleted := orm.Event{
+		Type:      watch.Deleted,
+		Schema:    "v1_CronTab",
+		Namespace: namespacedName.Namespace,
+		Name:      namespacedName.Name,
+	}
+
+	newWatcher := func(selector string) *watcher {
+		s, err := labels.Parse(selector)
+		require.NoError(t, err)
+		return &watcher{
+			gvk:      gvk,
+			selector: s,
+			fields:   fields.Everything(),
+			known:    map[types.NamespacedName]*unstructured.Unstructured{},
+			events:   make(chan watch.Event, 1),
+		}
+	}
+
+	t.Run("deleted object previously sent", func(t *testing.T) {
+		w := newWatcher("")
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": "known"}}
+		w.known[namespacedName] = u
+
+		w.handle(context.TODO(), deleted)
+		event := <-w.events
+		require.Equal(t, watch.Deleted, event.Type)
+		require.Equal(t, u, event.Object)
+		require.Empty(t, w.known)
+	})
+
+	t.Run("deleted object not seen before", func(t *testing.T) {
+		w := newWatcher("")
+		w.handle(context.TODO(), deleted)
+		event := <-w.events
+		require.Equal(t, watch.Deleted, event.Type)
+
+		u, ok := event.Object.(*unstructured.Unstructured)
+		require.True(t, ok)
+		require.Equal(t, gvk, u.GroupVersionKind())
+		require.Equal(t, namespacedName.Name, u.GetName())
+		require.Equal(t, namespacedName.Namespace, u.GetNamespace())
+	})
+
+	t.Run("deleted object not seen before with label selector", func(t *testing.T) {
+		w := newWatcher("app=example")
+		w.handle(context.TODO(), deleted)
+		require.Len(t, w.events, 0)
+	})
+}
+
+func TestWatcher_sendError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int32
+	}{
+		{name: "status error", err: apierrors.NewResourceExpired("expired"), code: 410},
+		{name: "other error", err: errors.New("failed"), code: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &watcher{events: make(chan watch.Event, 1)}
+			w.sendError(context.TODO(), tt.err)
+			event := <-w.events
+			require.Equal(t, watch.Error, event.Type)
+
+			status, ok := event.Object.(*metav1.Status)
+			require.True(t, ok)
+			require.Equal(t, tt.code, status.Code)
+		})
+	}
+}
+
+func TestWatcher_matches(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"image": "image", "replicas": int64(1)},
+	}}
+	u.SetName("example")
+	u.SetLabels(map[string]string{"app": "example"})
+
+	tests := []struct {
+		name          string
+		labelSelector string
+		fieldSelector string
+		want          bool
+	}{
+		{name: "everything", want: true},
+		{name: "labels", labelSelector: "app=example", want: true},
+		{name: "name", fieldSelector: "metadata.name=example", want: true},
+		{name: "nested", fieldSelector: "spec.image=image,spec.replicas=1", want: true},
+		{name: "not informed", fieldSelector: "spec.schedule!=daily", want: true},
+		{name: "different", fieldSelector: "spec.image!=image"},
+		{name: "labels not matching", labelSelector: "app=other", fieldSelector: "spec.image=image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labelSelector, fieldSelector, err := parseSelectors(metav1.ListOptions{
+				LabelSelector: tt.labelSelector,
+				FieldSelector: tt.fieldSelector,
+			})
+			require.NoError(t, err)
+			w := &watcher{selector: labelSelector, fields: fieldSelector}
+			require.Equal(t, tt.want, w.matches(u))
+		})
+	}
+
+	t.Run("invalid selector", func(t *testing.T) {
+		_, _, err := parseSelectors(metav1.ListOptions{FieldSelector: "spec.image"})
+		require.True(t, errors.Is(err, InvalidSelectorErr))
+	})
+}
+
+func TestRepository_validateFieldSelector(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "CronTab"}
+	r := NewRepository(klogr.New(), &config.Config{})
+	openAPIV3Schema := mocks.OpenAPIV3SchemaMock()
+	require.NoError(t, r.schemaFactory(r.schemaNameforGVK(gvk)).Generate(&openAPIV3Schema))
+
+	tests := []struct {
+		name          string
+		fieldSelector string
+		wantErr       bool
+	}{
+		{name: "everything"},
+		{name: "scalar", fieldSelector: "metadata.name=example,spec.simple!=value"},
+		{name: "array", fieldSelector: "spec.array=value", wantErr: true},
+		{name: "object", fieldSelector: "spec.complex=value", wantErr: true},
+		{name: "unknown", fieldSelector: "spec.unknown=value", wantErr: true},
+		{name: "under a list", fieldSelector: "metadata.ownerReferences.name=a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := fields.ParseSelector(tt.fieldSelector)
+			require.NoError(t, err)
+			err = r.validateFieldSelector(gvk, selector)
+			if tt.wantErr {
+				require.True(t, errors.Is(err, InvalidSelectorErr))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
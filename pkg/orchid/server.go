@@ -16,7 +16,8 @@ import (
 
 // Options are the server parameters.
 type Options struct {
-	Address string
+	Address      string
+	AssembleJSON bool
 }
 
 // Server is the API server.
@@ -28,7 +29,12 @@ type Server struct {
 // NewServer creates a new Server using options.
 func NewServer(logger logr.Logger, options Options) *Server {
 	// TODO: move artificial configuration away;
-	config := &config.Config{Username: "postgres", Password: "1", Options: "sslmode=disable"}
+	config := &config.Config{
+		Username:     "postgres",
+		Password:     "1",
+		Options:      "sslmode=disable",
+		AssembleJSON: options.AssembleJSON,
+	}
 
 	repo := repository.NewRepository(logger, config)
 	if err := repo.Bootstrap(); err != nil {
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"errors"
+	"fmt"
 
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -10,15 +11,33 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/isutton/orchid/pkg/orchid/repository"
 )
 
 var GVKNotFoundErr = errors.New("gvk not found")
-var MultipleVersionsFoundErr = errors.New("multiple versions found where one is allowed")
 var SchemaNotFoundErr = errors.New("openAPIV3Schema not found")
 var InvalidObjectErr = errors.New("invalid object")
 
+// ObjectValidationErr carries the field errors found on validating an object against its schema,
+// it matches InvalidObjectErr.
+type ObjectValidationErr struct {
+	GVK    schema.GroupVersionKind // object's gvk
+	Name   string                  // object's name
+	Errors field.ErrorList         // field errors found
+}
+
+// Error returns the field errors found.
+func (e *ObjectValidationErr) Error() string {
+	return fmt.Sprintf("%s: %s", InvalidObjectErr, e.Errors.ToAggregate())
+}
+
+// Unwrap returns InvalidObjectErr.
+func (e *ObjectValidationErr) Unwrap() error {
+	return InvalidObjectErr
+}
+
 // Validator provides validation for unstructured objects.
 type Validator interface {
 	Validate(obj *unstructured.Unstructured) error
@@ -43,11 +62,11 @@ func (v *repositoryValidator) discoverOpenAPIV3Schema(gvk schema.GroupVersionKin
 		if err != nil {
 			return nil, err
 		}
-		if crGVK != gvk {
+		if crGVK.GroupKind() != gvk.GroupKind() {
 			continue
 		}
 
-		s, err := ExtractOpenAPIV3Schema(curCRD.Object)
+		s, err := ExtractOpenAPIV3Schema(curCRD.Object, gvk.Version)
 		if err == SchemaNotFoundErr {
 			continue
 		}
@@ -60,7 +79,8 @@ func (v *repositoryValidator) discoverOpenAPIV3Schema(gvk schema.GroupVersionKin
 }
 
 // Validate validates the given obj according to information available in the repository by finding
-// the first resource definition matching the object's gvk.
+// the first resource definition matching the object's gvk. It returns ObjectValidationErr carrying
+// the field errors found, and GVKNotFoundErr when no resource definition matches.
 func (v *repositoryValidator) Validate(obj *unstructured.Unstructured) error {
 	if obj == nil {
 		return errors.New("input is required")
@@ -81,21 +101,17 @@ func (v *repositoryValidator) Validate(obj *unstructured.Unstructured) error {
 	if err != nil {
 		return err
 	}
-	// perform the actual validation returning the first error if any
-	r := validator.Validate(obj)
-	if len(r.Errors) > 0 {
-		return InvalidObjectErr
+	// perform the actual validation returning all field errors found, if any
+	errs := validation.ValidateCustomResource(nil, obj.UnstructuredContent(), validator)
+	if len(errs) > 0 {
+		return &ObjectValidationErr{GVK: obj.GroupVersionKind(), Name: obj.GetName(), Errors: errs}
 	}
 
 	return nil
 }
 
-// ExtractOpenAPIV3Schema returns the JSON Schema properties contained in obj, assuming u contains
-// the required fields determined by CustomResourceDefinition.
-//
-// It assumes '.spec.versions' to exist, and to contain exactly one entry; its name is currently
-// being ignored.
-func ExtractOpenAPIV3Schema(obj map[string]interface{}) (*extv1.JSONSchemaProps, error) {
+// servedVersion returns the entry in '.spec.versions' named after version, as long as it's served.
+func servedVersion(obj map[string]interface{}, version string) (map[string]interface{}, error) {
 	versions, exists, err := unstructured.NestedSlice(obj, "spec", "versions")
 	if err != nil {
 		return nil, err
@@ -103,14 +119,33 @@ func ExtractOpenAPIV3Schema(obj map[string]interface{}) (*extv1.JSONSchemaProps,
 	if !exists {
 		return nil, SchemaNotFoundErr
 	}
-	if len(versions) != 1 {
-		return nil, MultipleVersionsFoundErr
+	for _, entry := range versions {
+		crdVersion, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(crdVersion, "name")
+		served, _, _ := unstructured.NestedBool(crdVersion, "served")
+		if name == version && served {
+			return crdVersion, nil
+		}
 	}
-	version, ok := versions[0].(map[string]interface{})
-	if !ok {
-		return nil, SchemaNotFoundErr
+	return nil, SchemaNotFoundErr
+}
+
+// ExtractOpenAPIV3Schema returns the JSON Schema properties contained in obj for the informed
+// version, assuming u contains the required fields determined by CustomResourceDefinition.
+//
+// It assumes '.spec.versions' to exist, and to contain a served entry named after version.
+func ExtractOpenAPIV3Schema(
+	obj map[string]interface{},
+	version string,
+) (*extv1.JSONSchemaProps, error) {
+	crdVersion, err := servedVersion(obj, version)
+	if err != nil {
+		return nil, err
 	}
-	schemaMap, exists, err := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+	schemaMap, exists, err := unstructured.NestedMap(crdVersion, "schema", "openAPIV3Schema")
 	if err != nil {
 		return nil, err
 	}